@@ -0,0 +1,375 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+	"github.com/mitchellh/cli"
+	"golang.org/x/time/rate"
+)
+
+// BenchCommand provides the entry point for the command
+type BenchCommand struct {
+	UI cli.Ui
+}
+
+// Help provies the help text for this command.
+func (c *BenchCommand) Help() string {
+	helpText := `
+Usage: wxa-cli [global options] bench [options]
+
+  Send encrypted and signed turns to a running skill and report the throughput,
+  latency percentiles and errors.  The benchmark runs for the duration, or until
+  the number of turns have been sent, or until interrupted.
+
+Options:
+  -url=URL               The URL of the skill. Default "http://localhost:8080".
+
+  -public=FILENAME       The public key for the skill. Default "public.pem".
+
+  -secret=FILENAME       The secret for the skill. Default "secret.txt".
+
+  -concurrency=N         The number of turns to send at once. Default 10.
+
+  -rate=N                The maximum number of turns to send per second. Default 0, unlimited.
+
+  -duration=DURATION     How long to run for. Default "30s".
+
+  -n=N                   The number of turns to send.  Overrides -duration.
+
+  -utterances=FILENAME   A file of utterances to send, one per line.  Blank lines and lines
+                         starting with # are ignored.  Default is to send "hello".
+
+  -timeout=DURATION      The timeout for each turn. Default "10s".
+
+  -json=FILENAME         Write the results as JSON to the file.
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run provides the command functionality
+func (c *BenchCommand) Run(args []string) int {
+	var url, publicFilename, secretFilename, utterancesFilename, jsonFilename string
+	var concurrency, n int
+	var perSecond float64
+	var duration, timeout time.Duration
+	cmdFlags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+	cmdFlags.StringVar(&url, "url", "http://localhost:8080", "the url of the skill")
+	cmdFlags.StringVar(&publicFilename, "public", "public.pem", "the public key for the skill")
+	cmdFlags.StringVar(&secretFilename, "secret", "secret.txt", "the secret for the skill")
+	cmdFlags.IntVar(&concurrency, "concurrency", 10, "the number of turns to send at once")
+	cmdFlags.Float64Var(&perSecond, "rate", 0, "the maximum number of turns per second")
+	cmdFlags.DurationVar(&duration, "duration", 30*time.Second, "how long to run for")
+	cmdFlags.IntVar(&n, "n", 0, "the number of turns to send")
+	cmdFlags.StringVar(&utterancesFilename, "utterances", "", "file of utterances to send")
+	cmdFlags.DurationVar(&timeout, "timeout", 10*time.Second, "the timeout for each turn")
+	cmdFlags.StringVar(&jsonFilename, "json", "", "file to write json results to")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if concurrency < 1 {
+		c.UI.Error("error: concurrency must be at least 1")
+		return 1
+	}
+	if perSecond < 0 || n < 0 || duration <= 0 {
+		c.UI.Error("error: rate, n and duration must not be negative")
+		return 1
+	}
+	utterances := []string{"hello"}
+	if utterancesFilename != "" {
+		var err error
+		utterances, err = readUtterances(utterancesFilename)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+	}
+	client, err := newSkillClient(url, publicFilename, secretFilename, timeout)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	// keep the proxy, dial and TLS settings of the default transport, but use a connection for
+	// each worker, otherwise connections are constantly reopened
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = concurrency
+	transport.MaxConnsPerHost = concurrency
+	client.HTTPClient.Transport = transport
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if n == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+	b := &bench{client: client, utterances: utterances, n: int64(n)}
+	if perSecond > 0 {
+		b.limiter = rate.NewLimiter(rate.Limit(perSecond), 1)
+	}
+	c.UI.Output(fmt.Sprintf("Benchmarking %s with %d workers...", url, concurrency))
+	result := b.run(ctx, concurrency)
+	result.URL = url
+	result.Concurrency = concurrency
+	result.Rate = perSecond
+
+	c.UI.Output(result.String())
+	if jsonFilename != "" {
+		js, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("error encoding results: %s", err))
+			return 1
+		}
+		if err := os.WriteFile(jsonFilename, append(js, '\n'), 0644); err != nil {
+			c.UI.Error(fmt.Sprintf("error writing json file: %s", err))
+			return 1
+		}
+	}
+	if result.Turns == 0 || result.Succeeded == 0 {
+		return 1
+	}
+	return 0
+}
+
+// Synopsis provides the one liner
+func (c *BenchCommand) Synopsis() string {
+	return "Generate load against a skill and report latency."
+}
+
+func readUtterances(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading utterances: %s", err)
+	}
+	defer f.Close()
+	var utterances []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		utterances = append(utterances, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading utterances: %s", err)
+	}
+	if len(utterances) == 0 {
+		return nil, fmt.Errorf("error reading utterances: %s has no utterances", filename)
+	}
+	return utterances, nil
+}
+
+// bench sends turns from a number of workers and collects the results.
+type bench struct {
+	client     *skilltest.Client
+	utterances []string
+	limiter    *rate.Limiter
+	n          int64
+
+	sent atomic.Int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    map[string]int
+}
+
+// BenchResult holds the results of a benchmark.  Durations are in milliseconds so that results
+// are easy to compare between builds.
+type BenchResult struct {
+	URL         string         `json:"url"`
+	Concurrency int            `json:"concurrency"`
+	Rate        float64        `json:"rate,omitempty"`
+	Started     time.Time      `json:"started"`
+	DurationMS  float64        `json:"duration_ms"`
+	Turns       int            `json:"turns"`
+	Succeeded   int            `json:"succeeded"`
+	Failed      int            `json:"failed"`
+	Throughput  float64        `json:"throughput"`
+	Latency     BenchLatency   `json:"latency"`
+	Errors      map[string]int `json:"errors,omitempty"`
+}
+
+// BenchLatency holds the latency of successful turns in milliseconds.
+type BenchLatency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+func (b *bench) run(ctx context.Context, concurrency int) *BenchResult {
+	b.errors = make(map[string]int)
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.worker(ctx)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(started)
+
+	result := &BenchResult{
+		Started:    started,
+		DurationMS: ms(elapsed),
+		Succeeded:  len(b.latencies),
+		Errors:     b.errors,
+	}
+	for _, count := range b.errors {
+		result.Failed += count
+	}
+	result.Turns = result.Succeeded + result.Failed
+	if elapsed > 0 {
+		result.Throughput = float64(result.Succeeded) / elapsed.Seconds()
+	}
+	if len(b.latencies) > 0 {
+		sort.Slice(b.latencies, func(i, j int) bool { return b.latencies[i] < b.latencies[j] })
+		var total time.Duration
+		for _, l := range b.latencies {
+			total += l
+		}
+		result.Latency = BenchLatency{
+			Min:  ms(b.latencies[0]),
+			Mean: ms(total / time.Duration(len(b.latencies))),
+			P50:  ms(percentile(b.latencies, 50)),
+			P95:  ms(percentile(b.latencies, 95)),
+			P99:  ms(percentile(b.latencies, 99)),
+			Max:  ms(b.latencies[len(b.latencies)-1]),
+		}
+	}
+	return result
+}
+
+func (b *bench) worker(ctx context.Context) {
+	for {
+		if b.limiter != nil {
+			if err := b.limiter.Wait(ctx); err != nil {
+				return
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+		i := b.sent.Add(1)
+		if b.n > 0 && i > b.n {
+			return
+		}
+		text := b.utterances[int(i-1)%len(b.utterances)]
+		msg := wxas.WebexAssistantMessage{Text: text}
+		// turns in flight when the benchmark stops are allowed to complete
+		start := time.Now()
+		r, err := b.client.Send(context.Background(), msg)
+		latency := time.Since(start)
+
+		class := classify(r, err)
+		b.mu.Lock()
+		if class == "" {
+			b.latencies = append(b.latencies, latency)
+		} else {
+			b.errors[class]++
+		}
+		b.mu.Unlock()
+	}
+}
+
+// classify returns the class of error for a turn, or an empty string if it succeeded.  Wire
+// errors are classified by status code and the error text returned by the skill.
+func classify(r *skilltest.Result, err error) string {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case err != nil && strings.Contains(err.Error(), "decoding response"):
+		return "invalid response"
+	case err != nil:
+		return "connection error"
+	case r.StatusCode != http.StatusOK:
+		// skills built with this package respond with {"error": "..."}
+		var body struct {
+			Error string `json:"error"`
+		}
+		text := strings.ToLower(http.StatusText(r.StatusCode))
+		if json.Unmarshal(r.Body, &body) == nil && body.Error != "" && len(body.Error) <= 40 {
+			text = body.Error
+		}
+		return fmt.Sprintf("%d %s", r.StatusCode, text)
+	case r.Response.Challenge != r.Challenge:
+		return "challenge mismatch"
+	}
+	return ""
+}
+
+// percentile returns the nearest rank percentile of the sorted durations.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.999999) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// String returns a summary of the results.
+func (r *BenchResult) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nTurns:       %d (%d succeeded, %d failed) in %.1fs\n", r.Turns, r.Succeeded, r.Failed, r.DurationMS/1000)
+	fmt.Fprintf(&b, "Throughput:  %.1f turns/s\n", r.Throughput)
+	if r.Succeeded > 0 {
+		l := r.Latency
+		fmt.Fprintf(&b, "Latency:     min %.1fms, mean %.1fms, max %.1fms\n", l.Min, l.Mean, l.Max)
+		fmt.Fprintf(&b, "             p50 %.1fms, p95 %.1fms, p99 %.1fms\n", l.P50, l.P95, l.P99)
+	}
+	if len(r.Errors) > 0 {
+		classes := make([]string, 0, len(r.Errors))
+		for class := range r.Errors {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		b.WriteString("Errors:\n")
+		for _, class := range classes {
+			fmt.Fprintf(&b, "  %-24s %d\n", class, r.Errors[class])
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
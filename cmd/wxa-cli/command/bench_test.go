@@ -0,0 +1,129 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+)
+
+// timeoutError is a net.Error which has timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassify(t *testing.T) {
+	result := func(status int, body, challenge string) *skilltest.Result {
+		return &skilltest.Result{
+			StatusCode: status,
+			Body:       []byte(body),
+			Challenge:  "abc",
+			Response:   wxas.WebexAssistantResponse{Challenge: challenge},
+		}
+	}
+	tests := []struct {
+		name   string
+		result *skilltest.Result
+		err    error
+		want   string
+	}{
+		{name: "ok", result: result(200, "{}", "abc"), want: ""},
+		{name: "timeout", err: fmt.Errorf("post: %w", timeoutError{}), want: "timeout"},
+		{name: "invalid response", err: errors.New("error decoding response: unexpected EOF"), want: "invalid response"},
+		{name: "connection error", err: errors.New("connection refused"), want: "connection error"},
+		{name: "error body", result: result(401, `{"error": "invalid signature"}`, ""), want: "401 invalid signature"},
+		{name: "plain body", result: result(502, "Bad Gateway\n", ""), want: "502 bad gateway"},
+		{name: "long error", result: result(500, `{"error": "`+strings.Repeat("x", 41)+`"}`, ""), want: "500 internal server error"},
+		{name: "challenge mismatch", result: result(200, "{}", "xyz"), want: "challenge mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(tt.result, tt.err); got != tt.want {
+				t.Errorf("classify = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	durations := func(n int) []time.Duration {
+		d := make([]time.Duration, n)
+		for i := range d {
+			d[i] = time.Duration(i+1) * time.Millisecond
+		}
+		return d
+	}
+	tests := []struct {
+		n    int
+		p    float64
+		want time.Duration
+	}{
+		{n: 1, p: 0, want: 1 * time.Millisecond},
+		{n: 1, p: 50, want: 1 * time.Millisecond},
+		{n: 1, p: 100, want: 1 * time.Millisecond},
+		{n: 10, p: 0, want: 1 * time.Millisecond},
+		{n: 10, p: 50, want: 5 * time.Millisecond},
+		{n: 10, p: 51, want: 6 * time.Millisecond},
+		{n: 10, p: 95, want: 10 * time.Millisecond},
+		{n: 10, p: 100, want: 10 * time.Millisecond},
+		{n: 20, p: 95, want: 19 * time.Millisecond},
+		{n: 100, p: 99, want: 99 * time.Millisecond},
+		{n: 1000, p: 99.9, want: 999 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(durations(tt.n), tt.p); got != tt.want {
+			t.Errorf("p%v of %d = %s, want %s", tt.p, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestReadUtterances(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	got, err := readUtterances(write("utterances.txt", "# greetings\nhello\n\n  what's the weather  \n#\ngoodbye"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"hello", "what's the weather", "goodbye"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("utterances = %q, want %q", got, want)
+	}
+
+	for name, path := range map[string]string{
+		"empty":         write("empty.txt", "# nothing to say\n\n"),
+		"missing":       filepath.Join(dir, "missing.txt"),
+		"line too long": write("long.txt", strings.Repeat("x", 70*1024)),
+	} {
+		if _, err := readUtterances(path); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
@@ -40,17 +40,16 @@ Options:
   -name=NAME       The name of your skill.
   -url=URL         The publicly accessible url for your skill.
   -contact=EMAIL   The contact email address for the skill.
-  -token=TOKEN     Your personal access token from developer.webex.com.
-  -developerid=ID  Your base64 decoded developer id.
   -public=KEY      The public key for your skill.
   -secret=SECRET   The secret for your skill.
-`
+` + serviceHelp
 	return strings.TrimSpace(helpText)
 }
 
 // Run provides the command functionality
 func (c *CreateSkillCommand) Run(args []string) int {
-	var name, url, contact, public, secret, token, developerID string
+	var name, url, contact, public, secret string
+	var sf serviceFlags
 	cmdFlags := flag.NewFlagSet("listskills", flag.ContinueOnError)
 	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
 	cmdFlags.StringVar(&name, "name", "", "the name of your skill.")
@@ -58,17 +57,16 @@ func (c *CreateSkillCommand) Run(args []string) int {
 	cmdFlags.StringVar(&contact, "contact", "", "the contact email address for the skill.")
 	cmdFlags.StringVar(&public, "public", "", "the public key for your skill")
 	cmdFlags.StringVar(&secret, "secret", "", "the secret for your skill")
-	cmdFlags.StringVar(&token, "token", "", "your personal access token")
-	cmdFlags.StringVar(&developerID, "developerid", "", "your base64 decoded developer id")
+	sf.register(cmdFlags)
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
-	if name == "" || url == "" || contact == "" || token == "" || developerID == "" || public == "" || secret == "" {
+	if name == "" || url == "" || contact == "" || sf.developerID == "" || public == "" || secret == "" {
 		c.UI.Error("error: missing required flags")
 		return 1
 	}
 	ctx := context.Background()
-	ss, err := wxaskillsservice.NewClient(developerID, token, nil)
+	ss, err := sf.client()
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
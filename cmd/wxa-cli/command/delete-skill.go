@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
 	"github.com/mitchellh/cli"
 )
 
@@ -39,31 +38,29 @@ Usage: wxa-cli [global options] delete-skill [options]
 Options:
   -id=ID           The Skill ID
   -hard            Pass the HARD_DELETE flag.
-  -token=TOKEN     Your personal access token from developer.webex.com.
-  -developerid=ID  Your base64 decoded developer id.
-`
+` + serviceHelp
 	return strings.TrimSpace(helpText)
 }
 
 // Run provides the command functionality
 func (c *DeleteSkillCommand) Run(args []string) int {
-	var id, token, developerID string
+	var id string
+	var sf serviceFlags
 	var hard bool
 	cmdFlags := flag.NewFlagSet("deleteskill", flag.ContinueOnError)
 	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
 	cmdFlags.StringVar(&id, "id", "", "the skill id to delete")
 	cmdFlags.BoolVar(&hard, "hard", false, "pass the HARD_DELETE flag")
-	cmdFlags.StringVar(&token, "token", "", "your personal access token")
-	cmdFlags.StringVar(&developerID, "developerid", "", "your base64 decoded developer id")
+	sf.register(cmdFlags)
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
-	if id == "" || token == "" || developerID == "" {
+	if id == "" || sf.developerID == "" {
 		c.UI.Error("error: missing required flags")
 		return 1
 	}
 	ctx := context.Background()
-	ss, err := wxaskillsservice.NewClient(developerID, token, nil)
+	ss, err := sf.client()
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
@@ -0,0 +1,122 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+	"github.com/mitchellh/cli"
+)
+
+// GetSkillCommand provides the entry point for the command
+type GetSkillCommand struct {
+	UI cli.Ui
+}
+
+// Help provies the help text for this command.
+func (c *GetSkillCommand) Help() string {
+	helpText := `
+Usage: wxa-cli [global options] get-skill [options]
+
+  Get a skill on the skills service.
+
+Options:
+  -id=ID           The Skill ID
+` + serviceHelp
+	return strings.TrimSpace(helpText)
+}
+
+// Run provides the command functionality
+func (c *GetSkillCommand) Run(args []string) int {
+	var id string
+	var sf serviceFlags
+	cmdFlags := flag.NewFlagSet("getskill", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+	cmdFlags.StringVar(&id, "id", "", "the skill id to get")
+	sf.register(cmdFlags)
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if id == "" || sf.developerID == "" {
+		c.UI.Error("error: missing required flags")
+		return 1
+	}
+	ctx := context.Background()
+	ss, err := sf.client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	skill, err := ss.GetSkill(ctx, id)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output(formatSkill(skill))
+
+	return 0
+}
+
+// Synopsis provides the one liner
+func (c *GetSkillCommand) Synopsis() string {
+	return "Get a skill on the skills service."
+}
+
+// formatSkill returns the details of the skill, one per line, leaving out those that aren't set.
+func formatSkill(s *wxaskillsservice.Skill) string {
+	var b strings.Builder
+	add := func(name string, v *string) {
+		if v != nil && *v != "" {
+			fmt.Fprintf(&b, "%-14s %s\n", name+":", *v)
+		}
+	}
+	add("ID", s.SkillID)
+	add("DeveloperID", s.DeveloperID)
+	add("Name", s.Name)
+	add("URL", s.URL)
+	add("ContactEmail", s.ContactEmail)
+	add("Description", s.Description)
+	add("HomePage", s.HomePage)
+	if len(s.Languages) > 0 {
+		add("Languages", wxaskillsservice.String(strings.Join(s.Languages, ", ")))
+	}
+	if len(s.SuggestedInvocationNames) > 0 {
+		add("Invocations", wxaskillsservice.String(strings.Join(s.SuggestedInvocationNames, ", ")))
+	}
+	if s.Public != nil {
+		add("Public", wxaskillsservice.String(fmt.Sprint(*s.Public)))
+	}
+	add("CreatedAt", s.CreatedAt)
+	add("ModifiedAt", s.ModifiedAt)
+	add("LastActiveAt", s.LastActiveAt)
+	if s.Deleted != nil && *s.Deleted {
+		add("DeletedAt", wxaskillsservice.String(fmt.Sprintf("%s (SOFT DELETED)", stringValue(s.DeletedAt))))
+	}
+	if s.PublicKey != nil && *s.PublicKey != "" {
+		add("PublicKey", wxaskillsservice.String("\n"+strings.TrimSpace(*s.PublicKey)))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
 	"github.com/mitchellh/cli"
 )
 
@@ -37,29 +36,25 @@ Usage: wxa-cli [global options] list-skills [options]
   List skills configured on the skills service.
 
 Options:
-  -token=TOKEN     Your personal access token from developer.webex.com.
-  -developerid=ID  Your base64 decoded developer id.
-
-`
+` + serviceHelp
 	return strings.TrimSpace(helpText)
 }
 
 // Run provides the command functionality
 func (c *ListSkillsCommand) Run(args []string) int {
-	var token, developerID string
+	var sf serviceFlags
 	cmdFlags := flag.NewFlagSet("listskills", flag.ContinueOnError)
 	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
-	cmdFlags.StringVar(&token, "token", "", "your personal access token")
-	cmdFlags.StringVar(&developerID, "developerid", "", "your base64 decoded developer id")
+	sf.register(cmdFlags)
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
-	if token == "" || developerID == "" {
-		c.UI.Error("error: token and developer id flags reqUIred")
+	if sf.developerID == "" {
+		c.UI.Error("error: developer id flag required")
 		return 1
 	}
 	ctx := context.Background()
-	ss, err := wxaskillsservice.NewClient(developerID, token, nil)
+	ss, err := sf.client()
 	if err != nil {
 		c.UI.Error(err.Error())
 		return 1
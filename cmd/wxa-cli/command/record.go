@@ -0,0 +1,243 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+	"github.com/mitchellh/cli"
+)
+
+// RecordCommand provides the entry point for the command
+type RecordCommand struct {
+	UI cli.Ui
+}
+
+// Help provies the help text for this command.
+func (c *RecordCommand) Help() string {
+	helpText := `
+Usage: wxa-cli [global options] record [options]
+
+  Run a reverse proxy in front of a skill which records each turn.  Requests are
+  decrypted with the skill's private key and stored, along with the response, as
+  one JSON object per line.  The recordings can be replayed against another build
+  of the skill with "wxa-cli replay".
+
+  The recordings contain the decrypted messages, including what users said and
+  their ids, so keep them safe or use -redact.  Turns rejected as unauthorized
+  aren't recorded.
+
+Options:
+  -target=URL         The URL of the skill. Default "http://localhost:8080".
+
+  -addr=ADDRESS       The address to listen on. Default "localhost:8081".
+
+  -private=FILENAME   The private key for the skill. Default "private.pem".
+
+  -out=FILENAME       The file to append recordings to. Default "recordings.jsonl".
+
+  -redact=POLICY      Redact the messages and responses before they are written,
+                      using "default", "strict" or a list of field=action pairs,
+                      e.g. "text=mask,user_id=hash".  The challenge is also
+                      removed.  Replaying turns with redacted text will usually
+                      show differences.  Default "none".
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run provides the command functionality
+func (c *RecordCommand) Run(args []string) int {
+	var target, addr, privateFilename, outFilename, redaction string
+	cmdFlags := flag.NewFlagSet("record", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+	cmdFlags.StringVar(&target, "target", "http://localhost:8080", "the url of the skill")
+	cmdFlags.StringVar(&addr, "addr", "localhost:8081", "the address to listen on")
+	cmdFlags.StringVar(&privateFilename, "private", "private.pem", "the private key for the skill")
+	cmdFlags.StringVar(&outFilename, "out", "recordings.jsonl", "the file to append recordings to")
+	cmdFlags.StringVar(&redaction, "redact", "none", "the redaction policy for recordings")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	policy, err := redact.Parse(redaction)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error: %s", err))
+		return 1
+	}
+	targetURL, err := url.Parse(target)
+	if err != nil || targetURL.Scheme == "" || targetURL.Host == "" {
+		c.UI.Error(fmt.Sprintf("error: invalid target %q", target))
+		return 1
+	}
+	privateKey, err := os.ReadFile(privateFilename)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error reading private key: %s", err))
+		return 1
+	}
+	f, err := os.OpenFile(outFilename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error opening recordings file: %s", err))
+		return 1
+	}
+	defer f.Close()
+
+	rec := &recorder{ui: c.UI, privateKey: string(privateKey), policy: policy, out: f}
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	proxy.ModifyResponse = rec.modifyResponse
+	srv := &http.Server{Addr: addr, Handler: rec.handler(proxy)}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+	c.UI.Output(fmt.Sprintf("Recording turns to %s at http://%s, forwarding to %s", outFilename, addr, target))
+	select {
+	case err := <-errs:
+		c.UI.Error(fmt.Sprintf("error running proxy: %s", err))
+		return 1
+	case <-ctx.Done():
+		srv.Shutdown(context.Background())
+	}
+	c.UI.Output(fmt.Sprintf("Recorded %d turns", rec.count))
+	return 0
+}
+
+// Synopsis provides the one liner
+func (c *RecordCommand) Synopsis() string {
+	return "Record turns sent to a skill for replaying."
+}
+
+type recordingKey struct{}
+
+// recorder decrypts the turns passing through the proxy and writes them with their responses.
+type recorder struct {
+	ui         cli.Ui
+	privateKey string
+	policy     *redact.Policy
+
+	mu    sync.Mutex
+	out   io.Writer
+	count int
+}
+
+// handler decrypts turns before they are forwarded, so the response can be recorded with the
+// message.  Requests which aren't turns, such as health checks, are forwarded without being
+// recorded.
+func (rec *recorder) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			} else {
+				http.Error(w, "error reading request", http.StatusBadRequest)
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var req wxas.WebexAssistantRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			rec.ui.Warn(fmt.Sprintf("not recording invalid request: %s", err))
+			next.ServeHTTP(w, r)
+			return
+		}
+		decrypted, err := wxas.DecryptMessage(rec.privateKey, req.Message)
+		var msg wxas.WebexAssistantMessage
+		if err == nil {
+			msg, err = wxas.ParseMessage(decrypted)
+		}
+		if err == nil {
+			r = r.WithContext(context.WithValue(r.Context(), recordingKey{}, &skilltest.Recording{Time: time.Now(), Message: msg}))
+		} else {
+			rec.ui.Warn(fmt.Sprintf("not recording request: %s", err))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// modifyResponse records the response to a decrypted turn, leaving the response unchanged.
+func (rec *recorder) modifyResponse(resp *http.Response) error {
+	recording, ok := resp.Request.Context().Value(recordingKey{}).(*skilltest.Recording)
+	// turns rejected as unauthorized depend on how they were signed rather than on the skill
+	if !ok || resp.StatusCode == http.StatusUnauthorized {
+		return nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	recording.Status = resp.StatusCode
+	if resp.StatusCode == http.StatusOK {
+		var r wxas.WebexAssistantResponse
+		if err := json.Unmarshal(body, &r); err == nil {
+			recording.Response = &r
+		}
+	}
+	if recording.Response == nil {
+		recording.Body = string(body)
+	}
+	rec.write(recording)
+	return nil
+}
+
+// write writes the recording, redacted using the policy if there is one.
+func (rec *recorder) write(recording *skilltest.Recording) {
+	if rec.policy != nil {
+		recording.Message = recording.Message.Redact(rec.policy)
+		recording.Message.Challenge = ""
+		if recording.Response != nil {
+			r := recording.Response.Redact(rec.policy)
+			r.Challenge = ""
+			recording.Response = &r
+		}
+	}
+	js, err := json.Marshal(recording)
+	if err != nil {
+		rec.ui.Error(fmt.Sprintf("error encoding recording: %s", err))
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if _, err := rec.out.Write(append(js, '\n')); err != nil {
+		rec.ui.Error(fmt.Sprintf("error writing recording: %s", err))
+		return
+	}
+	rec.count++
+	rec.ui.Info(fmt.Sprintf("recorded %q (%d)", recording.Message.Text, recording.Status))
+}
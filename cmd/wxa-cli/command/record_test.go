@@ -0,0 +1,239 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+	"github.com/mitchellh/cli"
+)
+
+func echoHandler(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+	if msg.Text == "fail" {
+		return wxas.WebexAssistantResponse{}, errors.New("handler failed")
+	}
+	return wxas.WebexAssistantResponse{
+		Challenge: msg.Challenge,
+		Directives: []wxas.WebexAssistantDirective{
+			{Name: wxas.DirectiveNameReply, Type: wxas.DirectiveTypeView, Payload: wxas.Payload{Text: msg.Text}},
+			{Name: wxas.DirectiveNameUIHint, Type: wxas.DirectiveTypeView, Payload: wxas.Payload{Text: []string{msg.Text}}},
+			{Name: wxas.DirectiveNameSleep, Type: wxas.DirectiveTypeAction},
+		},
+		Frame: msg.Frame,
+	}, nil
+}
+
+// recordTurns records the turns sent through a recording proxy in front of the skill.
+func recordTurns(t *testing.T, h *skilltest.Harness, policy *redact.Policy, texts ...string) []skilltest.Recording {
+	t.Helper()
+	target, _ := url.Parse(h.Server.URL)
+	var out bytes.Buffer
+	rec := &recorder{ui: cli.NewMockUi(), privateKey: h.Keys.PrivateKey, policy: policy, out: &out}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = rec.modifyResponse
+	srv := httptest.NewServer(rec.handler(proxy))
+	defer srv.Close()
+
+	client := *h.Client
+	client.URL = srv.URL
+	client.HTTPClient = srv.Client()
+	for _, text := range texts {
+		msg := wxas.WebexAssistantMessage{Text: text, Frame: wxas.Frame{"said": text}, Params: wxas.Params{Locale: "en_US"}}
+		if _, err := client.Send(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a turn signed with the wrong secret isn't recorded
+	client.Secret = "wrong"
+	if _, err := client.Send(context.Background(), wxas.WebexAssistantMessage{Text: "unauthorized"}); err != nil {
+		t.Fatal(err)
+	}
+	recordings, err := skilltest.ReadRecordings(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != len(texts) || rec.count != len(texts) {
+		t.Fatalf("recorded %d turns, want %d", len(recordings), len(texts))
+	}
+	return recordings
+}
+
+func TestRecordReplay(t *testing.T) {
+	h := skilltest.New(t, wxas.HandlerFunc(echoHandler))
+	recordings := recordTurns(t, h, nil, "hello", "my number is 0123456789", "fail")
+	if recordings[0].Message.Text != "hello" || recordings[0].Status != 200 || recordings[0].Message.Challenge == "" {
+		t.Errorf("recording = %+v", recordings[0])
+	}
+	if r := recordings[0].Response; r == nil || len(r.Directives) != 3 || r.Frame["said"] != "hello" ||
+		!reflect.DeepEqual(r.Directives[1].Payload.Text, []interface{}{"hello"}) {
+		t.Errorf("recorded response = %+v", recordings[0].Response)
+	}
+	var errBody struct{ Error string }
+	if err := json.Unmarshal([]byte(recordings[2].Body), &errBody); err != nil || recordings[2].Status != 500 || errBody.Error != "Internal Server Error" {
+		t.Errorf("recording = %+v", recordings[2])
+	}
+
+	dir := t.TempDir()
+	recordingsFile := filepath.Join(dir, "recordings.jsonl")
+	publicFile := filepath.Join(dir, "public.pem")
+	secretFile := filepath.Join(dir, "secret.txt")
+	writeRecordings(t, recordingsFile, recordings)
+	for name, content := range map[string]string{publicFile: h.Keys.PublicKey, secretFile: h.Keys.Secret + "\n"} {
+		if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replay := func() (int, string) {
+		ui := cli.NewMockUi()
+		code := (&ReplayCommand{UI: ui}).Run([]string{"-url", h.Server.URL, "-public", publicFile, "-secret", secretFile, recordingsFile})
+		return code, ui.OutputWriter.String() + ui.ErrorWriter.String()
+	}
+
+	if code, out := replay(); code != 0 || !strings.Contains(out, "3 turns, 3 same, 0 different, 0 errors") {
+		t.Errorf("replay against the same skill = %d:\n%s", code, out)
+	}
+
+	// redacted recordings don't contain what was said or the challenge, but can still be
+	// replayed, and the echo skill responds in the same way to the redacted text
+	recordings = recordTurns(t, h, redact.Strict(), "hello", "my number is 0123456789")
+	for _, r := range recordings {
+		if r.Message.Text != "" || r.Message.Challenge != "" || r.Response.Challenge != "" || len(r.Message.Frame) != 0 || len(r.Response.Frame) != 0 {
+			t.Errorf("recording wasn't redacted: %+v", r)
+		}
+		if len(r.Response.Directives) != 3 {
+			t.Fatalf("recorded response has %d directives, want 3", len(r.Response.Directives))
+		}
+		if text := r.Response.Directives[0].Payload.Text; text != "" {
+			t.Errorf("reply text wasn't redacted: %v", text)
+		}
+		if hint := r.Response.Directives[1].Payload.Text; !reflect.DeepEqual(hint, []interface{}{""}) {
+			t.Errorf("ui hint text wasn't redacted: %v", hint)
+		}
+	}
+	writeRecordings(t, recordingsFile, recordings)
+	if code, out := replay(); code != 0 || !strings.Contains(out, "2 turns, 2 same, 0 different, 0 errors") {
+		t.Errorf("replay of redacted recordings = %d:\n%s", code, out)
+	}
+}
+
+func writeRecordings(t *testing.T, name string, recordings []skilltest.Recording) {
+	t.Helper()
+	var b bytes.Buffer
+	for _, r := range recordings {
+		rec := &recorder{ui: cli.NewMockUi(), out: &b}
+		rec.write(&r)
+	}
+	if err := os.WriteFile(name, b.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestRecordReadErrors(t *testing.T) {
+	keys, err := skilltest.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// turn returns a turn with the message encrypted, which is forwarded without being recorded
+	// if it can't be parsed
+	turn := func(message string) func() *http.Request {
+		return func() *http.Request {
+			token, err := wxas.EncryptMessage(keys.PublicKey, message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := json.Marshal(wxas.WebexAssistantRequest{Signature: wxas.SignMessage(keys.Secret, token), Message: token})
+			return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		}
+	}
+	tests := []struct {
+		name      string
+		body      func() *http.Request
+		status    int
+		forwarded bool
+		recorded  bool
+	}{
+		{
+			name: "too large",
+			body: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", 1<<20+1)))
+			},
+			status: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "read error",
+			body:   func() *http.Request { return httptest.NewRequest(http.MethodPost, "/", errReader{}) },
+			status: http.StatusBadRequest,
+		},
+		{
+			name:      "invalid request",
+			body:      func() *http.Request { return httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{")) },
+			status:    http.StatusOK,
+			forwarded: true,
+		},
+		{
+			name:      "undecodable message",
+			body:      turn(`{"text":1}`),
+			status:    http.StatusOK,
+			forwarded: true,
+		},
+		{
+			name:      "valid message",
+			body:      turn(`{"text":"hello"}`),
+			status:    http.StatusOK,
+			forwarded: true,
+			recorded:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{ui: cli.NewMockUi(), privateKey: keys.PrivateKey}
+			forwarded := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				forwarded = true
+				recording, ok := r.Context().Value(recordingKey{}).(*skilltest.Recording)
+				if ok != tt.recorded {
+					t.Errorf("recording attached = %t, want %t", ok, tt.recorded)
+				}
+				if ok && recording.Message.Text != "hello" {
+					t.Errorf("recorded message = %+v", recording.Message)
+				}
+			})
+			w := httptest.NewRecorder()
+			rec.handler(next).ServeHTTP(w, tt.body())
+			if w.Code != tt.status || forwarded != tt.forwarded {
+				t.Errorf("status %d, forwarded %t, want %d, %t", w.Code, forwarded, tt.status, tt.forwarded)
+			}
+		})
+	}
+}
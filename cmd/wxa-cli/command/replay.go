@@ -0,0 +1,119 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+	"github.com/mitchellh/cli"
+)
+
+// ReplayCommand provides the entry point for the command
+type ReplayCommand struct {
+	UI cli.Ui
+}
+
+// Help provies the help text for this command.
+func (c *ReplayCommand) Help() string {
+	helpText := `
+Usage: wxa-cli [global options] replay [options] FILE
+
+  Replay turns recorded by "wxa-cli record" against a skill, and report any
+  differences in the status or directives of the responses.  Each turn is
+  encrypted with the public key and sent with its recorded frame, so the skill
+  can be a different build with different keys to the one that was recorded.
+
+Options:
+  -url=URL            The URL of the skill. Default "http://localhost:8080".
+
+  -public=FILENAME    The public key for the skill. Default "public.pem".
+
+  -secret=FILENAME    The secret for the skill. Default "secret.txt".
+
+  -timeout=DURATION   The timeout for each turn. Default "10s".
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run provides the command functionality
+func (c *ReplayCommand) Run(args []string) int {
+	var url, publicFilename, secretFilename string
+	var timeout time.Duration
+	cmdFlags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+	cmdFlags.StringVar(&url, "url", "http://localhost:8080", "the url of the skill")
+	cmdFlags.StringVar(&publicFilename, "public", "public.pem", "the public key for the skill")
+	cmdFlags.StringVar(&secretFilename, "secret", "secret.txt", "the secret for the skill")
+	cmdFlags.DurationVar(&timeout, "timeout", 10*time.Second, "the timeout for each turn")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if cmdFlags.NArg() != 1 {
+		c.UI.Error("error: a single recordings file is required")
+		return 1
+	}
+	f, err := os.Open(cmdFlags.Arg(0))
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error reading recordings: %s", err))
+		return 1
+	}
+	recordings, err := skilltest.ReadRecordings(f)
+	f.Close()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error reading recordings: %s", err))
+		return 1
+	}
+	client, err := newSkillClient(url, publicFilename, secretFilename, timeout)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	different, failed := 0, 0
+	for i, rec := range recordings {
+		name := fmt.Sprintf("turn %d %q", i+1, rec.Message.Text)
+		r, err := client.Send(context.Background(), rec.Replay())
+		if err != nil {
+			failed++
+			c.UI.Error("ERROR " + name)
+			c.UI.Output(indent(err.Error()))
+			continue
+		}
+		if diff := rec.Compare(r); diff != "" {
+			different++
+			c.UI.Error("DIFF  " + name)
+			c.UI.Output(indent(diff))
+			continue
+		}
+		c.UI.Info("SAME  " + name)
+	}
+	c.UI.Output(fmt.Sprintf("\n%d turns, %d same, %d different, %d errors", len(recordings), len(recordings)-different-failed, different, failed))
+	if different > 0 || failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Synopsis provides the one liner
+func (c *ReplayCommand) Synopsis() string {
+	return "Replay recorded turns against a skill and compare the responses."
+}
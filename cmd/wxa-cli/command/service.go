@@ -0,0 +1,97 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+)
+
+// serviceHelp is the help text for the flags shared by the skills service commands.
+const serviceHelp = `  -token=TOKEN     Your personal access token from developer.webex.com.  If not given,
+                   the token is read from WEBEX_TOKEN, or refreshed using OAuth when
+                   WEBEX_CLIENT_ID, WEBEX_CLIENT_SECRET and WEBEX_REFRESH_TOKEN are set.
+  -developerid=ID  Your base64 decoded developer id.
+  -region=REGION   The region of the skills service. Default "us-east-2".
+  -base-url=URL    The base URL of the skills service, e.g. for staging. Overrides -region.
+`
+
+// Environment variables used for the token when the token flag isn't given.
+const (
+	envClientID     = "WEBEX_CLIENT_ID"
+	envClientSecret = "WEBEX_CLIENT_SECRET"
+	envRefreshToken = "WEBEX_REFRESH_TOKEN"
+)
+
+// serviceFlags holds the flags shared by the skills service commands.
+type serviceFlags struct {
+	token       string
+	developerID string
+	region      string
+	baseURL     string
+}
+
+// register adds the flags to the flag set.
+func (f *serviceFlags) register(cmdFlags *flag.FlagSet) {
+	cmdFlags.StringVar(&f.token, "token", "", "your personal access token")
+	cmdFlags.StringVar(&f.developerID, "developerid", "", "your base64 decoded developer id")
+	cmdFlags.StringVar(&f.region, "region", wxaskillsservice.DefaultRegion, "the region of the skills service")
+	cmdFlags.StringVar(&f.baseURL, "base-url", "", "the base url of the skills service")
+}
+
+// client returns a client for the skills service using the flags.
+func (f *serviceFlags) client() (*wxaskillsservice.Client, error) {
+	opts := []wxaskillsservice.Option{wxaskillsservice.WithRegion(f.region)}
+	if f.baseURL != "" {
+		opts = append(opts, wxaskillsservice.WithBaseURL(f.baseURL))
+	}
+	if f.token == "" {
+		ts, err := tokenSource()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, wxaskillsservice.WithTokenSource(ts))
+	}
+	return wxaskillsservice.NewClient(f.developerID, f.token, nil, opts...)
+}
+
+// tokenSource returns the token source to use when the token flag isn't given: OAuth if the
+// integration is configured in the environment, caching its tokens in the user's cache
+// directory, otherwise the token in WEBEX_TOKEN.
+func tokenSource() (wxaskillsservice.TokenSource, error) {
+	clientID, clientSecret, refreshToken := os.Getenv(envClientID), os.Getenv(envClientSecret), os.Getenv(envRefreshToken)
+	if clientID != "" && clientSecret != "" && refreshToken != "" {
+		cacheFile := ""
+		if dir, err := os.UserCacheDir(); err == nil {
+			cacheFile = filepath.Join(dir, "wxa-cli", "token-"+url.PathEscape(clientID)+".json")
+		}
+		ts := wxaskillsservice.NewOAuthTokenSource(clientID, clientSecret, refreshToken, cacheFile)
+		ts.OnCacheError = func(err error) {
+			// the command still works, but the token will be refreshed again next time
+			fmt.Fprintf(os.Stderr, "warning: %s\n", err)
+		}
+		return ts, nil
+	}
+	if os.Getenv(wxaskillsservice.DefaultTokenEnv) != "" {
+		return wxaskillsservice.EnvToken(""), nil
+	}
+	return nil, errors.New("error: token required, use -token or set " + wxaskillsservice.DefaultTokenEnv)
+}
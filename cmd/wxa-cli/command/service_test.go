@@ -0,0 +1,64 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice/fake"
+	"github.com/mitchellh/cli"
+)
+
+func TestServiceFlags(t *testing.T) {
+	srv := fake.NewServer("dev", "token")
+	defer srv.Close()
+	added := srv.AddSkill(wxaskillsservice.Skill{Name: wxaskillsservice.String("Echo")})
+	run := func(args ...string) (int, string) {
+		ui := cli.NewMockUi()
+		args = append([]string{"-id", *added.SkillID, "-token", "token", "-developerid", "dev"}, args...)
+		code := (&GetSkillCommand{UI: ui}).Run(args)
+		return code, ui.OutputWriter.String() + ui.ErrorWriter.String()
+	}
+
+	// the base URL overrides the region, so the skill is read from the fake
+	if code, out := run("-region", "eu-central-1", "-base-url", srv.URL); code != 0 || !strings.Contains(out, "Name:          Echo") {
+		t.Errorf("get-skill with -base-url = %d:\n%s", code, out)
+	}
+	if code, out := run("-region", "nowhere"); code != 1 || !strings.Contains(out, `invalid region "nowhere"`) {
+		t.Errorf("get-skill with an invalid region = %d:\n%s", code, out)
+	}
+	if code, out := run("-base-url", "example.com"); code != 1 || !strings.Contains(out, `invalid base url "example.com"`) {
+		t.Errorf("get-skill with an invalid base url = %d:\n%s", code, out)
+	}
+
+	for _, tt := range []struct {
+		flags serviceFlags
+		want  string
+	}{
+		{flags: serviceFlags{region: "eu-central-1"}, want: "https://assistant.eu-central-1.intelligence.webex.com/skills/api/developers/dev"},
+		{flags: serviceFlags{region: "eu-central-1", baseURL: srv.URL}, want: srv.BaseURL()},
+	} {
+		tt.flags.token, tt.flags.developerID = "token", "dev"
+		client, err := tt.flags.client()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.BaseURL != tt.want {
+			t.Errorf("BaseURL with %+v = %q, want %q", tt.flags, client.BaseURL, tt.want)
+		}
+	}
+}
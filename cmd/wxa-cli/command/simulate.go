@@ -0,0 +1,240 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+	"github.com/mitchellh/cli"
+)
+
+// SimulateCommand provides the entry point for the command
+type SimulateCommand struct {
+	UI cli.Ui
+}
+
+// Help provies the help text for this command.
+func (c *SimulateCommand) Help() string {
+	helpText := `
+Usage: wxa-cli [global options] simulate [options]
+
+  Have a conversation with a running skill in the terminal.  Each line is
+  encrypted and signed and sent to the skill in the same way as Webex Assistant,
+  and the directives in the response are displayed.  The frame is carried
+  between turns while the skill is listening, but the conversation history is
+  not, so the history is always sent empty.  Type :help for the commands.
+
+Options:
+  -url=URL            The URL of the skill. Default "http://localhost:8080".
+
+  -public=FILENAME    The public key for the skill. Default "public.pem".
+
+  -secret=FILENAME    The secret for the skill. Default "secret.txt".
+
+  -locale=LOCALE      The locale to send. Default "en_US".
+
+  -timezone=ZONE      The time zone to send, e.g. "Europe/London".
+
+  -timeout=DURATION   The timeout for each turn. Default "10s".
+
+  -web                Run the simulator in the browser instead of the terminal.  It
+                      shows web views, clickable ui-hints and the raw requests and
+                      responses, and lets you choose the directives the client supports.
+
+  -addr=ADDRESS       The address to serve the browser simulator on. Default "localhost:8081".
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+const simulateHelp = `Commands:
+  :intro            invoke the skill, so it introduces itself
+  :locale [LOCALE]  show or set the locale, e.g. :locale fr_FR
+  :frame            show the current frame
+  :reset            end the conversation
+  :help             show this help
+  :quit             exit
+Anything else is sent to the skill.`
+
+// Run provides the command functionality
+func (c *SimulateCommand) Run(args []string) int {
+	var url, publicFilename, secretFilename, locale, timezone, addr string
+	var timeout time.Duration
+	var web bool
+	cmdFlags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+	cmdFlags.StringVar(&url, "url", "http://localhost:8080", "the url of the skill")
+	cmdFlags.StringVar(&publicFilename, "public", "public.pem", "the public key for the skill")
+	cmdFlags.StringVar(&secretFilename, "secret", "secret.txt", "the secret for the skill")
+	cmdFlags.StringVar(&locale, "locale", "en_US", "the locale to send")
+	cmdFlags.StringVar(&timezone, "timezone", "", "the time zone to send")
+	cmdFlags.DurationVar(&timeout, "timeout", 10*time.Second, "the timeout for each turn")
+	cmdFlags.BoolVar(&web, "web", false, "run the simulator in the browser")
+	cmdFlags.StringVar(&addr, "addr", "localhost:8081", "the address to serve the browser simulator on")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	client, err := newSkillClient(url, publicFilename, secretFilename, timeout)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	session := skilltest.NewSession(client)
+	setLocale(session, locale)
+	session.Params.TimeZone = timezone
+
+	if web {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		c.UI.Output(fmt.Sprintf("Simulating %s at http://%s", url, addr))
+		if err := runWebSimulator(ctx, addr, newWebSimulator(url, session)); err != nil {
+			c.UI.Error(fmt.Sprintf("error running simulator: %s", err))
+			return 1
+		}
+		return 0
+	}
+	c.UI.Output(fmt.Sprintf("Talking to %s.  Type :help for the commands.", url))
+	c.repl(os.Stdin, os.Stdout, session)
+	return 0
+}
+
+// Synopsis provides the one liner
+func (c *SimulateCommand) Synopsis() string {
+	return "Have a conversation with a skill in the terminal."
+}
+
+func (c *SimulateCommand) repl(in io.Reader, out io.Writer, session *skilltest.Session) {
+	ctx := context.Background()
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, ":") {
+			r, err := session.Say(ctx, line)
+			c.render(session, r, err)
+			continue
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		arg = strings.TrimSpace(arg)
+		switch command {
+		case ":intro":
+			r, err := session.Intro(ctx)
+			c.render(session, r, err)
+		case ":locale":
+			if arg != "" {
+				setLocale(session, arg)
+			}
+			c.UI.Output(fmt.Sprintf("locale %s, language %s", session.Params.Locale, session.Params.Language))
+		case ":frame":
+			if len(session.Frame) == 0 {
+				c.UI.Output("no frame")
+				break
+			}
+			js, _ := json.MarshalIndent(session.Frame, "", "  ")
+			c.UI.Output(string(js))
+		case ":reset":
+			session.Reset()
+			c.UI.Output("conversation ended")
+		case ":help":
+			c.UI.Output(simulateHelp)
+		case ":quit", ":exit":
+			return
+		default:
+			c.UI.Error(fmt.Sprintf("unknown command %s, type :help for the commands", command))
+		}
+	}
+}
+
+// render displays the directives in the response to a turn.
+func (c *SimulateCommand) render(session *skilltest.Session, r *skilltest.Result, err error) {
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error: %s", err))
+		return
+	}
+	if r.StatusCode != 200 {
+		c.UI.Error(fmt.Sprintf("error: %d %s", r.StatusCode, strings.TrimSpace(string(r.Body))))
+		return
+	}
+	if r.Response.Challenge != r.Challenge {
+		c.UI.Warn("warning: the response doesn't echo the challenge, Webex Assistant would reject it")
+	}
+	for _, d := range r.Response.Directives {
+		p := d.Payload
+		switch d.Name {
+		case wxas.DirectiveNameReply, wxas.DirectiveNameLongReply:
+			for _, t := range skilltest.DirectiveTexts(d) {
+				c.UI.Info(t)
+			}
+		case wxas.DirectiveNameSpeak:
+			c.UI.Output(fmt.Sprintf("  (speak) %s", strings.Join(skilltest.DirectiveTexts(d), " ")))
+		case wxas.DirectiveNameUIHint:
+			hints := skilltest.DirectiveTexts(d)
+			if p.Prompt != nil {
+				c.UI.Output(fmt.Sprintf("  (hint) %s", *p.Prompt))
+			}
+			c.UI.Output(fmt.Sprintf("  (hints) [%s]", strings.Join(hints, "] [")))
+		case wxas.DirectiveNameDisplayWebView:
+			title, url := "", ""
+			if p.Title != nil {
+				title = *p.Title + " "
+			}
+			if p.URL != nil {
+				url = *p.URL
+			}
+			c.UI.Output(fmt.Sprintf("  (web view) %s<%s>", title, url))
+		case wxas.DirectiveNameClearWebView:
+			c.UI.Output("  (web view cleared)")
+		case wxas.DirectiveNameListen, wxas.DirectiveNameSleep:
+			// shown below, once the session has been updated
+		default:
+			detail := strings.Join(skilltest.DirectiveTexts(d), " ")
+			if len(p.Payload) > 0 {
+				js, _ := json.Marshal(p.Payload)
+				detail = strings.TrimSpace(detail + " " + string(js))
+			}
+			c.UI.Output(fmt.Sprintf("  (%s) %s", d.Name, detail))
+		}
+	}
+	if session.Listening {
+		c.UI.Output("  (listening)")
+	} else {
+		c.UI.Output("  (sleeping, the conversation has ended)")
+	}
+}
+
+// setLocale sets the locale of the session, along with the language from the locale.
+func setLocale(session *skilltest.Session, locale string) {
+	session.Params.Locale = locale
+	language, _, _ := strings.Cut(locale, "_")
+	session.Params.Language = language
+}
@@ -0,0 +1,495 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+)
+
+// clientDirectives are the directives a client may support, which can be toggled in the web
+// simulator.
+var clientDirectives = []wxas.DirectiveName{
+	wxas.DirectiveNameReply,
+	wxas.DirectiveNameLongReply,
+	wxas.DirectiveNameSpeak,
+	wxas.DirectiveNameListen,
+	wxas.DirectiveNameSleep,
+	wxas.DirectiveNameUIHint,
+	wxas.DirectiveNameASRHint,
+	wxas.DirectiveNameDisplay,
+	wxas.DirectiveNameDisplayWebView,
+	wxas.DirectiveNameClearWebView,
+	wxas.DirectiveNameAssistantEvent,
+}
+
+// webSimulator serves a browser based simulator for the session.
+type webSimulator struct {
+	url string
+
+	mu      sync.Mutex
+	session *skilltest.Session
+}
+
+// webState is the state of the session shown in the simulator.
+type webState struct {
+	URL                 string     `json:"url"`
+	Locale              string     `json:"locale"`
+	TimeZone            string     `json:"timeZone"`
+	Directives          []string   `json:"directives"`
+	SupportedDirectives []string   `json:"supportedDirectives"`
+	Listening           bool       `json:"listening"`
+	Frame               wxas.Frame `json:"frame"`
+}
+
+// webTurn is the result of a turn, with the raw request and response for the inspector.
+type webTurn struct {
+	Message     *wxas.WebexAssistantMessage  `json:"message,omitempty"`
+	Request     json.RawMessage              `json:"request,omitempty"`
+	Status      int                          `json:"status,omitempty"`
+	Body        string                       `json:"body,omitempty"`
+	Response    *wxas.WebexAssistantResponse `json:"response,omitempty"`
+	Error       string                       `json:"error,omitempty"`
+	Warnings    []string                     `json:"warnings,omitempty"`
+	Unsupported []string                     `json:"unsupported,omitempty"`
+	State       webState                     `json:"state"`
+}
+
+func newWebSimulator(url string, session *skilltest.Session) *webSimulator {
+	if session.Context.SupportedDirectives == nil {
+		for _, d := range clientDirectives {
+			session.Context.SupportedDirectives = append(session.Context.SupportedDirectives, d.String())
+		}
+	}
+	return &webSimulator{url: url, session: session}
+}
+
+func (s *webSimulator) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handlePage)
+	mux.HandleFunc("/api/state", s.handleState)
+	mux.HandleFunc("/api/say", s.handleSay)
+	mux.HandleFunc("/api/settings", s.handleSettings)
+	mux.HandleFunc("/api/reset", s.handleReset)
+	return mux
+}
+
+func (s *webSimulator) handlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	simulatorPage.Execute(w, s.url)
+}
+
+func (s *webSimulator) handleState(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeWebJSON(w, http.StatusOK, s.state())
+}
+
+func (s *webSimulator) handleSay(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Text  string `json:"text"`
+		Intro bool   `json:"intro"`
+	}
+	if !readWebJSON(w, r, &input) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx := r.Context()
+	var result *skilltest.Result
+	var err error
+	if input.Intro {
+		result, err = s.session.Intro(ctx)
+	} else {
+		result, err = s.session.Say(ctx, input.Text)
+	}
+	writeWebJSON(w, http.StatusOK, s.turn(result, err))
+}
+
+func (s *webSimulator) handleSettings(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Locale              string   `json:"locale"`
+		TimeZone            string   `json:"timeZone"`
+		SupportedDirectives []string `json:"supportedDirectives"`
+	}
+	if !readWebJSON(w, r, &input) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if input.Locale != "" {
+		setLocale(s.session, input.Locale)
+	}
+	s.session.Params.TimeZone = input.TimeZone
+	if input.SupportedDirectives != nil {
+		s.session.Context.SupportedDirectives = input.SupportedDirectives
+	}
+	writeWebJSON(w, http.StatusOK, s.state())
+}
+
+func (s *webSimulator) handleReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.session.Reset()
+	writeWebJSON(w, http.StatusOK, s.state())
+}
+
+// state returns the state of the session.  The caller must hold the lock.
+func (s *webSimulator) state() webState {
+	names := make([]string, len(clientDirectives))
+	for i, d := range clientDirectives {
+		names[i] = d.String()
+	}
+	supported := s.session.Context.SupportedDirectives
+	if supported == nil {
+		supported = []string{}
+	}
+	return webState{
+		URL:                 s.url,
+		Locale:              s.session.Params.Locale,
+		TimeZone:            s.session.Params.TimeZone,
+		Directives:          names,
+		SupportedDirectives: supported,
+		Listening:           s.session.Listening,
+		Frame:               s.session.Frame,
+	}
+}
+
+// turn returns the result of a turn for the simulator.  The caller must hold the lock.
+func (s *webSimulator) turn(r *skilltest.Result, err error) webTurn {
+	t := webTurn{State: s.state()}
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	t.Message = &r.Message
+	t.Request = r.Request
+	t.Status = r.StatusCode
+	t.Body = string(r.Body)
+	if r.StatusCode != http.StatusOK {
+		t.Error = strings.TrimSpace(t.Body)
+		return t
+	}
+	t.Response = &r.Response
+	if r.Response.Challenge != r.Challenge {
+		t.Warnings = append(t.Warnings, "the response doesn't echo the challenge, Webex Assistant would reject it")
+	}
+	supported := make(map[string]bool)
+	for _, d := range r.Message.Context.SupportedDirectives {
+		supported[d] = true
+	}
+	for _, d := range r.Response.Directives {
+		if !supported[d.Name.String()] {
+			t.Unsupported = append(t.Unsupported, d.Name.String())
+		}
+	}
+	if len(t.Unsupported) > 0 {
+		t.Warnings = append(t.Warnings, "the response contains directives the client doesn't support: "+strings.Join(t.Unsupported, ", "))
+	}
+	for _, d := range r.Response.Directives {
+		if d.Name == wxas.DirectiveNameDisplayWebView && !webURL(d.Payload.URL) {
+			t.Warnings = append(t.Warnings, "the web view URL isn't an http or https URL and won't be shown")
+		}
+	}
+	return t
+}
+
+// webURL reports whether the web view URL is an absolute http or https URL, which are the only
+// URLs the simulator shows.
+func webURL(s *string) bool {
+	if s == nil {
+		return false
+	}
+	u, err := url.Parse(*s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func readWebJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeWebJSON(w http.ResponseWriter, status int, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// runWebSimulator serves the simulator on the address until the context is done.
+func runWebSimulator(ctx context.Context, addr string, sim *webSimulator) error {
+	srv := &http.Server{Addr: addr, Handler: sim.routes()}
+	errs := make(chan error, 1)
+	go func() { errs <- srv.ListenAndServe() }()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		return srv.Shutdown(context.Background())
+	}
+}
+
+var simulatorPage = template.Must(template.New("simulator").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Skill Simulator - {{.}}</title>
+<style>
+* { box-sizing: border-box; }
+body { font-family: sans-serif; margin: 0; display: grid; grid-template-columns: 1fr 1fr; grid-template-rows: auto 1fr auto; height: 100vh; }
+header { grid-column: 1 / 3; padding: 8px 12px; background: #24292e; color: #fff; display: flex; gap: 12px; align-items: center; flex-wrap: wrap; }
+header input { width: 7em; }
+#chat { overflow-y: auto; padding: 12px; border-right: 1px solid #ddd; }
+#side { display: grid; grid-template-rows: 1fr 1fr; overflow: hidden; }
+#webview { border-bottom: 1px solid #ddd; display: flex; flex-direction: column; }
+#webview iframe { flex: 1; border: 0; width: 100%; }
+#inspector { overflow: auto; padding: 8px; font-size: 12px; }
+#inspector pre { background: #f6f8fa; padding: 6px; white-space: pre-wrap; word-break: break-all; }
+form { grid-column: 1 / 2; display: flex; gap: 6px; padding: 8px; border-top: 1px solid #ddd; border-right: 1px solid #ddd; }
+form input { flex: 1; padding: 6px; }
+.msg { margin: 6px 0; padding: 6px 10px; border-radius: 8px; max-width: 80%; }
+.user { background: #0b66c3; color: #fff; margin-left: auto; }
+.skill { background: #eef1f4; }
+.note { color: #666; font-size: 12px; }
+.warn { color: #b36b00; font-size: 12px; }
+.error { color: #c00; font-size: 12px; }
+.hint { margin: 2px 4px 2px 0; border: 1px solid #0b66c3; background: #fff; color: #0b66c3; border-radius: 12px; padding: 2px 10px; cursor: pointer; }
+.turn { cursor: pointer; }
+.bar { padding: 4px 8px; background: #f6f8fa; font-size: 12px; display: flex; justify-content: space-between; }
+details summary { cursor: pointer; }
+#caps label { margin-right: 6px; font-size: 12px; white-space: nowrap; }
+</style>
+</head>
+<body>
+<header>
+  <strong>Skill Simulator</strong><span>{{.}}</span>
+  <label>Locale <input id="locale"></label>
+  <label>Time zone <input id="timezone"></label>
+  <button id="intro">Invoke skill</button>
+  <button id="reset">End conversation</button>
+  <span id="status"></span>
+  <details><summary>Client capabilities</summary><div id="caps"></div></details>
+</header>
+<div id="chat"></div>
+<div id="side">
+  <div id="webview"><div class="bar"><span id="webtitle">No web view</span><a id="weblink" target="_blank" rel="noopener"></a></div><iframe id="webframe" sandbox="allow-scripts allow-same-origin allow-forms"></iframe></div>
+  <div id="inspector"><p class="note">Select a turn to inspect the request and response.</p></div>
+</div>
+<form id="say"><input id="text" placeholder="Say something to the skill" autocomplete="off" autofocus><button>Send</button></form>
+<script>
+"use strict";
+var chat = document.getElementById("chat");
+var inspector = document.getElementById("inspector");
+var state = null;
+
+function el(tag, cls, text) {
+  var e = document.createElement(tag);
+  if (cls) { e.className = cls; }
+  if (text !== undefined) { e.textContent = text; }
+  return e;
+}
+
+function add(e) { chat.appendChild(e); chat.scrollTop = chat.scrollHeight; return e; }
+
+function texts(d) {
+  var t = d.payload && d.payload.text;
+  if (t === undefined || t === null) { return []; }
+  return Array.isArray(t) ? t.map(String) : [String(t)];
+}
+
+function pretty(v) {
+  if (typeof v === "string") { try { v = JSON.parse(v); } catch (e) { return v; } }
+  return JSON.stringify(v, null, 2);
+}
+
+function section(title, value) {
+  var d = el("details");
+  d.open = true;
+  d.appendChild(el("summary", "", title));
+  d.appendChild(el("pre", "", pretty(value)));
+  return d;
+}
+
+function inspect(t) {
+  inspector.textContent = "";
+  if (t.message) { inspector.appendChild(section("Request (decrypted)", t.message)); }
+  if (t.request) { inspector.appendChild(section("Request (encrypted and signed)", t.request)); }
+  if (t.status) { inspector.appendChild(section("Response (" + t.status + ")", t.body)); }
+  if (t.error && !t.status) { inspector.appendChild(section("Error", t.error)); }
+}
+
+function setState(s) {
+  state = s;
+  document.getElementById("status").textContent = s.listening ? "listening" : "sleeping";
+  document.getElementById("locale").value = s.locale;
+  document.getElementById("timezone").value = s.timeZone;
+  var caps = document.getElementById("caps");
+  caps.textContent = "";
+  s.directives.forEach(function (name) {
+    var label = el("label");
+    var box = el("input");
+    box.type = "checkbox";
+    box.value = name;
+    box.checked = s.supportedDirectives.indexOf(name) >= 0;
+    box.addEventListener("change", saveSettings);
+    label.appendChild(box);
+    label.appendChild(document.createTextNode(" " + name));
+    caps.appendChild(label);
+  });
+}
+
+// webURL returns the url if it is an http or https page on another origin, since other URLs,
+// such as javascript: URLs or pages from the simulator, could run scripts in the simulator.
+function webURL(url) {
+  try {
+    var u = new URL(url);
+    if ((u.protocol === "http:" || u.protocol === "https:") && u.origin !== window.location.origin) {
+      return u.href;
+    }
+  } catch (e) {}
+  return "";
+}
+
+function webView(title, url) {
+  url = webURL(url);
+  document.getElementById("webtitle").textContent = title || (url ? "Web view" : "No web view");
+  var link = document.getElementById("weblink");
+  link.textContent = url ? "open in new tab" : "";
+  link.href = url || "#";
+  document.getElementById("webframe").src = url || "about:blank";
+}
+
+function render(t) {
+  setState(t.state);
+  var turn = add(el("div", "turn"));
+  turn.addEventListener("click", function () { inspect(t); });
+  (t.warnings || []).forEach(function (w) { turn.appendChild(el("div", "warn", "warning: " + w)); });
+  if (t.error) {
+    turn.appendChild(el("div", "error", "error: " + (t.status ? t.status + " " : "") + t.error));
+  }
+  if (t.response) {
+    (t.response.directives || []).forEach(function (d) {
+      var p = d.payload || {};
+      switch (d.name) {
+      case "reply":
+      case "long-reply":
+        texts(d).forEach(function (x) { turn.appendChild(el("div", "msg skill", x)); });
+        break;
+      case "speak":
+        turn.appendChild(el("div", "note", "speaks: " + texts(d).join(" ")));
+        break;
+      case "ui-hint":
+        var hints = el("div");
+        if (p.prompt) { hints.appendChild(el("div", "note", p.prompt)); }
+        texts(d).forEach(function (x) {
+          var b = el("button", "hint", x);
+          b.addEventListener("click", function (e) { e.stopPropagation(); say({text: x}); });
+          hints.appendChild(b);
+        });
+        turn.appendChild(hints);
+        break;
+      case "display-web-view":
+        webView(p.title, p.url);
+        turn.appendChild(el("div", "note", "web view: " + (p.title || "") + " " + (p.url || "")));
+        break;
+      case "clear-web-view":
+        webView("", "");
+        turn.appendChild(el("div", "note", "web view cleared"));
+        break;
+      case "listen":
+      case "sleep":
+        turn.appendChild(el("div", "note", d.name === "listen" ? "listening" : "sleeping, the conversation has ended"));
+        break;
+      default:
+        turn.appendChild(el("div", "note", d.name + ": " + JSON.stringify(p)));
+      }
+    });
+  }
+  chat.scrollTop = chat.scrollHeight;
+  inspect(t);
+}
+
+function post(path, body) {
+  return fetch(path, {method: "POST", headers: {"Content-Type": "application/json"}, body: JSON.stringify(body)})
+    .then(function (r) {
+      if (!r.ok) { return r.text().then(function (t) { throw new Error(t); }); }
+      return r.json();
+    });
+}
+
+function say(input) {
+  add(el("div", "msg user", input.intro ? "(invokes the skill)" : input.text));
+  return post("/api/say", input).then(render).catch(function (e) { add(el("div", "error", "error: " + e.message)); });
+}
+
+function saveSettings() {
+  var supported = [];
+  document.querySelectorAll("#caps input").forEach(function (b) { if (b.checked) { supported.push(b.value); } });
+  post("/api/settings", {
+    locale: document.getElementById("locale").value,
+    timeZone: document.getElementById("timezone").value,
+    supportedDirectives: supported
+  }).then(setState);
+}
+
+document.getElementById("say").addEventListener("submit", function (e) {
+  e.preventDefault();
+  var input = document.getElementById("text");
+  if (input.value.trim() === "") { return; }
+  say({text: input.value});
+  input.value = "";
+});
+document.getElementById("intro").addEventListener("click", function () { say({intro: true}); });
+document.getElementById("reset").addEventListener("click", function () {
+  post("/api/reset", {}).then(function (s) { setState(s); add(el("div", "note", "conversation ended")); });
+});
+document.getElementById("locale").addEventListener("change", saveSettings);
+document.getElementById("timezone").addEventListener("change", saveSettings);
+fetch("/api/state").then(function (r) { return r.json(); }).then(setState);
+</script>
+</body>
+</html>
+`))
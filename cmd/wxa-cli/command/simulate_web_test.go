@@ -0,0 +1,169 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+)
+
+// webHandler replies with what was said, shows a web view for "web <url>", listens for "listen",
+// fails for "fail" and returns the wrong challenge for "wrong challenge".
+func webHandler(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+	resp := wxas.WebexAssistantResponse{
+		Challenge: msg.Challenge,
+		Directives: []wxas.WebexAssistantDirective{
+			{Name: wxas.DirectiveNameReply, Type: wxas.DirectiveTypeView, Payload: wxas.Payload{Text: msg.Text}},
+		},
+	}
+	switch {
+	case msg.Text == "fail":
+		return wxas.WebexAssistantResponse{}, errors.New("handler failed")
+	case msg.Text == "wrong challenge":
+		resp.Challenge = "wrong"
+	case msg.Text == "listen" || msg.Params.TargetDialogueState == "skill_intro":
+		resp.Frame = wxas.Frame{"listening": true}
+		resp.Directives = append(resp.Directives, wxas.WebexAssistantDirective{Name: wxas.DirectiveNameListen, Type: wxas.DirectiveTypeAction})
+		return resp, nil
+	case strings.HasPrefix(msg.Text, "web "):
+		u := strings.TrimPrefix(msg.Text, "web ")
+		resp.Directives = append(resp.Directives, wxas.WebexAssistantDirective{Name: wxas.DirectiveNameDisplayWebView, Type: wxas.DirectiveTypeView, Payload: wxas.Payload{URL: &u}})
+	}
+	resp.Directives = append(resp.Directives, wxas.WebexAssistantDirective{Name: wxas.DirectiveNameSleep, Type: wxas.DirectiveTypeAction})
+	return resp, nil
+}
+
+func newTestWebSimulator(t *testing.T) (*webSimulator, http.Handler) {
+	t.Helper()
+	h := skilltest.New(t, wxas.HandlerFunc(webHandler))
+	sim := newWebSimulator(h.Server.URL, skilltest.NewSession(h.Client))
+	return sim, sim.routes()
+}
+
+// post sends the body to the simulator, decoding the response into v.
+func post(t *testing.T, routes http.Handler, path, body string, v interface{}) int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("invalid response %q: %s", rec.Body, err)
+		}
+	}
+	return rec.Code
+}
+
+func TestWebSimulatorSay(t *testing.T) {
+	_, routes := newTestWebSimulator(t)
+	tests := []struct {
+		name          string
+		body          string
+		wantReply     string
+		wantStatus    int
+		wantError     string
+		wantWarning   string
+		wantListening bool
+	}{
+		{name: "intro", body: `{"intro": true}`, wantStatus: 200, wantListening: true},
+		{name: "listening", body: `{"text": "listen"}`, wantReply: "listen", wantStatus: 200, wantListening: true},
+		{name: "say", body: `{"text": "hello"}`, wantReply: "hello", wantStatus: 200},
+		{name: "handler error", body: `{"text": "fail"}`, wantStatus: 500, wantError: `"error": "Internal Server Error"`},
+		{name: "wrong challenge", body: `{"text": "wrong challenge"}`, wantReply: "wrong challenge", wantStatus: 200, wantWarning: "doesn't echo the challenge"},
+		{name: "web view", body: `{"text": "web https://example.com/page"}`, wantReply: "web https://example.com/page", wantStatus: 200},
+		{name: "script web view", body: `{"text": "web javascript:alert(1)"}`, wantReply: "web javascript:alert(1)", wantStatus: 200, wantWarning: "isn't an http or https URL"},
+		{name: "relative web view", body: `{"text": "web /page"}`, wantReply: "web /page", wantStatus: 200, wantWarning: "isn't an http or https URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var turn webTurn
+			if code := post(t, routes, "/api/say", tt.body, &turn); code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if turn.Status != tt.wantStatus || !strings.Contains(turn.Error, tt.wantError) || (tt.wantError == "") != (turn.Error == "") {
+				t.Errorf("status %d error %q, want %d %q", turn.Status, turn.Error, tt.wantStatus, tt.wantError)
+			}
+			if tt.wantReply != "" {
+				if turn.Response == nil || turn.Response.Directives[0].Payload.Text != tt.wantReply {
+					t.Errorf("response = %+v, want reply %q", turn.Response, tt.wantReply)
+				}
+			}
+			if warnings := strings.Join(turn.Warnings, "\n"); !strings.Contains(warnings, tt.wantWarning) || (tt.wantWarning == "") != (warnings == "") {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarning)
+			}
+			if turn.State.Listening != tt.wantListening {
+				t.Errorf("listening = %t, want %t", turn.State.Listening, tt.wantListening)
+			}
+			if turn.Message == nil || len(turn.Request) == 0 {
+				t.Error("turn doesn't include the message and request for the inspector")
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/say", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", rec.Code)
+	}
+	if code := post(t, routes, "/api/say", "{", nil); code != http.StatusBadRequest {
+		t.Errorf("invalid json status = %d, want 400", code)
+	}
+}
+
+func TestWebSimulatorSettings(t *testing.T) {
+	sim, routes := newTestWebSimulator(t)
+	var state webState
+	if code := post(t, routes, "/api/settings", `{"locale": "fr_FR", "timeZone": "Europe/Paris", "supportedDirectives": ["reply"]}`, &state); code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if state.Locale != "fr_FR" || state.TimeZone != "Europe/Paris" || !reflect.DeepEqual(state.SupportedDirectives, []string{"reply"}) {
+		t.Errorf("state = %+v", state)
+	}
+	if sim.session.Params.Language != "fr" {
+		t.Errorf("language = %q, want fr", sim.session.Params.Language)
+	}
+	if len(state.Directives) != len(clientDirectives) {
+		t.Errorf("%d directives, want %d", len(state.Directives), len(clientDirectives))
+	}
+
+	// the directives the client doesn't support are reported with the turn
+	var turn webTurn
+	post(t, routes, "/api/say", `{"text": "hello"}`, &turn)
+	if !reflect.DeepEqual(turn.Unsupported, []string{"sleep"}) || !reflect.DeepEqual(turn.Message.Context.SupportedDirectives, []string{"reply"}) {
+		t.Errorf("unsupported = %v, supported = %v", turn.Unsupported, turn.Message.Context.SupportedDirectives)
+	}
+
+	// empty settings keep the locale and directives
+	post(t, routes, "/api/settings", `{}`, &state)
+	if state.Locale != "fr_FR" || state.TimeZone != "" || !reflect.DeepEqual(state.SupportedDirectives, []string{"reply"}) {
+		t.Errorf("state after empty settings = %+v", state)
+	}
+}
+
+func TestWebSimulatorTurnError(t *testing.T) {
+	sim, _ := newTestWebSimulator(t)
+	turn := sim.turn(nil, errors.New("connection refused"))
+	if turn.Error != "connection refused" || turn.Message != nil || turn.State.URL != sim.url {
+		t.Errorf("turn = %+v", turn)
+	}
+}
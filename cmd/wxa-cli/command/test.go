@@ -0,0 +1,168 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+	"github.com/mitchellh/cli"
+)
+
+// TestCommand provides the entry point for the command
+type TestCommand struct {
+	UI cli.Ui
+}
+
+// Help provies the help text for this command.
+func (c *TestCommand) Help() string {
+	helpText := `
+Usage: wxa-cli [global options] test [options] SCRIPT...
+
+  Run YAML conversation scripts against a running skill.  Each SCRIPT may be a
+  file or a glob pattern, e.g. "testdata/*.yaml".
+
+Options:
+  -url=URL            The URL of the skill. Default "http://localhost:8080".
+
+  -public=FILENAME    The public key for the skill. Default "public.pem".
+
+  -secret=FILENAME    The secret for the skill. Default "secret.txt".
+
+  -junit=FILENAME     Write the results as JUnit XML to the file.
+
+  -timeout=DURATION   The timeout for each turn. Default "10s".
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run provides the command functionality
+func (c *TestCommand) Run(args []string) int {
+	var url, publicFilename, secretFilename, junitFilename string
+	var timeout time.Duration
+	cmdFlags := flag.NewFlagSet("test", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+	cmdFlags.StringVar(&url, "url", "http://localhost:8080", "the url of the skill")
+	cmdFlags.StringVar(&publicFilename, "public", "public.pem", "the public key for the skill")
+	cmdFlags.StringVar(&secretFilename, "secret", "secret.txt", "the secret for the skill")
+	cmdFlags.StringVar(&junitFilename, "junit", "", "file to write junit xml to")
+	cmdFlags.DurationVar(&timeout, "timeout", 10*time.Second, "the timeout for each turn")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	var paths []string
+	for _, pattern := range cmdFlags.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("error: invalid pattern %q: %s", pattern, err))
+			return 1
+		}
+		if matches == nil {
+			c.UI.Error(fmt.Sprintf("error: no scripts match %q", pattern))
+			return 1
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		c.UI.Error("error: at least one script required")
+		return 1
+	}
+	client, err := newSkillClient(url, publicFilename, secretFilename, timeout)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	var results []*skilltest.ScriptResult
+	failed, total := 0, 0
+	for _, path := range paths {
+		s, err := skilltest.LoadScript(path)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+		r := skilltest.RunScript(context.Background(), client, s)
+		for _, conv := range r.Conversations {
+			total++
+			name := fmt.Sprintf("%s: %s (%s)", r.Name, conv.Name, conv.Duration.Round(time.Millisecond))
+			switch {
+			case conv.Error != "":
+				c.UI.Error("ERROR " + name)
+				c.UI.Output(indent(conv.Error))
+			case conv.Failure != "":
+				c.UI.Error("FAIL  " + name)
+				c.UI.Output(indent(conv.Failure))
+			default:
+				c.UI.Info("PASS  " + name)
+			}
+		}
+		failed += r.Failed()
+		results = append(results, r)
+	}
+
+	if junitFilename != "" {
+		f, err := os.Create(junitFilename)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("error creating junit file: %s", err))
+			return 1
+		}
+		defer f.Close()
+		if err := skilltest.WriteJUnit(f, results); err != nil {
+			c.UI.Error(fmt.Sprintf("error writing junit file: %s", err))
+			return 1
+		}
+	}
+	c.UI.Output(fmt.Sprintf("\n%d conversations, %d passed, %d failed", total, total-failed, failed))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Synopsis provides the one liner
+func (c *TestCommand) Synopsis() string {
+	return "Run YAML conversation scripts against a skill."
+}
+
+// newSkillClient returns a client which sends turns to the skill using the public key and
+// secret read from the files.
+func newSkillClient(url, publicFilename, secretFilename string, timeout time.Duration) (*skilltest.Client, error) {
+	publicKey, err := os.ReadFile(publicFilename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading public key: %s", err)
+	}
+	secret, err := os.ReadFile(secretFilename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secret: %s", err)
+	}
+	return &skilltest.Client{
+		URL:        url,
+		PublicKey:  string(publicKey),
+		Secret:     strings.TrimRight(string(secret), "\r\n"),
+		HTTPClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func indent(s string) string {
+	return "    " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n    ")
+}
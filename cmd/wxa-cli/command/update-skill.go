@@ -0,0 +1,116 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+	"github.com/mitchellh/cli"
+)
+
+// UpdateSkillCommand provides the entry point for the command
+type UpdateSkillCommand struct {
+	UI cli.Ui
+}
+
+// Help provies the help text for this command.
+func (c *UpdateSkillCommand) Help() string {
+	helpText := `
+Usage: wxa-cli [global options] update-skill [options]
+
+  Update a skill on the skills service, keeping its ID.  Only the fields given
+  are changed, e.g. to change the URL after restarting ngrok:
+
+    wxa-cli update-skill -id=ID -url=https://example.ngrok.io -token=TOKEN -developerid=ID
+
+Options:
+  -id=ID           The Skill ID
+  -name=NAME       The name of your skill.
+  -url=URL         The publicly accessible url for your skill.
+  -contact=EMAIL   The contact email address for the skill.
+  -public=KEY      The public key for your skill.
+  -secret=SECRET   The secret for your skill.
+` + serviceHelp
+	return strings.TrimSpace(helpText)
+}
+
+// Run provides the command functionality
+func (c *UpdateSkillCommand) Run(args []string) int {
+	var id, name, url, contact, public, secret string
+	var sf serviceFlags
+	cmdFlags := flag.NewFlagSet("updateskill", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+	cmdFlags.StringVar(&id, "id", "", "the skill id to update")
+	cmdFlags.StringVar(&name, "name", "", "the name of your skill.")
+	cmdFlags.StringVar(&url, "url", "", "the publicly accessible url for your skill.")
+	cmdFlags.StringVar(&contact, "contact", "", "the contact email address for the skill.")
+	cmdFlags.StringVar(&public, "public", "", "the public key for your skill")
+	cmdFlags.StringVar(&secret, "secret", "", "the secret for your skill")
+	sf.register(cmdFlags)
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if id == "" || sf.developerID == "" {
+		c.UI.Error("error: missing required flags")
+		return 1
+	}
+	// only send the fields which were given, so the others are left unchanged
+	var changes wxaskillsservice.Skill
+	changed := 0
+	cmdFlags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			changes.Name = wxaskillsservice.String(name)
+		case "url":
+			changes.URL = wxaskillsservice.String(url)
+		case "contact":
+			changes.ContactEmail = wxaskillsservice.String(contact)
+		case "public":
+			changes.PublicKey = wxaskillsservice.String(public)
+		case "secret":
+			changes.Secret = wxaskillsservice.String(secret)
+		default:
+			return
+		}
+		changed++
+	})
+	if changed == 0 {
+		c.UI.Error("error: at least one field to change is required")
+		return 1
+	}
+	ctx := context.Background()
+	ss, err := sf.client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	skill, err := ss.UpdateSkill(ctx, id, changes)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	c.UI.Output("Skill updated.")
+	c.UI.Output(formatSkill(skill))
+
+	return 0
+}
+
+// Synopsis provides the one liner
+func (c *UpdateSkillCommand) Synopsis() string {
+	return "Update a skill on the skills service."
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice/fake"
+	"github.com/mitchellh/cli"
+)
+
+func TestUpdateSkill(t *testing.T) {
+	srv := fake.NewServer("dev", "token")
+	defer srv.Close()
+	added := srv.AddSkill(wxaskillsservice.Skill{
+		Name:         wxaskillsservice.String("Echo"),
+		URL:          wxaskillsservice.String("https://old.example.com"),
+		ContactEmail: wxaskillsservice.String("dev@example.com"),
+	})
+	run := func(args ...string) (int, string) {
+		ui := cli.NewMockUi()
+		args = append(args, "-base-url", srv.URL, "-token", "token", "-developerid", "dev")
+		code := (&UpdateSkillCommand{UI: ui}).Run(args)
+		return code, ui.OutputWriter.String() + ui.ErrorWriter.String()
+	}
+
+	// only the URL is sent, so the other fields are left unchanged
+	code, out := run("-id", *added.SkillID, "-url", "https://new.example.com")
+	if code != 0 || !strings.Contains(out, "Skill updated.") || !strings.Contains(out, "URL:           https://new.example.com") {
+		t.Errorf("update-skill = %d:\n%s", code, out)
+	}
+	skill := srv.Skills()[0]
+	if *skill.URL != "https://new.example.com" || *skill.Name != "Echo" || *skill.ContactEmail != "dev@example.com" {
+		t.Errorf("skill = %+v", skill)
+	}
+
+	for _, tt := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "no changes", args: []string{"-id", *added.SkillID}, want: "at least one field to change is required"},
+		{name: "no id", args: []string{"-name", "Echo"}, want: "missing required flags"},
+		{name: "unknown skill", args: []string{"-id", "missing", "-name", "Echo"}, want: "skill not found"},
+	} {
+		if code, out := run(tt.args...); code != 1 || !strings.Contains(out, tt.want) {
+			t.Errorf("%s: update-skill = %d:\n%s", tt.name, code, out)
+		}
+	}
+}
+
+func TestFormatSkill(t *testing.T) {
+	skill := &wxaskillsservice.Skill{
+		SkillID:                  wxaskillsservice.String("skill-1"),
+		DeveloperID:              wxaskillsservice.String("dev"),
+		Name:                     wxaskillsservice.String("Echo"),
+		URL:                      wxaskillsservice.String("https://example.com"),
+		ContactEmail:             wxaskillsservice.String(""),
+		Languages:                []string{"en", "fr"},
+		SuggestedInvocationNames: []string{"echo"},
+		Public:                   wxaskillsservice.Bool(false),
+		CreatedAt:                wxaskillsservice.String("2021-06-01T12:00:00Z"),
+		Deleted:                  wxaskillsservice.Bool(true),
+		DeletedAt:                wxaskillsservice.String("2021-06-02T12:00:00Z"),
+		PublicKey:                wxaskillsservice.String("-----BEGIN PUBLIC KEY-----\nabc\n-----END PUBLIC KEY-----\n"),
+	}
+	want := `ID:            skill-1
+DeveloperID:   dev
+Name:          Echo
+URL:           https://example.com
+Languages:     en, fr
+Invocations:   echo
+Public:        false
+CreatedAt:     2021-06-01T12:00:00Z
+DeletedAt:     2021-06-02T12:00:00Z (SOFT DELETED)
+PublicKey:     
+-----BEGIN PUBLIC KEY-----
+abc
+-----END PUBLIC KEY-----`
+	if got := formatSkill(skill); got != want {
+		t.Errorf("formatSkill =\n%s\nwant:\n%s", got, want)
+	}
+	if got := formatSkill(&wxaskillsservice.Skill{}); got != "" {
+		t.Errorf("formatSkill of an empty skill = %q", got)
+	}
+}
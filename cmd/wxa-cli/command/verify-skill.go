@@ -0,0 +1,101 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+	"github.com/mitchellh/cli"
+)
+
+// VerifySkillCommand provides the entry point for the command
+type VerifySkillCommand struct {
+	UI cli.Ui
+}
+
+// Help provies the help text for this command.
+func (c *VerifySkillCommand) Help() string {
+	helpText := `
+Usage: wxa-cli [global options] verify-skill [options]
+
+  Check that a running skill follows the Webex Assistant protocol before
+  registering it.  The signed health check, challenge echo, rejection of invalid
+  signatures and tampered messages, content type, skill_intro handling, latency
+  and directive structure are checked.
+
+Options:
+  -url=URL                The URL of the skill. Default "http://localhost:8080".
+
+  -public=FILENAME        The public key for the skill. Default "public.pem".
+
+  -secret=FILENAME        The secret for the skill. Default "secret.txt".
+
+  -max-latency=DURATION   How long the skill may take to respond to a turn. Default "3s".
+
+  -timeout=DURATION       The timeout for each request. Default "10s".
+
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run provides the command functionality
+func (c *VerifySkillCommand) Run(args []string) int {
+	var url, publicFilename, secretFilename string
+	var maxLatency, timeout time.Duration
+	cmdFlags := flag.NewFlagSet("verifyskill", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+	cmdFlags.StringVar(&url, "url", "http://localhost:8080", "the url of the skill")
+	cmdFlags.StringVar(&publicFilename, "public", "public.pem", "the public key for the skill")
+	cmdFlags.StringVar(&secretFilename, "secret", "secret.txt", "the secret for the skill")
+	cmdFlags.DurationVar(&maxLatency, "max-latency", skilltest.DefaultMaxLatency, "how long the skill may take to respond")
+	cmdFlags.DurationVar(&timeout, "timeout", 10*time.Second, "the timeout for each request")
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	client, err := newSkillClient(url, publicFilename, secretFilename, timeout)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	c.UI.Output(fmt.Sprintf("Verifying %s\n", url))
+	failed := 0
+	checks := skilltest.Verify(context.Background(), client, maxLatency)
+	for _, check := range checks {
+		name := fmt.Sprintf("%s (%s)", check.Name, check.Duration.Round(time.Millisecond))
+		if check.Passed() {
+			c.UI.Info("PASS  " + name)
+			continue
+		}
+		failed++
+		c.UI.Error("FAIL  " + name)
+		c.UI.Output(indent(check.Failure))
+	}
+	c.UI.Output(fmt.Sprintf("\n%d checks, %d passed, %d failed", len(checks), len(checks)-failed, failed))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Synopsis provides the one liner
+func (c *VerifySkillCommand) Synopsis() string {
+	return "Check a skill follows the Webex Assistant protocol."
+}
@@ -40,6 +40,9 @@ func init() {
 		"generate-secret": func() (cli.Command, error) {
 			return &command.GenerateSecretCommand{UI: ui}, nil
 		},
+		"get-skill": func() (cli.Command, error) {
+			return &command.GetSkillCommand{UI: ui}, nil
+		},
 		"list-skills": func() (cli.Command, error) {
 			return &command.ListSkillsCommand{UI: ui}, nil
 		},
@@ -49,6 +52,27 @@ func init() {
 		"delete-skill": func() (cli.Command, error) {
 			return &command.DeleteSkillCommand{UI: ui}, nil
 		},
+		"bench": func() (cli.Command, error) {
+			return &command.BenchCommand{UI: ui}, nil
+		},
+		"record": func() (cli.Command, error) {
+			return &command.RecordCommand{UI: ui}, nil
+		},
+		"replay": func() (cli.Command, error) {
+			return &command.ReplayCommand{UI: ui}, nil
+		},
+		"simulate": func() (cli.Command, error) {
+			return &command.SimulateCommand{UI: ui}, nil
+		},
+		"test": func() (cli.Command, error) {
+			return &command.TestCommand{UI: ui}, nil
+		},
+		"update-skill": func() (cli.Command, error) {
+			return &command.UpdateSkillCommand{UI: ui}, nil
+		},
+		"verify-skill": func() (cli.Command, error) {
+			return &command.VerifySkillCommand{UI: ui}, nil
+		},
 		"version": func() (cli.Command, error) {
 			return &command.VersionCommand{
 				Version: Version,
@@ -0,0 +1,343 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config loads skill configuration into a struct from environment variables, a .env
+// file and files, reporting every missing or invalid setting at once.
+//
+// Fields are configured using struct tags:
+//
+//	env:"NAME"        the environment variable to read.
+//	default:"VALUE"   the value to use if the variable isn't set.
+//	file:"FILENAME"   a file to read the value from if the variable isn't set.
+//	required:"true"   the value must be set.
+//	secret:"true"     the value is masked in the summary.
+//
+// If NAME isn't set, NAME_FILE may be set to the path of a file containing the value, which is
+// useful for docker and kubernetes secrets.  Nested structs without an env tag are loaded
+// recursively and, if they implement Validator, validated once their fields are loaded.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/subosito/gotenv"
+)
+
+// Validator is implemented by structs which can validate their values once they are loaded.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError lists every problem found while loading the configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n  - %s", strings.Join(e.Problems, "\n  - "))
+}
+
+// Setting is the effective value of a single setting and where it came from.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+	Secret bool
+}
+
+// Loader loads configuration.  It can be created using New.
+type Loader struct {
+	// EnvFile is the path of a .env file which is read if it exists.  Environment variables take
+	// precedence over values in the file.
+	EnvFile string
+
+	// LookupEnv is used to look up environment variables.
+	LookupEnv func(key string) (string, bool)
+
+	mu       sync.Mutex
+	dotenv   map[string]string
+	files    []string
+	settings []Setting
+	problems []string
+}
+
+// New returns a loader which reads the environment and a .env file in the current directory.
+func New() *Loader {
+	return &Loader{
+		EnvFile:   ".env",
+		LookupEnv: os.LookupEnv,
+	}
+}
+
+// Load is a helper function which loads the configuration into dst using New.
+func Load(dst interface{}) (*Loader, error) {
+	l := New()
+	return l, l.Load(dst)
+}
+
+// Load loads the configuration into dst, which must be a pointer to a struct.  If any settings
+// are missing or invalid, a *ValidationError listing all of them is returned.
+func (l *Loader) Load(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config: destination must be a pointer to a struct")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.files = nil
+	l.settings = nil
+	l.problems = nil
+	if err := l.readEnvFile(); err != nil {
+		l.problems = append(l.problems, err.Error())
+	}
+	l.loadStruct(v.Elem())
+	if len(l.problems) > 0 {
+		return &ValidationError{Problems: l.problems}
+	}
+	return nil
+}
+
+// Files returns the paths of the files read by the last call to Load, including the .env file.
+func (l *Loader) Files() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	files := make([]string, len(l.files))
+	copy(files, l.files)
+	return files
+}
+
+// Settings returns the effective settings sorted by key.  Secret values are masked.
+func (l *Loader) Settings() []Setting {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := make([]Setting, len(l.settings))
+	copy(s, l.settings)
+	sort.Slice(s, func(i, j int) bool { return s[i].Key < s[j].Key })
+	return s
+}
+
+// Summary returns the effective settings, one per line, with secret values masked.
+func (l *Loader) Summary() string {
+	var sb strings.Builder
+	for _, s := range l.Settings() {
+		fmt.Fprintf(&sb, "%s=%s (%s)\n", s.Key, s.Value, s.Source)
+	}
+	return sb.String()
+}
+
+// LogValue implements the slog.LogValuer interface so the effective settings can be logged.
+func (l *Loader) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, s := range l.Settings() {
+		attrs = append(attrs, slog.String(s.Key, s.Value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (l *Loader) readEnvFile() error {
+	l.dotenv = map[string]string{}
+	if l.EnvFile == "" {
+		return nil
+	}
+	f, err := os.Open(l.EnvFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("unable to read %s: %s", l.EnvFile, err)
+	}
+	defer f.Close()
+	l.files = append(l.files, l.EnvFile)
+	env, err := gotenv.StrictParse(f)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %s", l.EnvFile, err)
+	}
+	l.dotenv = env
+	return nil
+}
+
+func (l *Loader) loadStruct(v reflect.Value) {
+	t := v.Type()
+	before := len(l.problems)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		key := f.Tag.Get("env")
+		if key == "" {
+			if f.Type.Kind() == reflect.Struct {
+				l.loadStruct(v.Field(i))
+			}
+			continue
+		}
+		l.loadField(v.Field(i), f, key)
+	}
+	if len(l.problems) > before {
+		return
+	}
+	if val, ok := v.Addr().Interface().(Validator); ok {
+		l.addValidationErrors(val.Validate())
+	}
+}
+
+func (l *Loader) addValidationErrors(err error) {
+	if err == nil {
+		return
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			l.addValidationErrors(e)
+		}
+		return
+	}
+	l.problems = append(l.problems, err.Error())
+}
+
+func (l *Loader) loadField(v reflect.Value, f reflect.StructField, key string) {
+	value, source, err := l.lookup(key, f.Tag.Get("file"))
+	if err != nil {
+		l.problems = append(l.problems, err.Error())
+		return
+	}
+	if source == "" {
+		if def, ok := f.Tag.Lookup("default"); ok {
+			value, source = def, "default"
+		}
+	}
+	if source == "" || value == "" {
+		if f.Tag.Get("required") == "true" {
+			l.problems = append(l.problems, missingMessage(key, f.Tag.Get("file")))
+			return
+		}
+	}
+	if source != "" {
+		if err := setValue(v, value); err != nil {
+			l.problems = append(l.problems, fmt.Sprintf("%s has invalid value %q: %s", key, value, err))
+			return
+		}
+	}
+	secret := f.Tag.Get("secret") == "true"
+	l.settings = append(l.settings, Setting{
+		Key:    key,
+		Value:  displayValue(value, source, secret),
+		Source: displaySource(source),
+		Secret: secret,
+	})
+}
+
+// lookup finds the value for a key, returning where it was found or an empty source if it wasn't.
+func (l *Loader) lookup(key, file string) (string, string, error) {
+	if v, ok := l.LookupEnv(key); ok {
+		return v, "env", nil
+	}
+	if v, ok := l.dotenv[key]; ok {
+		return v, l.EnvFile, nil
+	}
+	path, ok := l.LookupEnv(key + "_FILE")
+	if !ok {
+		path, ok = l.dotenv[key+"_FILE"]
+	}
+	if ok {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", fmt.Errorf("%s_FILE: unable to read %s: %s", key, path, err)
+		}
+		l.files = append(l.files, path)
+		return strings.TrimRight(string(b), "\r\n"), path, nil
+	}
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err == nil {
+			l.files = append(l.files, file)
+			return strings.TrimRight(string(b), "\r\n"), file, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", fmt.Errorf("%s: unable to read %s: %s", key, file, err)
+		}
+	}
+	return "", "", nil
+}
+
+func missingMessage(key, file string) string {
+	if file != "" {
+		return fmt.Sprintf("%s is required: set %s, %s_FILE or provide %s", key, key, key, file)
+	}
+	return fmt.Sprintf("%s is required: set %s or %s_FILE", key, key, key)
+}
+
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		v.SetInt(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func displayValue(value, source string, secret bool) string {
+	switch {
+	case source == "":
+		return "<not set>"
+	case secret && value != "":
+		return "********"
+	case strings.Contains(value, "\n"):
+		return fmt.Sprintf("<%d bytes>", len(value))
+	default:
+		return value
+	}
+}
+
+func displaySource(source string) string {
+	if source == "" {
+		return "unset"
+	}
+	return source
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type limits struct {
+	Min int `env:"MIN" default:"1"`
+	Max int `env:"MAX" default:"10"`
+}
+
+func (l *limits) Validate() error {
+	if l.Min > l.Max {
+		return errors.New("MIN must not be greater than MAX")
+	}
+	return nil
+}
+
+type testConfig struct {
+	Name    string        `env:"NAME" required:"true"`
+	Port    int           `env:"PORT" default:"4000"`
+	Token   string        `env:"TOKEN" secret:"true"`
+	Timeout time.Duration `env:"TIMEOUT" default:"5s"`
+	Debug   bool          `env:"DEBUG"`
+	Ratio   float64       `env:"RATIO"`
+	Limits  limits
+	ignored string
+}
+
+// mapEnv returns a LookupEnv function for the variables.
+func mapEnv(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defaults := testConfig{Port: 4000, Timeout: 5 * time.Second, Limits: limits{Min: 1, Max: 10}}
+	tests := []struct {
+		name         string
+		env          map[string]string
+		dotenv       string
+		want         testConfig
+		wantProblems []string
+	}{
+		{
+			name: "defaults",
+			env:  map[string]string{"NAME": "skill"},
+			want: func() testConfig { c := defaults; c.Name = "skill"; return c }(),
+		},
+		{
+			name: "all set",
+			env:  map[string]string{"NAME": "skill", "PORT": "8080", "TOKEN": "abc", "TIMEOUT": "1m", "DEBUG": "true", "RATIO": "0.5", "MIN": "2", "MAX": "3"},
+			want: testConfig{Name: "skill", Port: 8080, Token: "abc", Timeout: time.Minute, Debug: true, Ratio: 0.5, Limits: limits{Min: 2, Max: 3}},
+		},
+		{
+			name:   "dotenv",
+			env:    map[string]string{"PORT": "9000"},
+			dotenv: "NAME=dotenv\nPORT=1\n",
+			want:   func() testConfig { c := defaults; c.Name = "dotenv"; c.Port = 9000; return c }(),
+		},
+		{
+			name: "file variable",
+			env:  map[string]string{"NAME": "skill", "TOKEN_FILE": tokenFile},
+			want: func() testConfig { c := defaults; c.Name = "skill"; c.Token = "from-file"; return c }(),
+		},
+		{
+			name: "every problem reported",
+			env:  map[string]string{"PORT": "http", "TIMEOUT": "soon", "DEBUG": "maybe", "RATIO": "half", "TOKEN_FILE": filepath.Join(dir, "missing")},
+			wantProblems: []string{
+				"NAME is required: set NAME or NAME_FILE",
+				`PORT has invalid value "http": must be a whole number`,
+				"TOKEN_FILE: unable to read",
+				`TIMEOUT has invalid value "soon"`,
+				`DEBUG has invalid value "maybe": must be true or false`,
+				`RATIO has invalid value "half": must be a number`,
+			},
+		},
+		{
+			name:         "empty required value",
+			env:          map[string]string{"NAME": ""},
+			wantProblems: []string{"NAME is required"},
+		},
+		{
+			name:         "nested validation",
+			env:          map[string]string{"NAME": "skill", "MIN": "5", "MAX": "4"},
+			wantProblems: []string{"MIN must not be greater than MAX"},
+		},
+		{
+			name:         "invalid dotenv",
+			env:          map[string]string{"NAME": "skill"},
+			dotenv:       "NOT VALID\n",
+			wantProblems: []string{"unable to parse"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &Loader{EnvFile: filepath.Join(t.TempDir(), ".env"), LookupEnv: mapEnv(tt.env)}
+			if tt.dotenv != "" {
+				if err := os.WriteFile(l.EnvFile, []byte(tt.dotenv), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got testConfig
+			err := l.Load(&got)
+			if tt.wantProblems == nil {
+				if err != nil {
+					t.Fatalf("Load: %s", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Load = %+v, want %+v", got, tt.want)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Load error = %v, want a *ValidationError", err)
+			}
+			if len(verr.Problems) != len(tt.wantProblems) {
+				t.Fatalf("got problems %q, want %d", verr.Problems, len(tt.wantProblems))
+			}
+			for i, want := range tt.wantProblems {
+				if !strings.Contains(verr.Problems[i], want) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, verr.Problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadDestination(t *testing.T) {
+	var c testConfig
+	if err := New().Load(c); err == nil {
+		t.Error("Load of a struct value didn't return an error")
+	}
+}
+
+func TestSettings(t *testing.T) {
+	dir := t.TempDir()
+	l := &Loader{
+		EnvFile:   filepath.Join(dir, ".env"),
+		LookupEnv: mapEnv(map[string]string{"NAME": "skill", "TOKEN": "abc"}),
+	}
+	if err := os.WriteFile(l.EnvFile, []byte("PORT=8080\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var c testConfig
+	if err := l.Load(&c); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Setting{
+		"NAME":    {Key: "NAME", Value: "skill", Source: "env"},
+		"PORT":    {Key: "PORT", Value: "8080", Source: l.EnvFile},
+		"TOKEN":   {Key: "TOKEN", Value: "********", Source: "env", Secret: true},
+		"TIMEOUT": {Key: "TIMEOUT", Value: "5s", Source: "default"},
+		"DEBUG":   {Key: "DEBUG", Value: "<not set>", Source: "unset"},
+	}
+	settings := l.Settings()
+	for i := 1; i < len(settings); i++ {
+		if settings[i-1].Key > settings[i].Key {
+			t.Errorf("settings aren't sorted: %v", settings)
+		}
+	}
+	for _, s := range settings {
+		if w, ok := want[s.Key]; ok && s != w {
+			t.Errorf("setting %s = %+v, want %+v", s.Key, s, w)
+		}
+	}
+	if strings.Contains(l.Summary(), "abc") {
+		t.Errorf("summary contains a secret:\n%s", l.Summary())
+	}
+	if files := l.Files(); len(files) != 1 || files[0] != l.EnvFile {
+		t.Errorf("Files = %v, want [%s]", files, l.EnvFile)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Skill holds the credentials for a skill.  If the environment variables aren't set, the keys
+// and secret are read from private.pem, public.pem and secret.txt in the current directory, as
+// created by wxa-cli generate-keys and generate-secret.
+type Skill struct {
+	PrivateKey string `env:"SKILL_PRIVATE_KEY" file:"private.pem" required:"true" secret:"true"`
+	PublicKey  string `env:"SKILL_PUBLIC_KEY" file:"public.pem" required:"true"`
+	Secret     string `env:"SKILL_SECRET" file:"secret.txt" required:"true" secret:"true"`
+}
+
+// Validate checks the keys are a valid RSA key pair and the secret is usable.
+func (s *Skill) Validate() error {
+	var errs []error
+	if err := ValidateKeyPair(s.PrivateKey, s.PublicKey); err != nil {
+		errs = append(errs, err)
+	}
+	if err := ValidateSecret(s.Secret); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// ParsePrivateKey parses a PEM encoded PKCS1 RSA private key.
+func ParsePrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil || block.Type != "RSA PRIVATE KEY" {
+		return nil, errors.New("SKILL_PRIVATE_KEY is not a PEM encoded RSA PRIVATE KEY")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("SKILL_PRIVATE_KEY is invalid: %s", err)
+	}
+	return key, nil
+}
+
+// ParsePublicKey parses a PEM encoded PKIX RSA public key.
+func ParsePublicKey(publicKey string) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("SKILL_PUBLIC_KEY is not a PEM encoded PUBLIC KEY")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("SKILL_PUBLIC_KEY is invalid: %s", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("SKILL_PUBLIC_KEY is not an RSA public key")
+	}
+	return rsaKey, nil
+}
+
+// ValidateKeyPair checks that both keys parse and that the public key belongs to the private key.
+func ValidateKeyPair(privateKey, publicKey string) error {
+	private, err := ParsePrivateKey(privateKey)
+	if err != nil {
+		return err
+	}
+	public, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+	if !private.PublicKey.Equal(public) {
+		return errors.New("SKILL_PUBLIC_KEY does not match SKILL_PRIVATE_KEY")
+	}
+	return nil
+}
+
+// ValidateSecret checks the secret isn't empty and contains no whitespace, which usually means
+// it was copied incorrectly.
+func ValidateSecret(secret string) error {
+	if secret == "" {
+		return errors.New("SKILL_SECRET is empty")
+	}
+	if strings.IndexFunc(secret, unicode.IsSpace) >= 0 {
+		return errors.New("SKILL_SECRET must not contain whitespace")
+	}
+	return nil
+}
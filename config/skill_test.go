@@ -0,0 +1,95 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+// newKeyPair returns a PEM encoded RSA key pair, as created by wxa-cli generate-keys.
+func newKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return string(private), string(public)
+}
+
+func TestSkillValidate(t *testing.T) {
+	private, public := newKeyPair(t)
+	_, otherPublic := newKeyPair(t)
+	tests := []struct {
+		name  string
+		skill Skill
+		want  []string
+	}{
+		{name: "valid", skill: Skill{PrivateKey: private, PublicKey: public, Secret: "s3cret"}},
+		{name: "mismatched keys", skill: Skill{PrivateKey: private, PublicKey: otherPublic, Secret: "s3cret"}, want: []string{"does not match"}},
+		{name: "swapped keys", skill: Skill{PrivateKey: public, PublicKey: private, Secret: "s3cret"}, want: []string{"SKILL_PRIVATE_KEY is not a PEM encoded RSA PRIVATE KEY"}},
+		{name: "invalid public key", skill: Skill{PrivateKey: private, PublicKey: "public", Secret: "s3cret"}, want: []string{"SKILL_PUBLIC_KEY is not a PEM encoded PUBLIC KEY"}},
+		{name: "empty secret", skill: Skill{PrivateKey: private, PublicKey: public}, want: []string{"SKILL_SECRET is empty"}},
+		{name: "every problem", skill: Skill{PrivateKey: private, PublicKey: otherPublic, Secret: "s3cret\n"}, want: []string{"does not match", "must not contain whitespace"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.skill.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate: %s", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate didn't return an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate error %q doesn't contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadSkill(t *testing.T) {
+	private, public := newKeyPair(t)
+	var cfg struct{ Skill Skill }
+	l := &Loader{LookupEnv: mapEnv(map[string]string{
+		"SKILL_PRIVATE_KEY": private,
+		"SKILL_PUBLIC_KEY":  public,
+		"SKILL_SECRET":      "bad secret",
+	})}
+	err := l.Load(&cfg)
+	if err == nil || !strings.Contains(err.Error(), "SKILL_SECRET must not contain whitespace") {
+		t.Fatalf("Load error = %v, want the secret to be rejected", err)
+	}
+	for _, s := range l.Settings() {
+		if s.Key == "SKILL_PRIVATE_KEY" && s.Value != "********" {
+			t.Errorf("private key isn't masked: %q", s.Value)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultWatchInterval is how often a Watcher checks the files for changes.
+const DefaultWatchInterval = 5 * time.Second
+
+// Watcher calls Reload whenever one of the files the configuration was loaded from changes, or
+// the process receives SIGHUP.  The files are polled rather than relying on filesystem
+// notifications so that it works with the symlinks kubernetes swaps when a secret is updated.
+// It can be created using NewWatcher.
+type Watcher struct {
+	// Loader is the loader the configuration was loaded with.  Its Files are watched.
+	Loader *Loader
+
+	// Files, if set, is used instead of Loader to get the files to watch, e.g. host.Config.Files.
+	// It is called again after each successful reload.
+	Files func() []string
+
+	// Reload is called when a change is detected.  It should load and validate the new
+	// configuration and only apply it if it is valid, returning an error otherwise.  Errors are
+	// logged and the watcher carries on, so the current configuration is kept until the files are
+	// fixed.
+	Reload func() error
+
+	// Interval is how often the files are checked.  If zero, DefaultWatchInterval is used.
+	Interval time.Duration
+
+	// Logger is used to log reloads.  If nil, slog.Default() is used.
+	Logger *slog.Logger
+}
+
+// NewWatcher is a helper function that returns a new watcher for the files read by loader.
+func NewWatcher(loader *Loader, reload func() error) *Watcher {
+	return &Watcher{
+		Loader:   loader,
+		Reload:   reload,
+		Interval: DefaultWatchInterval,
+	}
+}
+
+// Run watches for changes until ctx is cancelled.
+func (w *Watcher) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	files := w.files()
+	sums := checksums(files)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			w.logger().Info("caught signal, reloading configuration", "signal", "SIGHUP")
+		case <-ticker.C:
+			current := checksums(files)
+			if equal(sums, current) {
+				continue
+			}
+			w.logger().Info("configuration files changed, reloading configuration")
+		}
+		err := w.Reload()
+		if err != nil {
+			w.logger().Error("unable to reload configuration, keeping current configuration", "error", err)
+		} else {
+			w.logger().Info("reloaded configuration")
+			files = w.files()
+		}
+		// record the files as they are now so a broken file is only reported once
+		sums = checksums(files)
+	}
+}
+
+func (w *Watcher) files() []string {
+	if w.Files != nil {
+		return w.Files()
+	}
+	return w.Loader.Files()
+}
+
+func (w *Watcher) logger() *slog.Logger {
+	if w.Logger != nil {
+		return w.Logger
+	}
+	return slog.Default()
+}
+
+// checksums returns the checksum of each file, or an empty string if it can't be read.
+func checksums(files []string) map[string]string {
+	sums := make(map[string]string, len(files))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			sums[f] = ""
+			continue
+		}
+		sum := sha256.Sum256(b)
+		sums[f] = string(sum[:])
+	}
+	return sums
+}
+
+func equal(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type greeting struct {
+	Text string `env:"GREETING" file:"greeting.txt" required:"true"`
+}
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "greeting.txt")
+	write := func(s string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("hello")
+	l := &Loader{LookupEnv: mapEnv(map[string]string{"GREETING_FILE": path})}
+	var current greeting
+	if err := l.Load(&current); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	reloads := make(chan error, 10)
+	w := NewWatcher(l, func() error {
+		var g greeting
+		err := l.Load(&g)
+		if err == nil {
+			mu.Lock()
+			current = g
+			mu.Unlock()
+		}
+		reloads <- err
+		return err
+	})
+	w.Interval = 10 * time.Millisecond
+	w.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	wait := func(wantErr bool) {
+		t.Helper()
+		select {
+		case err := <-reloads:
+			if (err != nil) != wantErr {
+				t.Fatalf("reload error = %v, want error %t", err, wantErr)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("configuration wasn't reloaded")
+		}
+	}
+	text := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return current.Text
+	}
+
+	// give the watcher time to checksum the file before changing it
+	time.Sleep(50 * time.Millisecond)
+	write("bonjour")
+	wait(false)
+	if got := text(); got != "bonjour" {
+		t.Errorf("after change greeting = %q, want bonjour", got)
+	}
+
+	// an invalid file is only reported once and the current configuration is kept
+	write("")
+	wait(true)
+	select {
+	case err := <-reloads:
+		t.Fatalf("unchanged broken file was reloaded again: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := text(); got != "bonjour" {
+		t.Errorf("after invalid change greeting = %q, want bonjour", got)
+	}
+
+	write("hola")
+	wait(false)
+	if got := text(); got != "hola" {
+		t.Errorf("after fix greeting = %q, want hola", got)
+	}
+
+	// the watcher is running, so SIGHUP is being caught
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	wait(false)
+}
+
+func TestWatcherFiles(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	for _, f := range []string{first, second} {
+		if err := os.WriteFile(f, []byte("v1"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var mu sync.Mutex
+	files := []string{first}
+	reloads := make(chan struct{}, 10)
+	w := &Watcher{
+		Files: func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), files...)
+		},
+		Reload: func() error {
+			// the reloaded configuration refers to another file
+			mu.Lock()
+			files = []string{second}
+			mu.Unlock()
+			reloads <- struct{}{}
+			return nil
+		},
+		Interval: 10 * time.Millisecond,
+		Logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// give the watcher time to checksum the files before changing them
+	time.Sleep(50 * time.Millisecond)
+	for i, f := range []string{first, second} {
+		if err := os.WriteFile(f, []byte("v2"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-reloads:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("change %d to %s wasn't detected", i, filepath.Base(f))
+		}
+	}
+}
+
+func TestChecksums(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	missing := checksums([]string{path})
+	if missing[path] != "" {
+		t.Error("missing file has a checksum")
+	}
+	if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	present := checksums([]string{path})
+	if equal(missing, present) || !equal(present, checksums([]string{path})) {
+		t.Error("checksums don't reflect the file contents")
+	}
+	if equal(present, map[string]string{}) {
+		t.Error("maps of different lengths are equal")
+	}
+}
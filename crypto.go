@@ -0,0 +1,129 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fernet/fernet-go"
+)
+
+// VerifySignature checks the inbound signature is the HMAC SHA256 of the payload using the skill secret.
+func VerifySignature(secret string, payload string, inboundSignature []byte) bool {
+	signature := generateSignature(secret, payload)
+	return subtle.ConstantTimeCompare(signature, inboundSignature) == 1
+}
+
+func generateSignature(secret string, payload string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// SignMessage returns the base64 encoded HMAC SHA256 signature of the payload using the skill
+// secret, as sent by Webex Assistant.  It is the counterpart to VerifySignature and is useful
+// for testing skills.
+func SignMessage(secret string, payload string) string {
+	return base64.StdEncoding.EncodeToString(generateSignature(secret, payload))
+}
+
+// EncryptMessage encrypts a message using the skill public key in the same way as Webex
+// Assistant.  It is the counterpart to DecryptMessage and is useful for testing skills.
+func EncryptMessage(publicKey, message string) (string, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return "", errors.New("error decoding public key from pem")
+	}
+	parsedKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("error parsing public key: %s", err)
+	}
+	rsaKey, ok := parsedKey.(*rsa.PublicKey)
+	if !ok {
+		return "", errors.New("public key is not an RSA key")
+	}
+	var fernetKey fernet.Key
+	if err := fernetKey.Generate(); err != nil {
+		return "", fmt.Errorf("error generating fernet key: %s", err)
+	}
+	token, err := fernet.EncryptAndSign([]byte(message), &fernetKey)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting message: %s", err)
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, rsaKey, []byte(fernetKey.Encode()), nil)
+	if err != nil {
+		return "", fmt.Errorf("error encrypting fernet key: %s", err)
+	}
+	return base64.StdEncoding.EncodeToString(encryptedKey) + "." + base64.StdEncoding.EncodeToString(token), nil
+}
+
+// DecryptMessage decrypts a message received from Webex Assistant using the skill private key.
+// The message is made up of an RSA encrypted fernet key and a fernet token, each base64 encoded
+// and separated by a ".".  If the message isn't in that format, the error wraps
+// ErrMalformedToken, and if it can't be decrypted with the key, the error wraps ErrDecrypt.
+func DecryptMessage(privateKey, message string) (string, error) {
+	encryptedFernetKey, fernetToken, ok := strings.Cut(message, ".")
+	if !ok || encryptedFernetKey == "" || fernetToken == "" || strings.Contains(fernetToken, ".") {
+		return "", fmt.Errorf("%w: expected two parts separated by a \".\"", ErrMalformedToken)
+	}
+	decodedFernetKey, err := base64.StdEncoding.DecodeString(encryptedFernetKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: error decoding key: %s", ErrMalformedToken, err)
+	}
+	decodedFernetToken, err := base64.StdEncoding.DecodeString(fernetToken)
+	if err != nil {
+		return "", fmt.Errorf("%w: error decoding token: %s", ErrMalformedToken, err)
+	}
+	parsedKey, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	fernetKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, parsedKey, decodedFernetKey, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: error decrypting fernet key: %s", ErrDecrypt, err)
+	}
+	key, err := fernet.DecodeKey(string(fernetKey))
+	if err != nil {
+		return "", fmt.Errorf("%w: error decoding fernet key: %s", ErrDecrypt, err)
+	}
+	payload := fernet.VerifyAndDecrypt(decodedFernetToken, 0, []*fernet.Key{key})
+	if payload == nil {
+		return "", fmt.Errorf("%w: invalid fernet token", ErrDecrypt)
+	}
+	return string(payload), nil
+}
+
+// parsePrivateKey parses a PEM encoded PKCS1 RSA private key.
+func parsePrivateKey(privateKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil || block.Type != "RSA PRIVATE KEY" {
+		return nil, errors.New("error decoding private key from pem")
+	}
+	parsedKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %s", err)
+	}
+	return parsedKey, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"testing"
+)
+
+var (
+	testKeysOnce   sync.Once
+	testPrivateKey string
+	testPublicKey  string
+	testKeysErr    error
+)
+
+// testKeys returns a PEM encoded key pair, generated once since generating RSA keys is slow.
+func testKeys(tb testing.TB) (privateKey, publicKey string) {
+	tb.Helper()
+	testKeysOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			testKeysErr = err
+			return
+		}
+		publicKeyBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			testKeysErr = err
+			return
+		}
+		testPrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+		testPublicKey = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes}))
+	})
+	if testKeysErr != nil {
+		tb.Fatal(testKeysErr)
+	}
+	return testPrivateKey, testPublicKey
+}
+
+func TestDecryptMessage(t *testing.T) {
+	privateKey, publicKey := testKeys(t)
+	token, err := EncryptMessage(publicKey, `{"text":"hello"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr error
+	}{
+		{name: "valid", token: token, want: `{"text":"hello"}`},
+		{name: "no separator", token: "abc", wantErr: ErrMalformedToken},
+		{name: "too many parts", token: token + ".abc", wantErr: ErrMalformedToken},
+		{name: "invalid base64", token: "!!!.???", wantErr: ErrMalformedToken},
+		{name: "wrong key", token: "AAAA" + token[4:], wantErr: ErrDecrypt},
+		{name: "truncated token", token: token[:len(token)-8], wantErr: ErrDecrypt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecryptMessage(privateKey, tt.token)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DecryptMessage = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// FuzzDecryptMessage checks that no token causes a panic, that every error is a malformed
+// token or decryption error, and that only the valid token decrypts.  Malformed tokens are in
+// testdata/fuzz/FuzzDecryptMessage.
+func FuzzDecryptMessage(f *testing.F) {
+	privateKey, publicKey := testKeys(f)
+	valid, err := EncryptMessage(publicKey, `{"text":"hello"}`)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(valid)
+	f.Add(valid[:len(valid)/2])
+	f.Fuzz(func(t *testing.T, token string) {
+		got, err := DecryptMessage(privateKey, token)
+		if err != nil {
+			if !errors.Is(err, ErrMalformedToken) && !errors.Is(err, ErrDecrypt) {
+				t.Errorf("unexpected error for %q: %s", token, err)
+			}
+			return
+		}
+		if got != `{"text":"hello"}` {
+			t.Errorf("%q decrypted to %q", token, got)
+		}
+	})
+}
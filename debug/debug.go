@@ -0,0 +1,187 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package debug keeps the most recent turns in memory and serves them on an authenticated
+// endpoint, typically /debug/turns, to help diagnose what a skill received and sent in staging.
+//
+// The records contain decrypted messages, redacted using the skill's policy, so the endpoint
+// should only be enabled where that is acceptable.
+package debug
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// DefaultSize is the number of turns kept by a Recorder created with a size of zero.
+const DefaultSize = 100
+
+// Recorder is a wxas.TurnRecorder that keeps the most recent turns in a ring buffer.  It can be
+// created using NewRecorder.
+type Recorder struct {
+	mu    sync.Mutex
+	turns []wxas.TurnRecord
+	next  int
+	full  bool
+}
+
+// NewRecorder returns a recorder that keeps the given number of turns.  If size is zero or
+// less, DefaultSize is used.
+func NewRecorder(size int) *Recorder {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Recorder{turns: make([]wxas.TurnRecord, size)}
+}
+
+// RecordTurn implements the wxas.TurnRecorder interface, replacing the oldest turn once the
+// recorder is full.
+func (r *Recorder) RecordTurn(t wxas.TurnRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.turns[r.next] = t
+	r.next = (r.next + 1) % len(r.turns)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Turns returns the recorded turns, most recent first.
+func (r *Recorder) Turns() []wxas.TurnRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.turns)
+	}
+	turns := make([]wxas.TurnRecord, 0, n)
+	for i := 1; i <= n; i++ {
+		turns = append(turns, r.turns[(r.next-i+len(r.turns))%len(r.turns)])
+	}
+	return turns
+}
+
+// Handler returns an http.Handler which serves the recorded turns.  Requests must present the
+// token, either as a bearer token or as the password for basic authentication so the turns can
+// be viewed in a browser.  If the token is empty, every request is refused.
+//
+// The turns are returned as JSON, or as an HTML page if the client accepts text/html.  They can
+// be filtered using the skill, outcome and id query parameters and limited using limit.
+func (r *Recorder) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !authorized(req, token) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="debug"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		turns := filter(r.Turns(), req)
+		if strings.Contains(req.Header.Get("Accept"), "text/html") {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if err := page.Execute(w, turns); err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+			return
+		}
+		js, err := json.MarshalIndent(map[string]interface{}{"turns": turns}, "", "\t")
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(append(js, '\n'))
+	})
+}
+
+func authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+	var presented string
+	if _, password, ok := r.BasicAuth(); ok {
+		presented = password
+	} else if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		presented = bearer
+	} else {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+func filter(turns []wxas.TurnRecord, r *http.Request) []wxas.TurnRecord {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	filtered := make([]wxas.TurnRecord, 0, len(turns))
+	for _, t := range turns {
+		if limit > 0 && len(filtered) == limit {
+			break
+		}
+		if v := q.Get("skill"); v != "" && t.Skill != v {
+			continue
+		}
+		if v := q.Get("outcome"); v != "" && t.Outcome != v {
+			continue
+		}
+		if v := q.Get("id"); v != "" && t.ID != v {
+			continue
+		}
+		filtered = append(filtered, t)
+	}
+	return filtered
+}
+
+var page = template.Must(template.New("turns").Funcs(template.FuncMap{
+	"json": func(v interface{}) string {
+		js, _ := json.MarshalIndent(v, "", "  ")
+		return string(js)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Recent turns</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.4em; text-align: left; vertical-align: top; }
+pre { margin: 0; white-space: pre-wrap; font-size: 0.85em; }
+.error { color: #b00; }
+</style>
+</head>
+<body>
+<h1>Recent turns</h1>
+<table>
+<tr><th>Time</th><th>Skill</th><th>Turn</th><th>Outcome</th><th>Intent</th><th>Latency</th><th>Message</th><th>Response</th></tr>
+{{range .}}<tr>
+<td>{{.Time.Format "2006-01-02 15:04:05.000"}}</td>
+<td>{{.Skill}}</td>
+<td>{{.ID}}</td>
+<td>{{.Outcome}}{{if .Error}}<div class="error">{{.Error}}</div>{{end}}</td>
+<td>{{.Intent}}{{if .DialogueState}} ({{.DialogueState}}){{end}}{{if .Fallback}} fallback{{end}}{{if .NLU}}<pre>{{json .NLU}}</pre>{{end}}</td>
+<td>{{.Latency}}</td>
+<td>{{if .Message}}<pre>{{json .Message}}</pre>{{end}}</td>
+<td>{{if .Response}}<pre>{{json .Response}}</pre>{{end}}</td>
+</tr>
+{{else}}<tr><td colspan="8">No turns recorded yet.</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
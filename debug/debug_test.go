@@ -0,0 +1,139 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package debug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		basic  string
+		want   bool
+	}{
+		{name: "bearer", token: "secret", header: "Bearer secret", want: true},
+		{name: "basic", token: "secret", basic: "secret", want: true},
+		{name: "bare token", token: "secret", header: "secret", want: false},
+		{name: "wrong scheme", token: "secret", header: "Token secret", want: false},
+		{name: "wrong bearer", token: "secret", header: "Bearer guess", want: false},
+		{name: "wrong password", token: "secret", basic: "guess", want: false},
+		{name: "missing", token: "secret", want: false},
+		{name: "no token configured", token: "", header: "Bearer ", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/debug/turns", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.basic != "" {
+				r.SetBasicAuth("debug", tt.basic)
+			}
+			if got := authorized(r, tt.token); got != tt.want {
+				t.Errorf("authorized = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	r := NewRecorder(3)
+	if got := r.Turns(); len(got) != 0 {
+		t.Errorf("new recorder has %d turns", len(got))
+	}
+	for i := 1; i <= 5; i++ {
+		r.RecordTurn(wxas.TurnRecord{ID: fmt.Sprint(i)})
+	}
+	var ids []string
+	for _, turn := range r.Turns() {
+		ids = append(ids, turn.ID)
+	}
+	if got := strings.Join(ids, ","); got != "5,4,3" {
+		t.Errorf("turns = %s, want the most recent first: 5,4,3", got)
+	}
+	if got := len(NewRecorder(0).turns); got != DefaultSize {
+		t.Errorf("default size = %d, want %d", got, DefaultSize)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRecorder(10)
+	r.RecordTurn(wxas.TurnRecord{ID: "1", Skill: "echo", Outcome: wxas.OutcomeOK})
+	r.RecordTurn(wxas.TurnRecord{ID: "2", Skill: "weather", Outcome: wxas.OutcomeHandlerError})
+	r.RecordTurn(wxas.TurnRecord{ID: "3", Skill: "echo", Outcome: wxas.OutcomeHandlerError, Intent: "<script>"})
+	h := r.Handler("secret")
+
+	tests := []struct {
+		query   string
+		wantIDs string
+	}{
+		{query: "", wantIDs: "3,2,1"},
+		{query: "?skill=echo", wantIDs: "3,1"},
+		{query: "?outcome=handler_error", wantIDs: "3,2"},
+		{query: "?skill=echo&outcome=ok", wantIDs: "1"},
+		{query: "?id=2", wantIDs: "2"},
+		{query: "?limit=2", wantIDs: "3,2"},
+		{query: "?skill=missing", wantIDs: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/debug/turns"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+				t.Fatalf("got %d with Cache-Control %q", rec.Code, rec.Header().Get("Cache-Control"))
+			}
+			var body struct{ Turns []wxas.TurnRecord }
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, turn := range body.Turns {
+				ids = append(ids, turn.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.wantIDs {
+				t.Errorf("turns = %q, want %q", got, tt.wantIDs)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/turns", nil)
+	req.Header.Set("Authorization", "secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("bare token got %d, want 401 with a challenge", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/debug/turns", nil)
+	req.SetBasicAuth("", "secret")
+	req.Header.Set("Accept", "text/html")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") || strings.Contains(rec.Body.String(), "<script>") {
+		t.Errorf("html page has content type %q and unescaped content: %t", rec.Header().Get("Content-Type"), strings.Contains(rec.Body.String(), "<script>"))
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Err implements the error interface so we can have constant errors.
+type Err string
+
+func (e Err) Error() string {
+	return string(e)
+}
+
+// Error Constants.  Errors returned while reading a request from Webex Assistant wrap one of
+// these, so they can be checked using errors.Is and mapped to a status using StatusCode.
+const (
+	ErrMalformedToken = Err("wxas: malformed token")
+	ErrBadSignature   = Err("wxas: invalid signature")
+	ErrDecrypt        = Err("wxas: unable to decrypt message")
+	ErrPayloadJSON    = Err("wxas: invalid message json")
+	ErrStale          = Err("wxas: stale message")
+)
+
+// StatusCode returns the HTTP status code the skill responds with for the error.  Errors that
+// aren't caused by the request, such as an invalid private key, are internal server errors.
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrBadSignature), errors.Is(err, ErrStale):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrMalformedToken), errors.Is(err, ErrDecrypt), errors.Is(err, ErrPayloadJSON):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ParseMessage unmarshals a decrypted message.  If it isn't a valid message, the error wraps
+// ErrPayloadJSON.
+func ParseMessage(decrypted string) (WebexAssistantMessage, error) {
+	var wam WebexAssistantMessage
+	if err := json.Unmarshal([]byte(decrypted), &wam); err != nil {
+		return WebexAssistantMessage{}, fmt.Errorf("%w: %s", ErrPayloadJSON, err)
+	}
+	return wam, nil
+}
+
+// checkFresh returns an error wrapping ErrStale if the message timestamp, in seconds, is more
+// than maxAge from now.  Messages without a timestamp are allowed, as are all messages if maxAge
+// is zero.
+func checkFresh(wam WebexAssistantMessage, maxAge time.Duration, now time.Time) error {
+	if maxAge <= 0 || wam.Params.Timestamp == 0 {
+		return nil
+	}
+	age := now.Sub(time.Unix(wam.Params.Timestamp, 0))
+	if age > maxAge || age < -maxAge {
+		return fmt.Errorf("%w: timestamp is %s from now", ErrStale, age.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		err         error
+		wantStatus  int
+		wantMessage string
+	}{
+		{err: fmt.Errorf("%w: bad padding", ErrMalformedToken), wantStatus: http.StatusBadRequest, wantMessage: "malformed token"},
+		{err: ErrBadSignature, wantStatus: http.StatusUnauthorized, wantMessage: "invalid signature"},
+		{err: fmt.Errorf("%w: wrong key", ErrDecrypt), wantStatus: http.StatusBadRequest, wantMessage: "unable to decrypt message"},
+		{err: fmt.Errorf("%w: unexpected EOF", ErrPayloadJSON), wantStatus: http.StatusBadRequest, wantMessage: "invalid message json"},
+		{err: fmt.Errorf("%w: timestamp is 1h0m0s from now", ErrStale), wantStatus: http.StatusUnauthorized, wantMessage: "stale message"},
+		{err: errors.New("invalid private key"), wantStatus: http.StatusInternalServerError, wantMessage: "Internal Server Error"},
+	}
+	s := &Skill{}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if got := StatusCode(tt.err); got != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", got, tt.wantStatus)
+			}
+			rec := httptest.NewRecorder()
+			s.wireError(rec, httptest.NewRequest(http.MethodPost, "/", nil), tt.err)
+			var body struct{ Error string }
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid body %q: %s", rec.Body, err)
+			}
+			if rec.Code != tt.wantStatus || body.Error != tt.wantMessage {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, body.Error, tt.wantStatus, tt.wantMessage)
+			}
+		})
+	}
+	if got := StatusCode(nil); got != http.StatusOK {
+		t.Errorf("StatusCode(nil) = %d, want 200", got)
+	}
+}
+
+func TestCheckFresh(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		maxAge    time.Duration
+		timestamp int64
+		wantErr   bool
+	}{
+		{name: "fresh", maxAge: time.Minute, timestamp: now.Add(-30 * time.Second).Unix()},
+		{name: "stale", maxAge: time.Minute, timestamp: now.Add(-2 * time.Minute).Unix(), wantErr: true},
+		{name: "too far in the future", maxAge: time.Minute, timestamp: now.Add(2 * time.Minute).Unix(), wantErr: true},
+		{name: "no timestamp", maxAge: time.Minute},
+		{name: "disabled", timestamp: now.Add(-time.Hour).Unix()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkFresh(WebexAssistantMessage{Params: Params{Timestamp: tt.timestamp}}, tt.maxAge, now)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrStale)) {
+				t.Errorf("checkFresh = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSkillErrors checks the status and JSON body of turns that are rejected or fail.
+func TestSkillErrors(t *testing.T) {
+	privateKey, publicKey := testKeys(t)
+	skill := testSkill(t, privateKey, HandlerFunc(func(ctx context.Context, msg WebexAssistantMessage) (WebexAssistantResponse, error) {
+		if msg.Text == "fail" {
+			return WebexAssistantResponse{}, errors.New("database password is hunter2")
+		}
+		return textResponse(msg.Text, msg.Challenge), nil
+	}))
+	skill.MaxAge = time.Minute
+	tests := []struct {
+		name        string
+		secret      string
+		message     string
+		wantStatus  int
+		wantMessage string
+	}{
+		{name: "stale", secret: "secret", message: fmt.Sprintf(`{"text":"hello","params":{"timestamp":%d}}`, time.Now().Add(-time.Hour).Unix()),
+			wantStatus: http.StatusUnauthorized, wantMessage: "stale message"},
+		{name: "bad signature", secret: "wrong", message: `{"text":"hello"}`, wantStatus: http.StatusUnauthorized, wantMessage: "invalid signature"},
+		{name: "invalid json", secret: "secret", message: `{"text":1}`, wantStatus: http.StatusBadRequest, wantMessage: "invalid message json"},
+		{name: "handler error", secret: "secret", message: `{"text":"fail"}`, wantStatus: http.StatusInternalServerError, wantMessage: "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			skill.ServeHTTP(rec, signedRequest(t, publicKey, tt.secret, tt.message))
+			var body struct{ Error string }
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid body %q: %s", rec.Body, err)
+			}
+			if rec.Code != tt.wantStatus || body.Error != tt.wantMessage {
+				t.Errorf("response = %d %q, want %d %q", rec.Code, body.Error, tt.wantStatus, tt.wantMessage)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("content type = %q", ct)
+			}
+		})
+	}
+}
@@ -1,133 +1,55 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
 	"net/http"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/lexruntimeservice"
 	owm "github.com/briandowns/openweathermap"
 	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+var tracer = otel.Tracer("github.com/darrenparkinson/wxa-skills-go/examples/basic-lex-skill")
+
 func ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
-//TODO: Validate that this works ok.
-func (app *application) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	encodedSignature := r.URL.Query().Get("signature")
-	encodedCipher := r.URL.Query().Get("challenge")
-	if encodedSignature == "" {
-		app.errorResponse(w, r, http.StatusBadRequest, "missing signature")
-		return
-	}
-	if encodedCipher == "" {
-		app.errorResponse(w, r, http.StatusBadRequest, "missing message")
-		return
-	}
-	decodedSignature, err := base64.StdEncoding.DecodeString(encodedSignature)
-	if err != nil {
-		app.errorLog.Println("error decoding signature:", err.Error())
-		app.errorResponse(w, r, http.StatusBadRequest, "error decoding signature")
-		return
-	}
-	if !verifySignature(app.config.Skill.Secret, encodedCipher, decodedSignature) {
-		app.errorLog.Println("message has invalid signature and will not be processed")
-		app.invalidSignatureResponse(w, r)
-		return
-	}
-	decryptedChallenge, err := decryptMessage(app.config.Skill.PrivateKey, encodedCipher)
-	if err != nil {
-		app.errorLog.Println("message has invalid signature and will not be processed", err)
-		app.errorResponse(w, r, http.StatusBadRequest, "unable to decrypt message")
-		return
-	}
-	whr := wxas.WebexAssistantHealthResponse{
-		Challenge: decryptedChallenge,
-		Status:    "OK",
-	}
-	renderJSON(w, whr)
-}
-
-func (app *application) handleSkills(w http.ResponseWriter, r *http.Request) {
-
-	var wr wxas.WebexAssistantRequest
-	err := decodeJSONBody(w, r, &wr)
-	if err != nil {
-		var mr *malformedRequest
-		if errors.As(err, &mr) {
-			app.errorLog.Println(err.Error())
-			app.errorResponse(w, r, mr.status, mr.msg)
-		} else {
-			app.errorLog.Println(err.Error())
-			app.invalidRequestResponse(w, r)
-		}
-		return
-	}
-
-	if wr.Signature == "" || wr.Message == "" {
-		app.invalidRequestResponse(w, r)
-		return
-	}
-
-	decodedSignature, err := base64.StdEncoding.DecodeString(wr.Signature)
-	if err != nil {
-		app.errorLog.Println("error decoding signature:", err.Error())
-		app.errorResponse(w, r, http.StatusBadRequest, "error decoding signature")
-		return
-	}
-	if !verifySignature(app.config.Skill.Secret, wr.Message, decodedSignature) {
-		app.errorLog.Println("message has invalid signature and will not be processed")
-		app.invalidSignatureResponse(w, r)
-		return
-	}
-
-	decryptedMessage, err := decryptMessage(app.config.Skill.PrivateKey, wr.Message)
-	if err != nil {
-		app.errorLog.Println("unable to decrypt message:", err)
-		app.errorResponse(w, r, http.StatusBadRequest, "unable to decrypt message")
-		return
-	}
-	var wam wxas.WebexAssistantMessage
-	err = json.Unmarshal([]byte(decryptedMessage), &wam)
-	if err != nil {
-		app.errorLog.Println("error unmarshalling message", err)
-		app.errorResponse(w, r, http.StatusBadRequest, "unable to unmarshal message")
-	}
-
-	// Now process the message
-	fmt.Printf("%+v\n", wam)
-	resp, err := app.buildResponse(wam)
-	if err != nil {
-		app.errorLog.Println(err.Error())
-		app.serverError(w, err)
-	}
-	renderJSON(w, resp)
+func (app *application) handleMessage(ctx context.Context, wam wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+	wxas.LoggerFromContext(ctx).Debug("received message", "text", wam.Text, "params", wam.Params)
+	return app.buildResponse(ctx, wam)
 }
 
-func (app *application) buildResponse(wam wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+func (app *application) buildResponse(ctx context.Context, wam wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
 	var wr wxas.WebexAssistantResponse
+	turn := wxas.TurnFromContext(ctx)
+	logger := wxas.LoggerFromContext(ctx)
 	if wam.Params.TargetDialogueState == "skill_intro" {
+		turn.Intent = "skill_intro"
 		return buildSkillIntroResponse(wam), nil
 	}
-	lr, err := app.lex.PostText(&lexruntimeservice.PostTextInput{
-		BotAlias:  &app.config.Lex.Alias,
-		BotName:   &app.config.Lex.BotName,
-		InputText: &wam.Text,
-		UserId:    wam.Context.UserID,
-	})
+	lr, err := app.parseText(ctx, wam)
 	if err != nil {
-		app.errorLog.Printf("error communicating with lex: %s", err)
+		logger.Error("error communicating with lex", "error", err)
 		return wr, err
 	}
 	city, text := "", ""
 	listenOrSleep := wxas.DirectiveNameSleep
 	if lr.DialogState != nil && lr.IntentName != nil {
+		turn.Intent = *lr.IntentName
+		turn.DialogueState = *lr.DialogState
+		turn.NLU = map[string]string{}
+		for slot, value := range lr.Slots {
+			turn.NLU["slot."+slot] = aws.StringValue(value)
+		}
 		if *lr.IntentName != "CityWeather" {
 			text = "That isn't a skill I have just yet."
+			turn.Fallback = true
 		} else {
 			switch *lr.DialogState {
 			case "ElicitSlot":
@@ -135,23 +57,22 @@ func (app *application) buildResponse(wam wxas.WebexAssistantMessage) (wxas.Webe
 				listenOrSleep = wxas.DirectiveNameListen
 			case "ReadyForFulfillment":
 				city = *lr.Slots["city"]
-				w, err := owm.NewCurrent("C", "en", app.config.OpenWeatherMap.APIKey)
+				text, err = app.currentWeather(ctx, city)
 				if err != nil {
-					app.errorLog.Println("error retrieving weather information")
+					logger.Error("error retrieving weather information", "error", err)
 					text = "Sorry, there was an error retrieving weather information."
 					break
 				}
-				w.CurrentByName(city)
-				text = fmt.Sprintf("The current weather in %s shows %s, with a low of %2.0f and a high of %2.0f.", w.Name, w.Weather[0].Description, w.Main.TempMin, w.Main.TempMax)
 				listenOrSleep = wxas.DirectiveNameSleep
 			}
 		}
 	}
 	if text == "" {
 		text = "Sorry, I have nothing to say to that."
+		turn.Fallback = true
 	}
 
-	fmt.Printf("%+v\n", lr)
+	logger.Debug("lex response", "intent", turn.Intent, "dialog_state", turn.DialogueState, "text", aws.StringValue(lr.Message))
 	wr.Directives = []wxas.WebexAssistantDirective{
 		{Name: wxas.DirectiveNameReply, Type: wxas.DirectiveTypeView, Payload: wxas.Payload{Text: &text}},
 		{Name: wxas.DirectiveNameSpeak, Type: wxas.DirectiveTypeAction, Payload: wxas.Payload{Text: &text}},
@@ -161,6 +82,56 @@ func (app *application) buildResponse(wam wxas.WebexAssistantMessage) (wxas.Webe
 	return wr, nil
 }
 
+// parseText sends the text to lex to identify the intent and any slots.
+func (app *application) parseText(ctx context.Context, wam wxas.WebexAssistantMessage) (*lexruntimeservice.PostTextOutput, error) {
+	ctx, span := tracer.Start(ctx, "lex.PostText")
+	defer span.End()
+	lr, err := app.lex.PostTextWithContext(ctx, &lexruntimeservice.PostTextInput{
+		BotAlias:  &app.config.Lex.Alias,
+		BotName:   &app.config.Lex.BotName,
+		InputText: &wam.Text,
+		UserId:    wam.Context.UserID,
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if lr.IntentName != nil {
+		span.SetAttributes(attribute.String("lex.intent", *lr.IntentName))
+	}
+	if lr.DialogState != nil {
+		span.SetAttributes(attribute.String("lex.dialog_state", *lr.DialogState))
+	}
+	return lr, nil
+}
+
+// currentWeather retrieves the current weather for the given city.  The openweathermap library
+// doesn't accept a context, so the request is traced with a span of its own.
+func (app *application) currentWeather(ctx context.Context, city string) (string, error) {
+	_, span := tracer.Start(ctx, "openweathermap.CurrentByName")
+	defer span.End()
+	span.SetAttributes(attribute.String("weather.city", city))
+	w, err := owm.NewCurrent("C", "en", app.config.OpenWeatherMap.APIKey)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+	err = w.CurrentByName(city)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+	if len(w.Weather) == 0 {
+		err = fmt.Errorf("no weather information returned for %s", city)
+		span.SetStatus(codes.Error, err.Error())
+		return "", err
+	}
+	return fmt.Sprintf("The current weather in %s shows %s, with a low of %2.0f and a high of %2.0f.", w.Name, w.Weather[0].Description, w.Main.TempMin, w.Main.TempMax), nil
+}
+
 func buildSkillIntroResponse(wam wxas.WebexAssistantMessage) wxas.WebexAssistantResponse {
 	var wr wxas.WebexAssistantResponse
 	text := "Sorry, I didn't catch what you said."
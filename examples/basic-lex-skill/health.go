@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lexruntimeservice"
+)
+
+// owmEndpoint is the OpenWeatherMap API used by currentWeather.
+const owmEndpoint = "https://api.openweathermap.org"
+
+// testLex checks we're able to communicate with lex by sending it some text.  Since each request
+// is billed, this is only used at startup rather than for the health checks.
+func (app *application) testLex(ctx context.Context) error {
+	_, err := app.lex.PostTextWithContext(ctx, &lexruntimeservice.PostTextInput{
+		BotAlias:  &app.config.Lex.Alias,
+		BotName:   &app.config.Lex.BotName,
+		InputText: aws.String("hello"),
+		UserId:    aws.String("health-check"),
+	})
+	return err
+}
+
+// checkLex checks we have credentials for lex and are able to reach its endpoint.
+func (app *application) checkLex(ctx context.Context) error {
+	if _, err := app.lex.Config.Credentials.GetWithContext(ctx); err != nil {
+		return fmt.Errorf("lex credentials: %w", err)
+	}
+	return reachable(ctx, app.lex.Endpoint)
+}
+
+// checkWeather checks we're able to reach OpenWeatherMap, without using any of our quota.
+func (app *application) checkWeather(ctx context.Context) error {
+	return reachable(ctx, owmEndpoint)
+}
+
+// reachable checks that a connection can be made to the host of the given endpoint.
+func reachable(ctx context.Context, endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -1,58 +1,131 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"os"
 	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/lexruntimeservice"
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/config"
+	"github.com/darrenparkinson/wxa-skills-go/debug"
+	"github.com/darrenparkinson/wxa-skills-go/health"
+	"github.com/darrenparkinson/wxa-skills-go/logging"
+	"github.com/darrenparkinson/wxa-skills-go/ratelimit"
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+	"github.com/darrenparkinson/wxa-skills-go/tracing"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type application struct {
-	config   *Config
-	errorLog *log.Logger
-	infoLog  *log.Logger
-	lex      *lexruntimeservice.LexRuntimeService
-	wg       *sync.WaitGroup
+	config *Config
+	debug  *debug.Recorder
+	health *health.Registry
+	logger *slog.Logger
+	lex    *lexruntimeservice.LexRuntimeService
+	skill  *wxas.Skill
+	wg     *sync.WaitGroup
 }
 
 func main() {
-	cfg, err := loadConfig()
+	cfg, loader, err := loadConfig()
 	if err != nil {
 		log.Fatal(err)
 	}
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	redaction, err := redact.Parse(cfg.Log.Redaction)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger = slog.New(redact.NewHandler(logger.Handler(), redaction))
+	slog.SetDefault(logger)
+	logger.Info("loaded configuration", "config", loader)
 	app := &application{
-		config:   cfg,
-		errorLog: errorLog,
-		infoLog:  infoLog,
-		wg:       &sync.WaitGroup{},
+		config: cfg,
+		logger: logger,
+		wg:     &sync.WaitGroup{},
 	}
 
-	// initialise lex using environment
-	sess, err := session.NewSession()
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "basic-lex-skill",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Logger:      logger,
+	})
 	if err != nil {
-		log.Fatal(err)
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
-	svc := lexruntimeservice.New(sess)
-	infoLog.Println("testing", svc.ServiceID, svc.ServiceName, svc.APIVersion)
-	_, err = svc.PostText(&lexruntimeservice.PostTextInput{
-		BotAlias:  &app.config.Lex.Alias,
-		BotName:   &app.config.Lex.BotName,
-		InputText: aws.String("hello"),
-		UserId:    aws.String("dummy"),
+
+	// initialise lex using the configured credentials, tracing the outbound requests
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(cfg.AWS.Region),
+		Credentials: credentials.NewStaticCredentials(cfg.AWS.AccessKeyID, cfg.AWS.SecretAccessKey, ""),
+		HTTPClient:  tracing.HTTPClient(nil),
 	})
 	if err != nil {
-		errorLog.Fatalf("error communicating with lex: %s", err)
+		logger.Error(err.Error())
+		os.Exit(1)
 	}
-	infoLog.Println("successfully connected to lex")
+	svc := lexruntimeservice.New(sess)
 	app.lex = svc
+	logger.Info("testing lex", "service_id", svc.ServiceID, "service_name", svc.ServiceName, "api_version", svc.APIVersion)
+	err = app.testLex(context.Background())
+	if err != nil {
+		logger.Error("error communicating with lex", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("successfully connected to lex")
+
+	app.health = health.NewRegistry()
+	app.health.Register("lex", true, health.CheckerFunc(app.checkLex))
+	app.health.Register("openweathermap", false, health.CheckerFunc(app.checkWeather))
 
+	app.skill, err = wxas.NewSkill(cfg.Skill.PrivateKey, cfg.Skill.Secret, wxas.HandlerFunc(app.handleMessage))
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app.skill.Logger = logger
+	app.skill.Redaction = redaction
+	app.skill.Health = app.health
+	if cfg.Debug.Token != "" {
+		app.debug = debug.NewRecorder(cfg.Debug.Turns)
+		app.skill.Recorder = app.debug
+	}
+	limits, err := ratelimit.ParseConfig(cfg.RateLimit.Org, cfg.RateLimit.User, cfg.RateLimit.Orgs)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app.skill.Limiter = ratelimit.New(limits)
+	app.skill.Metrics = wxas.NewMetrics("basic_lex_skill", prometheus.DefaultRegisterer)
+
+	watcher := config.NewWatcher(loader, func() error {
+		var newCfg Config
+		if err := loader.Load(&newCfg); err != nil {
+			return err
+		}
+		return app.skill.SetCredentials(newCfg.Skill.PrivateKey, newCfg.Skill.Secret)
+	})
+	watcher.Logger = logger
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	go watcher.Run(watchCtx)
 	err = app.serve()
+	stopWatching()
+	if err := shutdownTracing(context.Background()); err != nil {
+		app.logger.Error("error shutting down tracing", "error", err)
+	}
 	if err != nil {
-		app.errorLog.Fatal(err)
+		app.logger.Error(err.Error())
+		os.Exit(1)
 	}
 }
@@ -3,10 +3,6 @@ package main
 import (
 	"fmt"
 	"net/http"
-	"time"
-
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 func secureHeaders(next http.Handler) http.Handler {
@@ -18,8 +14,10 @@ func secureHeaders(next http.Handler) http.Handler {
 }
 func (app *application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.RequestURI() != "/metrics" {
-			app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		switch r.URL.RequestURI() {
+		case "/metrics", "/livez", "/readyz":
+		default:
+			app.logger.Info("request", "remote_addr", r.RemoteAddr, "proto", r.Proto, "method", r.Method, "uri", r.URL.RequestURI())
 		}
 		next.ServeHTTP(w, r)
 	})
@@ -36,27 +34,3 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
-func (app *application) metrics(next http.Handler) http.Handler {
-	totalRequestsReceived := promauto.NewCounter(prometheus.CounterOpts{
-		Name: "app_requests_received_total",
-		Help: "The total number of processed requests",
-	})
-	totalResponsesSent := promauto.NewCounter(prometheus.CounterOpts{
-		Name: "app_responses_sent_total",
-		Help: "The total number of processed responses",
-	})
-	totalProcessingTimeMicroseconds := promauto.NewCounter(prometheus.CounterOpts{
-		Name: "app_processing_time_microseconds_total",
-		Help: "The total amount of processing time in microseconds",
-	})
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		totalRequestsReceived.Inc()
-		next.ServeHTTP(w, r)
-		totalResponsesSent.Inc()
-		duration := time.Since(start).Microseconds()
-		totalProcessingTimeMicroseconds.Add(float64(duration))
-	})
-}
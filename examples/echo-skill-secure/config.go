@@ -1,67 +1,39 @@
 package main
 
 import (
-	"os"
-
-	"github.com/spf13/viper"
+	"github.com/darrenparkinson/wxa-skills-go/config"
 )
 
-// Config holds a reference to any required config.  It uses viper to
-// unmarshal environment variables into the configuration.
+// Config holds a reference to any required config.  It is loaded from environment variables,
+// a .env file and files using the config package.
 type Config struct {
-	Port  int
-	Skill struct {
-		PrivateKey string `mapstructure:"skill_private_key"`
-		PublicKey  string `mapstructure:"skill_public_key"`
-		Secret     string `mapstructure:"skill_secret"`
-	} `mapstructure:",squash"`
+	Port  int `env:"PORT" default:"8080"`
+	Skill config.Skill
+	Log   struct {
+		Format    string `env:"LOG_FORMAT" default:"console"`
+		Level     string `env:"LOG_LEVEL" default:"info"`
+		Redaction string `env:"LOG_REDACTION" default:"default"`
+	}
+	RateLimit struct {
+		Org  string `env:"RATE_LIMIT_ORG"`
+		User string `env:"RATE_LIMIT_USER"`
+		Orgs string `env:"RATE_LIMIT_ORGS"`
+	}
+	Debug struct {
+		Token string `env:"DEBUG_TOKEN" secret:"true"`
+		Turns int    `env:"DEBUG_TURNS" default:"100"`
+	}
+	Tracing struct {
+		Exporter string `env:"TRACING_EXPORTER" default:"none"`
+		Endpoint string `env:"TRACING_ENDPOINT"`
+	}
 }
 
-func loadConfig() (*Config, error) {
-
-	// Set defaults
-	viper.SetDefault("port", 8080)
-	viper.SetDefault("skill_private_key", "")
-	viper.SetDefault("skill_public_key", "")
-	viper.SetDefault("skill_secret", "")
-
-	// Set up Viper
-	viper.SetConfigName(".env")
-	viper.SetConfigType("env")
-	viper.AddConfigPath(".")
-	viper.ReadInConfig() // ignore error since there may not be a config file
-	viper.AutomaticEnv() // read in environment variables that match
-
+func loadConfig() (*Config, *config.Loader, error) {
 	cfg := Config{}
-	err := viper.Unmarshal(&cfg)
+	loader, err := config.Load(&cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-
-	if cfg.Skill.PrivateKey == "" || cfg.Skill.PublicKey == "" || cfg.Skill.Secret == "" {
-		// let's try to find the files instead?
-		if cfg.Skill.PrivateKey == "" {
-			f, err := os.ReadFile("private.pem")
-			if err != nil {
-				return nil, ErrMissingEnvironment
-			}
-			cfg.Skill.PrivateKey = string(f)
-		}
-		if cfg.Skill.PublicKey == "" {
-			f, err := os.ReadFile("public.pem")
-			if err != nil {
-				return nil, ErrMissingEnvironment
-			}
-			cfg.Skill.PublicKey = string(f)
-		}
-		if cfg.Skill.Secret == "" {
-			f, err := os.ReadFile("secret.txt")
-			if err != nil {
-				return nil, ErrMissingEnvironment
-			}
-			cfg.Skill.Secret = string(f)
-		}
-	}
-
-	return &cfg, nil
+	return &cfg, loader, nil
 }
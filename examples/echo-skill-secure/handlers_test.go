@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+)
+
+func TestHandleMessage(t *testing.T) {
+	app := &application{}
+	h := skilltest.New(t, wxas.HandlerFunc(app.handleMessage))
+
+	h.Intro().
+		ExpectReplyMatch("echo skill").
+		ExpectSpeakMatch("echo skill").
+		ExpectListen().
+		ExpectChallengeEcho()
+	h.Say("hello").
+		ExpectReply("hello").
+		ExpectSpeak("hello").
+		ExpectSleep().
+		ExpectChallengeEcho()
+	h.Say("bonjour").WithLocale("fr_FR").ExpectReply("bonjour")
+	h.Say("").ExpectReplyMatch("didn't get anything").ExpectSleep()
+}
+
+func TestConversations(t *testing.T) {
+	app := &application{}
+	skilltest.New(t, wxas.HandlerFunc(app.handleMessage)).RunScript("testdata/conversations.yaml")
+}
@@ -1,35 +1,102 @@
 package main
 
 import (
+	"context"
 	"log"
+	"log/slog"
 	"os"
 	"sync"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/config"
+	"github.com/darrenparkinson/wxa-skills-go/debug"
+	"github.com/darrenparkinson/wxa-skills-go/health"
+	"github.com/darrenparkinson/wxa-skills-go/logging"
+	"github.com/darrenparkinson/wxa-skills-go/ratelimit"
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+	"github.com/darrenparkinson/wxa-skills-go/tracing"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type application struct {
-	config   *Config
-	errorLog *log.Logger
-	infoLog  *log.Logger
-	models   models
-	wg       *sync.WaitGroup
+	config *Config
+	debug  *debug.Recorder
+	health *health.Registry
+	logger *slog.Logger
+	models models
+	skill  *wxas.Skill
+	wg     *sync.WaitGroup
 }
 
 func main() {
-	cfg, err := loadConfig()
+	cfg, loader, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
 	if err != nil {
 		log.Fatal(err)
 	}
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	redaction, err := redact.Parse(cfg.Log.Redaction)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger = slog.New(redact.NewHandler(logger.Handler(), redaction))
+	slog.SetDefault(logger)
+	logger.Info("loaded configuration", "config", loader)
 	app := &application{
-		config:   cfg,
-		errorLog: errorLog,
-		infoLog:  infoLog,
-		models:   newModels(),
-		wg:       &sync.WaitGroup{},
+		config: cfg,
+		health: health.NewRegistry(),
+		logger: logger,
+		models: newModels(),
+		wg:     &sync.WaitGroup{},
 	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "echo-skill-secure",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Logger:      logger,
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app.skill, err = wxas.NewSkill(cfg.Skill.PrivateKey, cfg.Skill.Secret, wxas.HandlerFunc(app.handleMessage))
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app.skill.Logger = logger
+	app.skill.Redaction = redaction
+	app.skill.Health = app.health
+	if cfg.Debug.Token != "" {
+		app.debug = debug.NewRecorder(cfg.Debug.Turns)
+		app.skill.Recorder = app.debug
+	}
+	limits, err := ratelimit.ParseConfig(cfg.RateLimit.Org, cfg.RateLimit.User, cfg.RateLimit.Orgs)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app.skill.Limiter = ratelimit.New(limits)
+	app.skill.Metrics = wxas.NewMetrics("echo_skill_secure", prometheus.DefaultRegisterer)
+	watcher := config.NewWatcher(loader, func() error {
+		var newCfg Config
+		if err := loader.Load(&newCfg); err != nil {
+			return err
+		}
+		return app.skill.SetCredentials(newCfg.Skill.PrivateKey, newCfg.Skill.Secret)
+	})
+	watcher.Logger = logger
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	go watcher.Run(watchCtx)
 	err = app.serve()
+	stopWatching()
+	if err := shutdownTracing(context.Background()); err != nil {
+		app.logger.Error("error shutting down tracing", "error", err)
+	}
 	if err != nil {
-		app.errorLog.Fatal(err)
+		app.logger.Error(err.Error())
+		os.Exit(1)
 	}
 }
@@ -1,14 +1,5 @@
 package main
 
-import (
-	"errors"
-)
-
-var (
-	// ErrMissingEnvironment is a constant error for missing environment variables
-	ErrMissingEnvironment = errors.New("missing required environment variables or files")
-)
-
 type webexAssistantModel struct{}
 
 type models struct {
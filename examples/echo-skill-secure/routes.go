@@ -10,8 +10,12 @@ import (
 func (app *application) routes() http.Handler {
 	mainRouter := mux.NewRouter()
 	mainRouter.HandleFunc("/ping", http.HandlerFunc(ping))
+	mainRouter.Handle("/livez", app.health.LivezHandler())
+	mainRouter.Handle("/readyz", app.health.ReadyzHandler())
 	mainRouter.Handle("/metrics", promhttp.Handler())
-	mainRouter.HandleFunc("/", app.handleSkills).Methods(http.MethodPost)
-	mainRouter.HandleFunc("/", app.handleHealthCheck).Methods(http.MethodGet)
-	return app.metrics(app.recoverPanic(app.logRequest(secureHeaders(mainRouter))))
+	if app.debug != nil {
+		mainRouter.Handle("/debug/turns", app.debug.Handler(app.config.Debug.Token)).Methods(http.MethodGet)
+	}
+	mainRouter.Handle("/", app.skill).Methods(http.MethodGet, http.MethodPost)
+	return app.recoverPanic(app.logRequest(secureHeaders(mainRouter)))
 }
@@ -1,30 +1,23 @@
 package main
 
 import (
-	"github.com/spf13/viper"
+	"github.com/darrenparkinson/wxa-skills-go/config"
 )
 
 // Config holds a reference to any required config
 type Config struct {
-	Port int
+	Port int `env:"PORT" default:"8080"`
+	Log  struct {
+		Format string `env:"LOG_FORMAT" default:"console"`
+		Level  string `env:"LOG_LEVEL" default:"info"`
+	}
 }
 
-func loadConfig() (*Config, error) {
-
-	// Set defaults
-	viper.SetDefault("port", 8080)
-
-	// Set up Viper
-	viper.SetConfigName(".env")
-	viper.SetConfigType("env")
-	viper.AddConfigPath(".")
-	viper.ReadInConfig() // ignore error since there may not be a config file
-	viper.AutomaticEnv() // read in environment variables that match
-
+func loadConfig() (*Config, *config.Loader, error) {
 	cfg := Config{}
-	err := viper.Unmarshal(&cfg)
+	loader, err := config.Load(&cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return &cfg, nil
+	return &cfg, loader, nil
 }
@@ -17,10 +17,10 @@ func (app *application) handleSkills(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		var mr *malformedRequest
 		if errors.As(err, &mr) {
-			app.errorLog.Println(err.Error())
+			app.logger.Warn("invalid request", "error", err)
 			app.errorResponse(w, r, mr.status, mr.msg)
 		} else {
-			app.errorLog.Println(err.Error())
+			app.logger.Warn("invalid request", "error", err)
 			app.invalidRequestResponse(w, r)
 		}
 		return
@@ -42,8 +42,8 @@ func (app *application) handleSkills(w http.ResponseWriter, r *http.Request) {
 	}
 	resp, err := buildResponse(text, shouldListen)
 	if err != nil {
-		app.errorLog.Println(err.Error())
 		app.serverError(w, err)
+		return
 	}
 	renderJSON(w, resp)
 }
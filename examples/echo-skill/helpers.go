@@ -15,8 +15,7 @@ import (
 type envelope map[string]interface{}
 
 func (app *application) serverError(w http.ResponseWriter, err error) {
-	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
-	app.errorLog.Output(2, trace)
+	app.logger.Error(err.Error(), "stack", string(debug.Stack()))
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
@@ -29,7 +28,7 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 	env := envelope{"error": message}
 	err := app.writeJSON(w, status, env, nil)
 	if err != nil {
-		app.errorLog.Println(err)
+		app.logger.Error("error writing response", "error", err)
 		w.WriteHeader(500)
 	}
 }
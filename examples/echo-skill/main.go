@@ -2,35 +2,41 @@ package main
 
 import (
 	"log"
+	"log/slog"
 	"os"
 	"sync"
+
+	"github.com/darrenparkinson/wxa-skills-go/logging"
 )
 
 type application struct {
-	config   *Config
-	errorLog *log.Logger
-	infoLog  *log.Logger
-	models   models
-	wg       *sync.WaitGroup
+	config *Config
+	logger *slog.Logger
+	models models
+	wg     *sync.WaitGroup
 }
 
 func main() {
-	cfg, err := loadConfig()
+	cfg, loader, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
 	if err != nil {
 		log.Fatal(err)
 	}
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	slog.SetDefault(logger)
+	logger.Info("loaded configuration", "config", loader)
 	app := &application{
-		config:   cfg,
-		errorLog: errorLog,
-		infoLog:  infoLog,
-		models:   newModels(),
-		wg:       &sync.WaitGroup{},
+		config: cfg,
+		logger: logger,
+		models: newModels(),
+		wg:     &sync.WaitGroup{},
 	}
 	err = app.serve()
 	if err != nil {
-		app.errorLog.Fatal(err)
+		app.logger.Error(err.Error())
+		os.Exit(1)
 	}
 
 }
@@ -9,6 +9,8 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/logging"
 )
 
 func (app *application) serve() error {
@@ -18,25 +20,25 @@ func (app *application) serve() error {
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 30 * time.Second,
-		ErrorLog:     app.errorLog,
+		ErrorLog:     logging.ErrorLog(app.logger),
 	}
 	shutdownError := make(chan error)
 	go func() {
 		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		s := <-quit
-		app.infoLog.Println("caught signal", s.String())
+		app.logger.Info("caught signal", "signal", s.String())
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		err := srv.Shutdown(ctx)
 		if err != nil {
 			shutdownError <- err
 		}
-		app.infoLog.Println("completing background tasks:", srv.Addr)
+		app.logger.Info("completing background tasks", "addr", srv.Addr)
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
-	app.infoLog.Printf("starting server on %s", srv.Addr)
+	app.logger.Info("starting server", "addr", srv.Addr)
 	err := srv.ListenAndServe()
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
@@ -45,6 +47,6 @@ func (app *application) serve() error {
 	if err != nil {
 		return err
 	}
-	app.infoLog.Println("stopped server on", srv.Addr)
+	app.logger.Info("stopped server", "addr", srv.Addr)
 	return nil
 }
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/darrenparkinson/wxa-skills-go/config"
+)
+
+// Config holds a reference to any required config for the process.  The skills themselves are
+// configured in the file named by HOST_CONFIG.
+type Config struct {
+	Port       int    `env:"PORT" default:"8080"`
+	HostConfig string `env:"HOST_CONFIG" default:"skills.yaml"`
+	Log        struct {
+		Format    string `env:"LOG_FORMAT" default:"console"`
+		Level     string `env:"LOG_LEVEL" default:"info"`
+		Redaction string `env:"LOG_REDACTION" default:"default"`
+	}
+	RateLimit struct {
+		Org  string `env:"RATE_LIMIT_ORG"`
+		User string `env:"RATE_LIMIT_USER"`
+		Orgs string `env:"RATE_LIMIT_ORGS"`
+	}
+	Debug struct {
+		Token string `env:"DEBUG_TOKEN" secret:"true"`
+		Turns int    `env:"DEBUG_TURNS" default:"100"`
+	}
+	Tracing struct {
+		Exporter string `env:"TRACING_EXPORTER" default:"none"`
+		Endpoint string `env:"TRACING_ENDPOINT"`
+	}
+}
+
+func loadConfig() (*Config, *config.Loader, error) {
+	cfg := Config{}
+	loader, err := config.Load(&cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &cfg, loader, nil
+}
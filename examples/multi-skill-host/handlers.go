@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+func ping(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+func handleEcho(ctx context.Context, wam wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+	turn := wxas.TurnFromContext(ctx)
+	if wam.Params.TargetDialogueState == "skill_intro" {
+		turn.Intent = "skill_intro"
+		return buildResponse("This is the echo skill.  Say something and I will echo it back.", wam.Challenge, true), nil
+	}
+	turn.Intent = "echo"
+	if wam.Text == "" {
+		turn.Fallback = true
+		return buildResponse("Hmm... I didn't get anything to echo", wam.Challenge, false), nil
+	}
+	return buildResponse(wam.Text, wam.Challenge, false), nil
+}
+
+func handleShout(ctx context.Context, wam wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+	turn := wxas.TurnFromContext(ctx)
+	if wam.Params.TargetDialogueState == "skill_intro" {
+		turn.Intent = "skill_intro"
+		return buildResponse("This is the shout skill.  Say something and I will shout it back.", wam.Challenge, true), nil
+	}
+	turn.Intent = "shout"
+	if wam.Text == "" {
+		turn.Fallback = true
+		return buildResponse("I CAN'T HEAR YOU", wam.Challenge, false), nil
+	}
+	return buildResponse(strings.ToUpper(wam.Text)+"!", wam.Challenge, false), nil
+}
+
+func buildResponse(text string, challenge string, shouldListen bool) wxas.WebexAssistantResponse {
+	var wr wxas.WebexAssistantResponse
+	var sleepOrListen wxas.DirectiveName
+	if shouldListen {
+		sleepOrListen = wxas.DirectiveNameListen
+	} else {
+		sleepOrListen = wxas.DirectiveNameSleep
+	}
+	wr.Directives = []wxas.WebexAssistantDirective{
+		{Name: wxas.DirectiveNameReply, Type: wxas.DirectiveTypeView, Payload: wxas.Payload{Text: &text}},
+		{Name: wxas.DirectiveNameSpeak, Type: wxas.DirectiveTypeAction, Payload: wxas.Payload{Text: &text}},
+		{Name: sleepOrListen, Type: wxas.DirectiveTypeAction},
+	}
+	wr.Challenge = challenge
+	return wr
+}
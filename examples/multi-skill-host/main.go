@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/darrenparkinson/wxa-skills-go/config"
+	"github.com/darrenparkinson/wxa-skills-go/debug"
+	"github.com/darrenparkinson/wxa-skills-go/health"
+	"github.com/darrenparkinson/wxa-skills-go/host"
+	"github.com/darrenparkinson/wxa-skills-go/logging"
+	"github.com/darrenparkinson/wxa-skills-go/ratelimit"
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+	"github.com/darrenparkinson/wxa-skills-go/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type application struct {
+	config *Config
+	debug  *debug.Recorder
+	health *health.Registry
+	host   *host.Host
+	logger *slog.Logger
+	wg     *sync.WaitGroup
+}
+
+func main() {
+	cfg, loader, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger, err := logging.New(os.Stdout, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		log.Fatal(err)
+	}
+	redaction, err := redact.Parse(cfg.Log.Redaction)
+	if err != nil {
+		log.Fatal(err)
+	}
+	logger = slog.New(redact.NewHandler(logger.Handler(), redaction))
+	slog.SetDefault(logger)
+	logger.Info("loaded configuration", "config", loader)
+	hostCfg, err := host.LoadConfig(cfg.HostConfig)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app := &application{
+		config: cfg,
+		health: health.NewRegistry(),
+		host:   host.New(),
+		logger: logger,
+		wg:     &sync.WaitGroup{},
+	}
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "multi-skill-host",
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Logger:      logger,
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	limits, err := ratelimit.ParseConfig(cfg.RateLimit.Org, cfg.RateLimit.User, cfg.RateLimit.Orgs)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app.host.Namespace = "multi_skill_host"
+	app.host.Registerer = prometheus.DefaultRegisterer
+	app.host.Logger = logger
+	app.host.Redaction = redaction
+	app.host.Limiter = ratelimit.New(limits)
+	app.host.Health = app.health
+	if cfg.Debug.Token != "" {
+		app.debug = debug.NewRecorder(cfg.Debug.Turns)
+		app.host.Recorder = app.debug
+	}
+	app.host.HandleFunc("echo", handleEcho)
+	app.host.HandleFunc("shout", handleShout)
+	if err := app.host.Mount(hostCfg); err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	logger.Info("mounted skills", "skills", app.host.Names())
+
+	watcher := config.NewWatcher(loader, func() error {
+		newCfg, err := host.LoadConfig(cfg.HostConfig)
+		if err != nil {
+			return err
+		}
+		if err := app.host.Reload(newCfg); err != nil {
+			return err
+		}
+		hostCfg = newCfg
+		return nil
+	})
+	watcher.Files = func() []string { return hostCfg.Files() }
+	watcher.Logger = logger
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	go watcher.Run(watchCtx)
+	err = app.serve()
+	stopWatching()
+	if err := shutdownTracing(context.Background()); err != nil {
+		app.logger.Error("error shutting down tracing", "error", err)
+	}
+	if err != nil {
+		app.logger.Error(err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func (app *application) routes() http.Handler {
+	mainRouter := mux.NewRouter()
+	mainRouter.HandleFunc("/ping", http.HandlerFunc(ping))
+	mainRouter.Handle("/livez", app.health.LivezHandler())
+	mainRouter.Handle("/readyz", app.health.ReadyzHandler())
+	mainRouter.Handle("/metrics", promhttp.Handler())
+	if app.debug != nil {
+		mainRouter.Handle("/debug/turns", app.debug.Handler(app.config.Debug.Token)).Methods(http.MethodGet)
+	}
+	mainRouter.PathPrefix("/").Handler(app.host).Methods(http.MethodGet, http.MethodPost)
+	return app.recoverPanic(app.logRequest(secureHeaders(mainRouter)))
+}
@@ -0,0 +1,228 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package health provides a registry of dependency checks used to report the liveness and
+// readiness of a skill.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Status Constants
+const (
+	StatusOK       = "OK"
+	StatusDegraded = "DEGRADED"
+	StatusFailed   = "FAILED"
+)
+
+// DefaultTimeout is the time allowed for each check to complete.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultCacheTTL is how long check results are reused for, so that frequent probes don't
+// result in frequent calls to dependencies.
+const DefaultCacheTTL = 10 * time.Second
+
+// Checker checks a dependency, returning an error if it isn't available.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to allow the use of ordinary functions as checkers.
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx).
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the result of a single check.
+type Result struct {
+	Status     string `json:"status"`
+	Critical   bool   `json:"critical"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the result of running all of the checks.  The status is degraded if any critical
+// check fails.
+type Report struct {
+	Status    string            `json:"status"`
+	Checks    map[string]Result `json:"checks,omitempty"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+type check struct {
+	name     string
+	critical bool
+	checker  Checker
+}
+
+// Registry holds the registered checks.  It can be created using NewRegistry.
+type Registry struct {
+	// Timeout is the time allowed for each check.
+	Timeout time.Duration
+
+	// CacheTTL is how long a report is reused for.  Set to zero to run the checks every time.
+	CacheTTL time.Duration
+
+	mu       sync.Mutex
+	checks   []check
+	last     *Report
+	inflight *flight
+}
+
+// flight is a run of the checks which concurrent callers wait for rather than starting their own.
+type flight struct {
+	done   chan struct{}
+	report Report
+}
+
+// NewRegistry returns a new registry using the default timeout and cache TTL.
+func NewRegistry() *Registry {
+	return &Registry{
+		Timeout:  DefaultTimeout,
+		CacheTTL: DefaultCacheTTL,
+	}
+}
+
+// Register adds a named check.  If a critical check fails, the skill is reported as degraded
+// and not ready.
+func (r *Registry) Register(name string, critical bool, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check{name: name, critical: critical, checker: c})
+	r.last = nil
+	r.inflight = nil
+}
+
+// Run runs all of the checks concurrently, or returns the previous report if it is still fresh.
+// Concurrent callers share a single run of the checks.  The checks aren't cancelled with ctx,
+// only by the timeout, so that a caller which gives up doesn't leave failures in the cache.
+// A nil *Registry is valid and always reports OK.
+func (r *Registry) Run(ctx context.Context) Report {
+	if r == nil {
+		return Report{Status: StatusOK, CheckedAt: time.Now()}
+	}
+	r.mu.Lock()
+	if r.last != nil && time.Since(r.last.CheckedAt) < r.CacheTTL {
+		report := *r.last
+		r.mu.Unlock()
+		return report
+	}
+	if f := r.inflight; f != nil {
+		r.mu.Unlock()
+		<-f.done
+		return f.report
+	}
+	f := &flight{done: make(chan struct{})}
+	r.inflight = f
+	checks := make([]check, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.Unlock()
+
+	f.report = r.runChecks(context.WithoutCancel(ctx), checks)
+	r.mu.Lock()
+	// a check registered during the run invalidates the report for later callers
+	if r.inflight == f {
+		r.last = &f.report
+		r.inflight = nil
+	}
+	r.mu.Unlock()
+	close(f.done)
+	return f.report
+}
+
+func (r *Registry) runChecks(ctx context.Context, checks []check) Report {
+
+	report := Report{
+		Status:    StatusOK,
+		Checks:    make(map[string]Result, len(checks)),
+		CheckedAt: time.Now(),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			res := r.runCheck(ctx, c)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = res
+			if res.Status != StatusOK && c.critical {
+				report.Status = StatusDegraded
+			}
+		}(c)
+	}
+	wg.Wait()
+	return report
+}
+
+func (r *Registry) runCheck(ctx context.Context, c check) Result {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	err := c.checker.Check(ctx)
+	res := Result{
+		Status:     StatusOK,
+		Critical:   c.critical,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		res.Status = StatusFailed
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// LivezHandler returns a handler which reports that the process is running.  It doesn't run
+// the checks, since a failing dependency is no reason to restart the skill.
+func (r *Registry) LivezHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusOK, CheckedAt: time.Now()})
+	})
+}
+
+// ReadyzHandler returns a handler which runs the checks and reports the results.  It responds
+// with 503 Service Unavailable if any critical check fails.
+func (r *Registry) ReadyzHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, status, report)
+	})
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	js, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// toggle is a checker whose result can be changed.
+type toggle struct {
+	err   atomic.Value
+	calls atomic.Int32
+}
+
+func (t *toggle) set(err error) { t.err.Store(&err) }
+
+func (t *toggle) Check(ctx context.Context) error {
+	t.calls.Add(1)
+	if err, ok := t.err.Load().(*error); ok {
+		return *err
+	}
+	return nil
+}
+
+func TestRunStateChanges(t *testing.T) {
+	r := NewRegistry()
+	r.CacheTTL = 0
+	db, cache := &toggle{}, &toggle{}
+	r.Register("db", true, db)
+	r.Register("cache", false, cache)
+
+	steps := []struct {
+		name       string
+		dbErr      error
+		cacheErr   error
+		wantStatus string
+		wantDB     string
+		wantCache  string
+	}{
+		{name: "all ok", wantStatus: StatusOK, wantDB: StatusOK, wantCache: StatusOK},
+		{name: "non critical fails", cacheErr: errors.New("down"), wantStatus: StatusOK, wantDB: StatusOK, wantCache: StatusFailed},
+		{name: "critical fails", dbErr: errors.New("down"), wantStatus: StatusDegraded, wantDB: StatusFailed, wantCache: StatusOK},
+		{name: "both fail", dbErr: errors.New("down"), cacheErr: errors.New("down"), wantStatus: StatusDegraded, wantDB: StatusFailed, wantCache: StatusFailed},
+		{name: "recovered", wantStatus: StatusOK, wantDB: StatusOK, wantCache: StatusOK},
+	}
+	for _, s := range steps {
+		db.set(s.dbErr)
+		cache.set(s.cacheErr)
+		report := r.Run(context.Background())
+		if report.Status != s.wantStatus {
+			t.Errorf("%s: status = %s, want %s", s.name, report.Status, s.wantStatus)
+		}
+		if got := report.Checks["db"]; got.Status != s.wantDB || !got.Critical {
+			t.Errorf("%s: db = %+v, want status %s and critical", s.name, got, s.wantDB)
+		}
+		if got := report.Checks["cache"]; got.Status != s.wantCache || got.Critical {
+			t.Errorf("%s: cache = %+v, want status %s and not critical", s.name, got, s.wantCache)
+		}
+		if s.dbErr != nil && report.Checks["db"].Error != s.dbErr.Error() {
+			t.Errorf("%s: db error = %q, want %q", s.name, report.Checks["db"].Error, s.dbErr)
+		}
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	r := NewRegistry()
+	c := &toggle{}
+	r.Register("db", true, c)
+	r.Run(context.Background())
+	c.set(errors.New("down"))
+	if report := r.Run(context.Background()); report.Status != StatusOK || c.calls.Load() != 1 {
+		t.Errorf("cached report status = %s after %d calls, want OK after 1 call", report.Status, c.calls.Load())
+	}
+	// registering a check invalidates the cached report
+	r.Register("other", false, CheckerFunc(func(ctx context.Context) error { return nil }))
+	if report := r.Run(context.Background()); report.Status != StatusDegraded {
+		t.Errorf("status after register = %s, want %s", report.Status, StatusDegraded)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	r := NewRegistry()
+	r.Timeout = 10 * time.Millisecond
+	r.Register("slow", true, CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	report := r.Run(context.Background())
+	if report.Status != StatusDegraded || report.Checks["slow"].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("report = %+v, want degraded with deadline exceeded", report)
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	r := NewRegistry()
+	r.Register("db", true, CheckerFunc(func(ctx context.Context) error { return ctx.Err() }))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if report := r.Run(ctx); report.Status != StatusOK {
+		t.Errorf("status with cancelled context = %s, want OK", report.Status)
+	}
+	if report := r.Run(context.Background()); report.Status != StatusOK {
+		t.Errorf("cached status = %s, want OK", report.Status)
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	r := NewRegistry()
+	release := make(chan struct{})
+	var calls atomic.Int32
+	r.Register("db", true, CheckerFunc(func(ctx context.Context) error {
+		calls.Add(1)
+		<-release
+		return errors.New("down")
+	}))
+	const callers = 10
+	reports := make(chan Report, callers)
+	for i := 0; i < callers; i++ {
+		go func() { reports <- r.Run(context.Background()) }()
+	}
+	// wait for the first caller to start the checks before letting them finish
+	for calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < callers; i++ {
+		if report := <-reports; report.Status != StatusDegraded {
+			t.Errorf("status = %s, want %s", report.Status, StatusDegraded)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("check called %d times, want 1", n)
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	if report := r.Run(context.Background()); report.Status != StatusOK {
+		t.Errorf("nil registry status = %s, want OK", report.Status)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	r := NewRegistry()
+	r.CacheTTL = 0
+	db := &toggle{}
+	r.Register("db", true, db)
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		dbErr      error
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "readyz ok", handler: r.ReadyzHandler(), wantCode: http.StatusOK, wantStatus: StatusOK},
+		{name: "readyz degraded", handler: r.ReadyzHandler(), dbErr: errors.New("down"), wantCode: http.StatusServiceUnavailable, wantStatus: StatusDegraded},
+		{name: "livez ignores checks", handler: r.LivezHandler(), dbErr: errors.New("down"), wantCode: http.StatusOK, wantStatus: StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.set(tt.dbErr)
+			rec := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			var report Report
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("invalid report %q: %s", rec.Body.String(), err)
+			}
+			if rec.Code != tt.wantCode || report.Status != tt.wantStatus {
+				t.Errorf("got %d %s, want %d %s", rec.Code, report.Status, tt.wantCode, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("content type = %q", ct)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/darrenparkinson/wxa-skills-go/config"
+	"gopkg.in/yaml.v2"
+)
+
+// SkillConfig configures a single skill on the host.  The private key and secret can be given
+// inline or read from files, which are relative to the configuration file.
+type SkillConfig struct {
+	// Name identifies the skill.  It must match the name the handler was registered with and is
+	// used to label the skill's logs and metrics.
+	Name string `yaml:"name"`
+
+	// Path is the URL path the skill is mounted on.  If empty, "/" is used.
+	Path string `yaml:"path"`
+
+	// Hostname, if set, restricts the skill to requests for that host, so several skills can
+	// be mounted on the same path of different hostnames.
+	Hostname string `yaml:"hostname"`
+
+	PrivateKey     string `yaml:"private_key"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	Secret         string `yaml:"secret"`
+	SecretFile     string `yaml:"secret_file"`
+}
+
+// Config holds the configuration for every skill on the host.  It can be loaded using LoadConfig.
+type Config struct {
+	Skills []SkillConfig `yaml:"skills"`
+
+	files []string
+}
+
+// LoadConfig reads the configuration file at path along with any key and secret files it refers
+// to.  If any skills are misconfigured, a *config.ValidationError listing all of the problems is
+// returned.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.UnmarshalStrict(b, &cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", path, err)
+	}
+	cfg.files = []string{path}
+	dir := filepath.Dir(path)
+	var problems []string
+	if len(cfg.Skills) == 0 {
+		problems = append(problems, "no skills configured")
+	}
+	names := map[string]bool{}
+	routes := map[string]string{}
+	for i := range cfg.Skills {
+		sc := &cfg.Skills[i]
+		label := sc.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+			problems = append(problems, fmt.Sprintf("skill %s: name is required", label))
+		} else if names[sc.Name] {
+			problems = append(problems, fmt.Sprintf("skill %s: name is used more than once", label))
+		}
+		names[sc.Name] = true
+		sc.Path = cleanPath(sc.Path)
+		sc.Hostname = strings.ToLower(sc.Hostname)
+		route := sc.Hostname + sc.Path
+		if other, ok := routes[route]; ok {
+			problems = append(problems, fmt.Sprintf("skill %s: %s is already used by skill %s", label, route, other))
+		}
+		routes[route] = label
+		if sc.PrivateKey, err = cfg.resolve(dir, sc.PrivateKey, sc.PrivateKeyFile); err != nil {
+			problems = append(problems, fmt.Sprintf("skill %s: private key: %s", label, err))
+		} else if sc.PrivateKey == "" {
+			problems = append(problems, fmt.Sprintf("skill %s: private_key or private_key_file is required", label))
+		} else if _, err := config.ParsePrivateKey(sc.PrivateKey); err != nil {
+			problems = append(problems, fmt.Sprintf("skill %s: private key is not a PEM encoded RSA PRIVATE KEY", label))
+		}
+		if sc.Secret, err = cfg.resolve(dir, sc.Secret, sc.SecretFile); err != nil {
+			problems = append(problems, fmt.Sprintf("skill %s: secret: %s", label, err))
+		} else if sc.Secret == "" {
+			problems = append(problems, fmt.Sprintf("skill %s: secret or secret_file is required", label))
+		} else if err := config.ValidateSecret(sc.Secret); err != nil {
+			problems = append(problems, fmt.Sprintf("skill %s: secret must not contain whitespace", label))
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &config.ValidationError{Problems: problems}
+	}
+	return &cfg, nil
+}
+
+// Files returns the configuration file and the key and secret files it refers to, e.g. for use
+// with a config.Watcher.
+func (c *Config) Files() []string {
+	files := make([]string, len(c.files))
+	copy(files, c.files)
+	return files
+}
+
+// resolve returns the inline value or, if it is empty, the contents of the file.
+func (c *Config) resolve(dir, value, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	c.files = append(c.files, file)
+	return string(bytes.TrimRight(b, "\r\n")), nil
+}
+
+// cleanPath makes sure the path starts with a "/" and has no trailing "/".
+func cleanPath(p string) string {
+	return "/" + strings.Trim(p, "/")
+}
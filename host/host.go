@@ -0,0 +1,226 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package host serves many skills from one process.  Each skill is mounted on its own path or
+// hostname with its own key pair, secret and handler, and the skills are configured from a
+// single file:
+//
+//	skills:
+//	  - name: echo
+//	    path: /echo
+//	    private_key_file: echo/private.pem
+//	    secret_file: echo/secret.txt
+//	  - name: weather
+//	    hostname: weather.example.com
+//	    private_key_file: weather/private.pem
+//	    secret_file: weather/secret.txt
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/config"
+	"github.com/darrenparkinson/wxa-skills-go/health"
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Host is an http.Handler that routes requests to the skills mounted on it.  It can be created
+// using New.  The exported fields are applied to each skill when it is mounted.
+type Host struct {
+	// Namespace is the prefix for the skill metrics.  Each skill's metrics are labelled with
+	// skill="<name>".  If empty, "wxas" is used.
+	Namespace string
+
+	// Registerer is used to register the skill metrics.  If nil, no metrics are recorded.
+	Registerer prometheus.Registerer
+
+	// Logger is used by the skills, with a skill attribute added for each.  If nil,
+	// slog.Default() is used.
+	Logger *slog.Logger
+
+	// TracerProvider, Redaction, Limiter, Health and Recorder are passed to each skill.  See
+	// wxas.Skill.
+	TracerProvider trace.TracerProvider
+	Redaction      *redact.Policy
+	Limiter        wxas.Limiter
+	Health         *health.Registry
+	Recorder       wxas.TurnRecorder
+
+	mu       sync.RWMutex
+	handlers map[string]wxas.Handler
+	skills   map[string]*wxas.Skill
+	routes   map[string]*wxas.Skill
+}
+
+// New is a helper function that returns a new host with no skills.
+func New() *Host {
+	return &Host{
+		handlers: map[string]wxas.Handler{},
+		skills:   map[string]*wxas.Skill{},
+		routes:   map[string]*wxas.Skill{},
+	}
+}
+
+// Handle registers the handler for the named skill.  Handlers must be registered before the
+// configuration is mounted.
+func (h *Host) Handle(name string, handler wxas.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[name] = handler
+}
+
+// HandleFunc registers the handler function for the named skill.
+func (h *Host) HandleFunc(name string, handler func(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error)) {
+	h.Handle(name, wxas.HandlerFunc(handler))
+}
+
+// Mount creates and mounts each skill in the configuration.  If any skill has no registered
+// handler, a *config.ValidationError listing them is returned and nothing is mounted.
+func (h *Host) Mount(cfg *Config) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var problems []string
+	for _, sc := range cfg.Skills {
+		if _, ok := h.handlers[sc.Name]; !ok {
+			problems = append(problems, fmt.Sprintf("skill %s: no handler registered", sc.Name))
+		}
+		if _, ok := h.skills[sc.Name]; ok {
+			problems = append(problems, fmt.Sprintf("skill %s: already mounted", sc.Name))
+		}
+		if _, ok := h.routes[sc.Hostname+sc.Path]; ok {
+			problems = append(problems, fmt.Sprintf("skill %s: %s%s is already mounted", sc.Name, sc.Hostname, sc.Path))
+		}
+	}
+	if len(problems) > 0 {
+		return &config.ValidationError{Problems: problems}
+	}
+	for _, sc := range cfg.Skills {
+		s, err := wxas.NewSkill(sc.PrivateKey, sc.Secret, h.handlers[sc.Name])
+		if err != nil {
+			return fmt.Errorf("skill %s: %w", sc.Name, err)
+		}
+		s.Name = sc.Name
+		s.Logger = h.logger().With("skill", sc.Name)
+		s.TracerProvider = h.TracerProvider
+		s.Redaction = h.Redaction
+		s.Limiter = h.Limiter
+		s.Health = h.Health
+		s.Recorder = h.Recorder
+		if h.Registerer != nil {
+			reg := prometheus.WrapRegistererWith(prometheus.Labels{"skill": sc.Name}, h.Registerer)
+			s.Metrics = wxas.NewMetrics(h.namespace(), reg)
+		}
+		h.skills[sc.Name] = s
+		h.routes[sc.Hostname+sc.Path] = s
+	}
+	return nil
+}
+
+// Reload replaces the credentials of the mounted skills with those in the configuration, e.g.
+// when the keys or secrets have been rotated.  Every skill in the configuration must already be
+// mounted; if not, or if any credentials are invalid, an error is returned and no skill is
+// changed.  Skills can't be added, removed or moved without a restart.
+func (h *Host) Reload(cfg *Config) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var problems []string
+	for _, sc := range cfg.Skills {
+		s, ok := h.skills[sc.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("skill %s: not mounted, restart to add it", sc.Name))
+			continue
+		}
+		if h.routes[sc.Hostname+sc.Path] != s {
+			problems = append(problems, fmt.Sprintf("skill %s: route changed, restart to move it", sc.Name))
+		}
+	}
+	if len(problems) > 0 {
+		return &config.ValidationError{Problems: problems}
+	}
+	// LoadConfig has already validated the credentials, so this only fails if the config
+	// wasn't loaded with it.
+	var errs []error
+	for _, sc := range cfg.Skills {
+		if err := h.skills[sc.Name].SetCredentials(sc.PrivateKey, sc.Secret); err != nil {
+			errs = append(errs, fmt.Errorf("skill %s: %w", sc.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Skill returns the named skill, or nil if it isn't mounted.
+func (h *Host) Skill(name string) *wxas.Skill {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.skills[name]
+}
+
+// Names returns the names of the mounted skills in alphabetical order.
+func (h *Host) Names() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	names := make([]string, 0, len(h.skills))
+	for name := range h.skills {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP implements the http.Handler interface.  A skill mounted on the request hostname is
+// preferred to one mounted on the same path for any hostname.
+func (h *Host) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hostname := r.Host
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = host
+	}
+	hostname = strings.ToLower(hostname)
+	path := cleanPath(r.URL.Path)
+	h.mu.RLock()
+	s, ok := h.routes[hostname+path]
+	if !ok {
+		s, ok = h.routes[path]
+	}
+	h.mu.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	s.ServeHTTP(w, r)
+}
+
+func (h *Host) logger() *slog.Logger {
+	if h.Logger != nil {
+		return h.Logger
+	}
+	return slog.Default()
+}
+
+func (h *Host) namespace() string {
+	if h.Namespace != "" {
+		return h.Namespace
+	}
+	return "wxas"
+}
@@ -0,0 +1,348 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"github.com/darrenparkinson/wxa-skills-go/config"
+	"github.com/darrenparkinson/wxa-skills-go/skilltest"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// nameHandler replies with the name of the skill, so tests can tell which skill handled a turn.
+func nameHandler(name string) wxas.HandlerFunc {
+	return func(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+		text := name
+		return wxas.WebexAssistantResponse{
+			Challenge: msg.Challenge,
+			Directives: []wxas.WebexAssistantDirective{
+				{Name: wxas.DirectiveNameReply, Type: wxas.DirectiveTypeView, Payload: wxas.Payload{Text: &text}},
+			},
+		}, nil
+	}
+}
+
+// hostTransport sends requests to the test server with a different Host header.
+type hostTransport struct {
+	host string
+}
+
+func (t hostTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Host = t.host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newKeys(t *testing.T) skilltest.Keys {
+	t.Helper()
+	keys, err := skilltest.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+// send sends a turn for the skill with the keys to the host, on the hostname and path, and returns
+// the status code and reply.
+func send(t *testing.T, srv *httptest.Server, keys skilltest.Keys, hostname, path string) (int, string) {
+	t.Helper()
+	c := &skilltest.Client{URL: srv.URL + path, PublicKey: keys.PublicKey, Secret: keys.Secret}
+	if hostname != "" {
+		c.HTTPClient = &http.Client{Transport: hostTransport{host: hostname}}
+	}
+	res, err := c.Send(context.Background(), wxas.WebexAssistantMessage{Text: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, strings.Join(res.Texts(wxas.DirectiveNameReply), "")
+}
+
+func newHost() *Host {
+	h := New()
+	h.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return h
+}
+
+func TestRouting(t *testing.T) {
+	keys := newKeys(t)
+	cfg := &Config{Skills: []SkillConfig{
+		{Name: "echo", Path: "/echo", PrivateKey: keys.PrivateKey, Secret: keys.Secret},
+		{Name: "root", Path: "/", PrivateKey: keys.PrivateKey, Secret: keys.Secret},
+		{Name: "weather", Hostname: "weather.example.com", Path: "/", PrivateKey: keys.PrivateKey, Secret: keys.Secret},
+		{Name: "weather-echo", Hostname: "weather.example.com", Path: "/echo", PrivateKey: keys.PrivateKey, Secret: keys.Secret},
+	}}
+	h := newHost()
+	for _, sc := range cfg.Skills {
+		h.Handle(sc.Name, nameHandler(sc.Name))
+	}
+	if err := h.Mount(cfg); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	tests := []struct {
+		hostname, path string
+		wantStatus     int
+		wantSkill      string
+	}{
+		{path: "/echo", wantStatus: http.StatusOK, wantSkill: "echo"},
+		{path: "/echo/", wantStatus: http.StatusOK, wantSkill: "echo"},
+		{path: "/", wantStatus: http.StatusOK, wantSkill: "root"},
+		{path: "/missing", wantStatus: http.StatusNotFound},
+		{hostname: "weather.example.com", path: "/", wantStatus: http.StatusOK, wantSkill: "weather"},
+		{hostname: "WEATHER.example.com:8443", path: "/", wantStatus: http.StatusOK, wantSkill: "weather"},
+		{hostname: "weather.example.com", path: "/echo", wantStatus: http.StatusOK, wantSkill: "weather-echo"},
+		{hostname: "other.example.com", path: "/echo", wantStatus: http.StatusOK, wantSkill: "echo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.hostname+tt.path, func(t *testing.T) {
+			status, skill := send(t, srv, keys, tt.hostname, tt.path)
+			if status != tt.wantStatus || skill != tt.wantSkill {
+				t.Errorf("got %d from %q, want %d from %q", status, skill, tt.wantStatus, tt.wantSkill)
+			}
+		})
+	}
+	if got := strings.Join(h.Names(), ","); got != "echo,root,weather,weather-echo" {
+		t.Errorf("Names = %s", got)
+	}
+	if s := h.Skill("echo"); s == nil || s.Name != "echo" {
+		t.Errorf("Skill(echo) = %v", s)
+	}
+	if s := h.Skill("missing"); s != nil {
+		t.Errorf("Skill(missing) = %v, want nil", s)
+	}
+}
+
+func TestMountProblems(t *testing.T) {
+	keys := newKeys(t)
+	h := newHost()
+	h.Handle("echo", nameHandler("echo"))
+	if err := h.Mount(&Config{Skills: []SkillConfig{{Name: "echo", Path: "/", PrivateKey: keys.PrivateKey, Secret: keys.Secret}}}); err != nil {
+		t.Fatal(err)
+	}
+	err := h.Mount(&Config{Skills: []SkillConfig{
+		{Name: "echo", Path: "/other", PrivateKey: keys.PrivateKey, Secret: keys.Secret},
+		{Name: "weather", Path: "/", PrivateKey: keys.PrivateKey, Secret: keys.Secret},
+	}})
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Mount error = %v, want a *config.ValidationError", err)
+	}
+	want := []string{
+		"skill echo: already mounted",
+		"skill weather: no handler registered",
+		"skill weather: / is already mounted",
+	}
+	if strings.Join(verr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("problems = %q, want %q", verr.Problems, want)
+	}
+	if got := h.Names(); len(got) != 1 {
+		t.Errorf("skills mounted after a failed mount: %v", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	oldKeys, newKeys := newKeys(t), newKeys(t)
+	h := newHost()
+	h.Handle("echo", nameHandler("echo"))
+	if err := h.Mount(&Config{Skills: []SkillConfig{{Name: "echo", Path: "/echo", PrivateKey: oldKeys.PrivateKey, Secret: oldKeys.Secret}}}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for _, cfg := range []*Config{
+		{Skills: []SkillConfig{{Name: "weather", Path: "/weather", PrivateKey: newKeys.PrivateKey, Secret: newKeys.Secret}}},
+		{Skills: []SkillConfig{{Name: "echo", Path: "/moved", PrivateKey: newKeys.PrivateKey, Secret: newKeys.Secret}}},
+	} {
+		if err := h.Reload(cfg); err == nil {
+			t.Errorf("Reload of %s on %s didn't return an error", cfg.Skills[0].Name, cfg.Skills[0].Path)
+		}
+	}
+	if status, _ := send(t, srv, oldKeys, "", "/echo"); status != http.StatusOK {
+		t.Fatalf("failed reload changed the credentials, got %d", status)
+	}
+
+	if err := h.Reload(&Config{Skills: []SkillConfig{{Name: "echo", Path: "/echo", PrivateKey: newKeys.PrivateKey, Secret: newKeys.Secret}}}); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := send(t, srv, oldKeys, "", "/echo"); status == http.StatusOK {
+		t.Error("old credentials still accepted after reload")
+	}
+	if status, skill := send(t, srv, newKeys, "", "/echo"); status != http.StatusOK || skill != "echo" {
+		t.Errorf("new credentials got %d from %q", status, skill)
+	}
+}
+
+func TestMetricsLabelled(t *testing.T) {
+	keys := newKeys(t)
+	reg := prometheus.NewRegistry()
+	h := newHost()
+	h.Namespace = "test"
+	h.Registerer = reg
+	h.Handle("echo", nameHandler("echo"))
+	h.Handle("weather", nameHandler("weather"))
+	if err := h.Mount(&Config{Skills: []SkillConfig{
+		{Name: "echo", Path: "/echo", PrivateKey: keys.PrivateKey, Secret: keys.Secret},
+		{Name: "weather", Path: "/weather", PrivateKey: keys.PrivateKey, Secret: keys.Secret},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	send(t, srv, keys, "", "/echo")
+	send(t, srv, keys, "", "/echo")
+	send(t, srv, keys, "", "/weather")
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	turns := map[string]float64{}
+	for _, mf := range families {
+		if mf.GetName() != "test_turns_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "skill" {
+					turns[l.GetValue()] += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	if turns["echo"] != 2 || turns["weather"] != 1 {
+		t.Errorf("turns by skill = %v, want echo 2 and weather 1", turns)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	keys := newKeys(t)
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("private.pem", keys.PrivateKey)
+	writeFile("secret.txt", keys.Secret+"\n")
+
+	tests := []struct {
+		name         string
+		yaml         string
+		wantProblems []string
+	}{
+		{
+			name: "valid",
+			yaml: `
+skills:
+  - name: echo
+    path: echo/
+    private_key_file: private.pem
+    secret_file: secret.txt
+  - name: weather
+    hostname: Weather.Example.com
+    private_key_file: private.pem
+    secret: inline
+`,
+		},
+		{name: "no skills", yaml: "skills: []\n", wantProblems: []string{"no skills configured"}},
+		{
+			name: "every problem",
+			yaml: `
+skills:
+  - path: /a
+    private_key_file: private.pem
+    secret_file: secret.txt
+  - name: echo
+    path: /a
+    private_key: not a key
+    secret: has space
+  - name: echo
+    path: /b
+    private_key_file: missing.pem
+  - name: weather
+    path: /c
+    secret: s
+`,
+			wantProblems: []string{
+				"skill #1: name is required",
+				"skill echo: /a is already used by skill #1",
+				"skill echo: private key is not a PEM encoded RSA PRIVATE KEY",
+				"skill echo: secret must not contain whitespace",
+				"skill echo: name is used more than once",
+				"skill echo: private key: open",
+				"skill echo: secret or secret_file is required",
+				"skill weather: private_key or private_key_file is required",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "skills.yaml")
+			writeFile("skills.yaml", tt.yaml)
+			cfg, err := LoadConfig(path)
+			if tt.wantProblems == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				echo, weather := cfg.Skills[0], cfg.Skills[1]
+				if echo.Path != "/echo" || echo.PrivateKey != strings.TrimSpace(keys.PrivateKey) || echo.Secret != keys.Secret {
+					t.Errorf("echo has path %q and secret %q, or the wrong private key", echo.Path, echo.Secret)
+				}
+				if weather.Path != "/" || weather.Hostname != "weather.example.com" || weather.Secret != "inline" {
+					t.Errorf("weather has path %q, hostname %q and secret %q", weather.Path, weather.Hostname, weather.Secret)
+				}
+				files := cfg.Files()
+				if len(files) != 4 || files[0] != path || files[1] != filepath.Join(dir, "private.pem") {
+					t.Errorf("Files = %v", files)
+				}
+				return
+			}
+			var verr *config.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("LoadConfig error = %v, want a *config.ValidationError", err)
+			}
+			if len(verr.Problems) != len(tt.wantProblems) {
+				t.Fatalf("problems = %q, want %q", verr.Problems, tt.wantProblems)
+			}
+			for i, want := range tt.wantProblems {
+				if !strings.HasPrefix(verr.Problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, verr.Problems[i], want)
+				}
+			}
+		})
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("LoadConfig of a missing file didn't return an error")
+	}
+	writeFile("bad.yaml", "skills:\n  - name: echo\n    colour: blue\n")
+	if _, err := LoadConfig(filepath.Join(dir, "bad.yaml")); err == nil {
+		t.Error("LoadConfig with an unknown field didn't return an error")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		level   string
+		json    bool
+		debug   bool
+		info    bool
+		wantErr string
+	}{
+		{name: "defaults", info: true},
+		{name: "json", format: "json", json: true, info: true},
+		{name: "format is case insensitive", format: "JSON", json: true, info: true},
+		{name: "console", format: "console", info: true},
+		{name: "debug level", level: "debug", debug: true, info: true},
+		{name: "warn level", format: "json", level: "WARN", json: true},
+		{name: "error level", level: "error"},
+		{name: "invalid format", format: "xml", wantErr: `invalid log format "xml"`},
+		{name: "invalid level", level: "verbose", wantErr: `invalid log level "verbose"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, tt.format, tt.level)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			logger.Debug("debug message")
+			logger.Info("info message", "key", "value")
+			logger.Error("error message")
+			out := buf.String()
+			if strings.Contains(out, "debug message") != tt.debug || strings.Contains(out, "info message") != tt.info ||
+				!strings.Contains(out, "error message") {
+				t.Errorf("output at level %q:\n%s", tt.level, out)
+			}
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			var entry map[string]interface{}
+			isJSON := json.Unmarshal([]byte(lines[len(lines)-1]), &entry) == nil
+			if isJSON != tt.json {
+				t.Errorf("json output = %t, want %t:\n%s", isJSON, tt.json, out)
+			}
+			if !tt.json && !strings.Contains(out, "level=ERROR msg=\"error message\"") {
+				t.Errorf("console output:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ErrorLog(logger).Printf("http: TLS handshake error from %s", "1.2.3.4")
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "ERROR" || entry["msg"] != "http: TLS handshake error from 1.2.3.4" {
+		t.Errorf("entry = %v", entry)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Outcome values used for the outcome label on the turns counter.
+const (
+	OutcomeOK             = "ok"
+	OutcomeInvalidRequest = "invalid_request"
+	OutcomeBadSignature   = "bad_signature"
+	OutcomeDecryptFailure = "decrypt_failure"
+	OutcomeMalformedJSON  = "malformed_json"
+	OutcomeHandlerError   = "handler_error"
+	OutcomeThrottled      = "throttled"
+	OutcomeStale          = "stale"
+)
+
+// Metrics holds the prometheus collectors for a skill.  All metric names are prefixed with the
+// namespace provided to NewMetrics so that several skills can share one prometheus.  A nil
+// *Metrics is valid and records nothing.
+type Metrics struct {
+	turnDuration    prometheus.Histogram
+	decryptDuration prometheus.Histogram
+	handlerDuration prometheus.Histogram
+	turns           *prometheus.CounterVec
+	intents         *prometheus.CounterVec
+	fallbacks       prometheus.Counter
+	directives      *prometheus.CounterVec
+}
+
+// NewMetrics creates and registers the skill metrics with the given registerer, e.g.
+// prometheus.DefaultRegisterer.  The namespace will typically be the name of the skill and
+// will be sanitised to make it a valid metric name.  As with promauto, it will panic if the
+// metrics are already registered with the same namespace.
+func NewMetrics(namespace string, reg prometheus.Registerer) *Metrics {
+	ns := sanitiseMetricName(namespace)
+	f := promauto.With(reg)
+	return &Metrics{
+		turnDuration: f.NewHistogram(prometheus.HistogramOpts{
+			Namespace: ns,
+			Name:      "turn_duration_seconds",
+			Help:      "End to end time taken to process a turn from Webex Assistant",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		decryptDuration: f.NewHistogram(prometheus.HistogramOpts{
+			Namespace: ns,
+			Name:      "decrypt_duration_seconds",
+			Help:      "Time taken to decrypt the message from Webex Assistant",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		handlerDuration: f.NewHistogram(prometheus.HistogramOpts{
+			Namespace: ns,
+			Name:      "handler_duration_seconds",
+			Help:      "Time taken by the skill handler to build a response",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		turns: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "turns_total",
+			Help:      "The total number of turns received, by outcome",
+		}, []string{"outcome"}),
+		intents: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "intents_total",
+			Help:      "The total number of turns handled, by intent",
+		}, []string{"intent"}),
+		fallbacks: f.NewCounter(prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "fallbacks_total",
+			Help:      "The total number of turns where the skill fell back to a default response",
+		}),
+		directives: f.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns,
+			Name:      "directives_total",
+			Help:      "The total number of directives sent, by directive name",
+		}, []string{"directive"}),
+	}
+}
+
+func (m *Metrics) observeTurn(outcome string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.turns.WithLabelValues(outcome).Inc()
+	m.turnDuration.Observe(d.Seconds())
+}
+
+func (m *Metrics) observeDecrypt(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.decryptDuration.Observe(d.Seconds())
+}
+
+func (m *Metrics) observeHandler(d time.Duration, turn *Turn, resp WebexAssistantResponse) {
+	if m == nil {
+		return
+	}
+	m.handlerDuration.Observe(d.Seconds())
+	if turn.Intent != "" {
+		m.intents.WithLabelValues(turn.Intent).Inc()
+	}
+	if turn.Fallback {
+		m.fallbacks.Inc()
+	}
+	for _, d := range resp.Directives {
+		m.directives.WithLabelValues(d.Name.String()).Inc()
+	}
+}
+
+// sanitiseMetricName replaces any characters that aren't valid in a prometheus metric name.
+func sanitiseMetricName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gather returns the value of each counter and the sample count of each histogram in the
+// registry, keyed by the metric name and labels, e.g. "skill_turns_total{outcome=ok}".
+func gather(t *testing.T, reg *prometheus.Registry) map[string]float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for _, l := range m.GetLabel() {
+				labels = append(labels, l.GetName()+"="+l.GetValue())
+			}
+			sort.Strings(labels)
+			name := f.GetName()
+			if len(labels) > 0 {
+				name += "{" + strings.Join(labels, ",") + "}"
+			}
+			switch {
+			case m.GetCounter() != nil:
+				values[name] = m.GetCounter().GetValue()
+			case m.GetHistogram() != nil:
+				values[name] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+	return values
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics("my-skill", reg)
+	m.observeTurn(OutcomeOK, time.Millisecond)
+	m.observeTurn(OutcomeOK, time.Millisecond)
+	m.observeTurn(OutcomeThrottled, time.Millisecond)
+	m.observeDecrypt(time.Millisecond)
+	m.observeHandler(time.Millisecond, &Turn{Intent: "weather"}, textResponse("hello", ""))
+	m.observeHandler(time.Millisecond, &Turn{Fallback: true}, WebexAssistantResponse{})
+
+	want := map[string]float64{
+		"my_skill_turns_total{outcome=ok}":           2,
+		"my_skill_turns_total{outcome=throttled}":    1,
+		"my_skill_turn_duration_seconds":             3,
+		"my_skill_decrypt_duration_seconds":          1,
+		"my_skill_handler_duration_seconds":          2,
+		"my_skill_intents_total{intent=weather}":     1,
+		"my_skill_fallbacks_total":                   1,
+		"my_skill_directives_total{directive=reply}": 1,
+		"my_skill_directives_total{directive=speak}": 1,
+		"my_skill_directives_total{directive=sleep}": 1,
+	}
+	got := gather(t, reg)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("metrics:\n%v\nwant:\n%v", got, want)
+	}
+
+	// a nil *Metrics records nothing
+	var nilMetrics *Metrics
+	nilMetrics.observeTurn(OutcomeOK, time.Millisecond)
+	nilMetrics.observeDecrypt(time.Millisecond)
+	nilMetrics.observeHandler(time.Millisecond, &Turn{}, WebexAssistantResponse{})
+}
+
+func TestSkillMetrics(t *testing.T) {
+	privateKey, publicKey := testKeys(t)
+	handler := HandlerFunc(func(ctx context.Context, msg WebexAssistantMessage) (WebexAssistantResponse, error) {
+		turn := TurnFromContext(ctx)
+		turn.Intent = "echo"
+		turn.Fallback = msg.Text == ""
+		return textResponse(msg.Text, msg.Challenge), nil
+	})
+	skill := testSkill(t, privateKey, handler)
+	reg := prometheus.NewRegistry()
+	skill.Metrics = NewMetrics("echo", reg)
+
+	for _, req := range []*http.Request{
+		signedRequest(t, publicKey, "secret", `{"text":"hello"}`),
+		signedRequest(t, publicKey, "secret", `{"text":""}`),
+		signedRequest(t, publicKey, "wrong", `{"text":"hello"}`),
+		signedRequest(t, publicKey, "secret", `{"text":`),
+	} {
+		skill.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	got := gather(t, reg)
+	for name, want := range map[string]float64{
+		"echo_turns_total{outcome=ok}":             2,
+		"echo_turns_total{outcome=bad_signature}":  1,
+		"echo_turns_total{outcome=malformed_json}": 1,
+		"echo_turn_duration_seconds":               4,
+		"echo_decrypt_duration_seconds":            3,
+		"echo_handler_duration_seconds":            2,
+		"echo_intents_total{intent=echo}":          2,
+		"echo_fallbacks_total":                     1,
+		"echo_directives_total{directive=reply}":   2,
+	} {
+		if got[name] != want {
+			t.Errorf("%s = %v, want %v", name, got[name], want)
+		}
+	}
+}
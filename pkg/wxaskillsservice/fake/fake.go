@@ -0,0 +1,528 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake provides an in-memory Skills Service for testing code which uses the
+// wxaskillsservice client, and the wxa-cli commands, without the real service.
+package fake
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+)
+
+// Server is an in-memory Skills Service running on an httptest server.  Requests must have the
+// token as a bearer token, and can only access the skills of the developer.  Skills are
+// validated as they are created or updated.  A soft deleted skill is still listed, with
+// deleted set, but can't be updated or deleted again except by a hard delete, which removes it.
+type Server struct {
+	// URL is the base URL of the server, e.g. http://127.0.0.1:1234.
+	URL string
+
+	// DeveloperID is the developer whose skills are served.
+	DeveloperID string
+
+	// Token is the access token requests must use.  It is replaced when a token is refreshed
+	// or rotated, so use CurrentToken while the server is in use.
+	Token string
+
+	// ClientID, ClientSecret and RefreshToken are those of the OAuth integration allowed to
+	// refresh tokens at TokenURL.  Refreshing is disabled while RefreshToken is empty.
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+
+	// TokenLifetime is the lifetime reported for refreshed access tokens.  It defaults to 14
+	// days, as for Webex.
+	TokenLifetime time.Duration
+
+	// Now returns the time used for created_at, modified_at and deleted_at.  It defaults to
+	// time.Now.
+	Now func() time.Time
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	skills   map[string]*wxaskillsservice.Skill
+	order    []string
+	failures []failure
+	requests int
+}
+
+// NewServer starts and returns a server for the developer, which accepts the token.  The
+// caller should call Close when finished, to shut it down.
+func NewServer(developerID, token string) *Server {
+	s := &Server{
+		DeveloperID: developerID,
+		Token:       token,
+		Now:         time.Now,
+		skills:      make(map[string]*wxaskillsservice.Skill),
+	}
+	s.server = httptest.NewServer(s.routes())
+	s.URL = s.server.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// BaseURL returns the base URL for the developer's API, as used by wxaskillsservice.Client.
+func (s *Server) BaseURL() string {
+	return fmt.Sprintf("%s/skills/api/developers/%s", s.URL, s.DeveloperID)
+}
+
+// TokenURL returns the URL of the OAuth token endpoint, for OAuthTokenSource.TokenURL.
+func (s *Server) TokenURL() string {
+	return s.URL + "/v1/access_token"
+}
+
+// Client returns a client for the server.
+func (s *Server) Client() *wxaskillsservice.Client {
+	return &wxaskillsservice.Client{
+		BaseURL:     s.BaseURL(),
+		DeveloperID: s.DeveloperID,
+		Token:       s.CurrentToken(),
+		HTTPClient:  s.server.Client(),
+	}
+}
+
+// CurrentToken returns the access token requests must use.
+func (s *Server) CurrentToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Token
+}
+
+// RotateToken replaces the access token with a new one, which it returns, as if the current
+// one had expired or been revoked.  Requests using the old token fail with 401 Unauthorized.
+func (s *Server) RotateToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Token = newID()
+	return s.Token
+}
+
+// AddSkill adds the skill without validating it, as if it had been created earlier, and
+// returns it with the id and other fields the service sets.
+func (s *Server) AddSkill(skill wxaskillsservice.Skill) wxaskillsservice.Skill {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return withoutSecret(s.add(skill))
+}
+
+// Skills returns every skill, including those which are soft deleted, in the order they were
+// created.  Unlike the API, the secrets are included.
+func (s *Server) Skills() []wxaskillsservice.Skill {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skills := make([]wxaskillsservice.Skill, len(s.order))
+	for i, id := range s.order {
+		skills[i] = copySkill(s.skills[id])
+	}
+	return skills
+}
+
+// failure is a response to a request which is made to fail.
+type failure struct {
+	status     int
+	retryAfter time.Duration
+}
+
+// FailNext makes the next request fail with the status, e.g. to test how errors are handled.
+// Each call adds another failure, so FailNext(503) twice fails the next two requests.
+func (s *Server) FailNext(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{status: status})
+}
+
+// ThrottleNext makes the next request fail with 429 Too Many Requests, asking the client to
+// retry after the delay, which is rounded up to whole seconds.
+func (s *Server) ThrottleNext(retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{status: http.StatusTooManyRequests, retryAfter: retryAfter})
+}
+
+// Requests returns the number of requests the server has received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) routes() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /skills/api/developers/{developer}/skills", s.handleList)
+	api.HandleFunc("POST /skills/api/developers/{developer}/skills", s.handleCreate)
+	api.HandleFunc("GET /skills/api/developers/{developer}/skills/{id}", s.handleGet)
+	api.HandleFunc("PATCH /skills/api/developers/{developer}/skills/{id}", s.handleUpdate)
+	api.HandleFunc("DELETE /skills/api/developers/{developer}/skills/{id}", s.handleDelete)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/access_token", s.handleToken)
+	mux.Handle("/", s.authenticate(api))
+	return mux
+}
+
+// authenticate checks the token and developer, and injects any failures.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		var fail failure
+		if len(s.failures) > 0 {
+			fail, s.failures = s.failures[0], s.failures[1:]
+		}
+		token := s.Token
+		s.mu.Unlock()
+		w.Header().Set("TrackingID", "FAKE_"+newID())
+		if fail.status != 0 {
+			if fail.retryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int((fail.retryAfter+time.Second-1)/time.Second)))
+			}
+			writeError(w, fail.status, http.StatusText(fail.status))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// developer checks the request is for the developer the token belongs to.
+func (s *Server) developer(w http.ResponseWriter, r *http.Request) bool {
+	if r.PathValue("developer") != s.DeveloperID {
+		writeError(w, http.StatusForbidden, "token doesn't belong to the developer")
+		return false
+	}
+	return true
+}
+
+// handleToken refreshes the access token using the integration's refresh token, replacing the
+// current token, as the Webex OAuth token endpoint does.
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid form")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "refresh_token" {
+		writeError(w, http.StatusBadRequest, "unsupported grant_type")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.RefreshToken == "" || r.PostForm.Get("client_id") != s.ClientID ||
+		r.PostForm.Get("client_secret") != s.ClientSecret || r.PostForm.Get("refresh_token") != s.RefreshToken {
+		writeError(w, http.StatusBadRequest, "invalid client or refresh token")
+		return
+	}
+	lifetime := s.TokenLifetime
+	if lifetime == 0 {
+		lifetime = 14 * 24 * time.Hour
+	}
+	s.Token = newID()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token":             s.Token,
+		"expires_in":               int(lifetime / time.Second),
+		"refresh_token":            s.RefreshToken,
+		"refresh_token_expires_in": 90 * 24 * 60 * 60,
+	})
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	if !s.developer(w, r) {
+		return
+	}
+	s.mu.Lock()
+	skills := s.list()
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, skills)
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if !s.developer(w, r) {
+		return
+	}
+	var skill wxaskillsservice.Skill
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&skill); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid json: %s", err))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if problems := s.validate(skill, ""); len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+	writeJSON(w, http.StatusCreated, withoutSecret(s.add(skill)))
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !s.developer(w, r) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skill, ok := s.skills[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "skill not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, withoutSecret(skill))
+}
+
+// handleUpdate changes the fields of the skill which are set in the request.  Soft deleted
+// skills can't be updated.
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	if !s.developer(w, r) {
+		return
+	}
+	var changes wxaskillsservice.Skill
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&changes); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid json: %s", err))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := r.PathValue("id")
+	skill, ok := s.skills[id]
+	if !ok || *skill.Deleted {
+		writeError(w, http.StatusNotFound, "skill not found")
+		return
+	}
+	if problems := s.validate(changes, id); len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+	// merge the changes by decoding them over a copy of the skill
+	updated := copySkill(skill)
+	js, _ := json.Marshal(changes)
+	json.Unmarshal(js, &updated)
+	now := s.now()
+	updated.ModifiedAt = &now
+	s.skills[id] = &updated
+	writeJSON(w, http.StatusOK, withoutSecret(&updated))
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	if !s.developer(w, r) {
+		return
+	}
+	hardDelete := false
+	if v := r.URL.Query().Get("HARD_DELETE"); v != "" {
+		var err error
+		if hardDelete, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "HARD_DELETE must be true or false")
+			return
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	skill, ok := s.skills[r.PathValue("id")]
+	if !ok || (*skill.Deleted && !hardDelete) {
+		writeError(w, http.StatusNotFound, "skill not found")
+		return
+	}
+	if hardDelete {
+		s.remove(*skill.SkillID)
+	} else {
+		now := s.now()
+		skill.Deleted = wxaskillsservice.Bool(true)
+		skill.DeletedAt = &now
+		skill.ModifiedAt = &now
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validate returns the problems with a skill being created, or with the fields being changed
+// when the skill with the id is updated.  The caller must hold the lock.
+func (s *Server) validate(skill wxaskillsservice.Skill, id string) []string {
+	update := id != ""
+	var problems []string
+	if skill.SkillID != nil || skill.DeveloperID != nil || skill.CreatedAt != nil || skill.Deleted != nil {
+		problems = append(problems, "skill_id, developer_id, created_at and deleted are set by the service")
+	}
+	if !update || skill.Name != nil {
+		if value(skill.Name) == "" {
+			problems = append(problems, "name is required")
+		} else {
+			for _, existing := range s.skills {
+				if *existing.SkillID != id && !*existing.Deleted && strings.EqualFold(*existing.Name, value(skill.Name)) {
+					problems = append(problems, fmt.Sprintf("a skill named %q already exists", *skill.Name))
+					break
+				}
+			}
+		}
+	}
+	if !update || skill.URL != nil {
+		if u, err := url.Parse(value(skill.URL)); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "url must be an absolute http or https url")
+		}
+	}
+	if !update || skill.ContactEmail != nil {
+		if _, err := mail.ParseAddress(value(skill.ContactEmail)); err != nil {
+			problems = append(problems, "contact_email must be a valid email address")
+		}
+	}
+	if (!update || skill.Secret != nil) && value(skill.Secret) == "" {
+		problems = append(problems, "secret is required")
+	}
+	if !update || skill.PublicKey != nil {
+		if err := validatePublicKey(value(skill.PublicKey)); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	for _, lang := range skill.Languages {
+		if strings.TrimSpace(lang) == "" {
+			problems = append(problems, "languages must not be empty")
+			break
+		}
+	}
+	return problems
+}
+
+func validatePublicKey(key string) error {
+	block, _ := pem.Decode([]byte(key))
+	if block == nil {
+		return fmt.Errorf("public_key must be a PEM encoded public key")
+	}
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return fmt.Errorf("public_key must be a PEM encoded public key")
+	}
+	return nil
+}
+
+// add stores the skill, setting the fields the service sets.  The caller must hold the lock.
+func (s *Server) add(skill wxaskillsservice.Skill) *wxaskillsservice.Skill {
+	now := s.now()
+	stored := copySkill(&skill)
+	if stored.SkillID == nil {
+		stored.SkillID = wxaskillsservice.String(newID())
+	}
+	stored.DeveloperID = wxaskillsservice.String(s.DeveloperID)
+	if stored.Name == nil {
+		stored.Name = wxaskillsservice.String("")
+	}
+	if stored.URL == nil {
+		stored.URL = wxaskillsservice.String("")
+	}
+	if stored.ContactEmail == nil {
+		stored.ContactEmail = wxaskillsservice.String("")
+	}
+	if stored.Public == nil {
+		stored.Public = wxaskillsservice.Bool(false)
+	}
+	if stored.Deleted == nil {
+		stored.Deleted = wxaskillsservice.Bool(false)
+	}
+	if len(stored.Languages) == 0 {
+		stored.Languages = []string{"en"}
+	}
+	if stored.CreatedAt == nil {
+		stored.CreatedAt = &now
+	}
+	stored.ModifiedAt = &now
+	if _, exists := s.skills[*stored.SkillID]; !exists {
+		s.order = append(s.order, *stored.SkillID)
+	}
+	s.skills[*stored.SkillID] = &stored
+	return &stored
+}
+
+// remove removes the skill.  The caller must hold the lock.
+func (s *Server) remove(id string) {
+	delete(s.skills, id)
+	for i, o := range s.order {
+		if o == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// list returns the skills in the order they were created, without their secrets.  The caller
+// must hold the lock.
+func (s *Server) list() []wxaskillsservice.Skill {
+	skills := make([]wxaskillsservice.Skill, len(s.order))
+	for i, id := range s.order {
+		skills[i] = withoutSecret(s.skills[id])
+	}
+	return skills
+}
+
+func (s *Server) now() string {
+	return s.Now().UTC().Format(time.RFC3339)
+}
+
+// copySkill returns a copy of the skill which doesn't share pointers with it.
+func copySkill(skill *wxaskillsservice.Skill) wxaskillsservice.Skill {
+	js, _ := json.Marshal(skill)
+	var c wxaskillsservice.Skill
+	json.Unmarshal(js, &c)
+	return c
+}
+
+// withoutSecret returns a copy of the skill without the secret, which the service never
+// returns.
+func withoutSecret(skill *wxaskillsservice.Skill) wxaskillsservice.Skill {
+	c := copySkill(skill)
+	c.Secret = nil
+	return c
+}
+
+func value(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(*s)
+}
+
+// newID returns a random version 4 UUID.
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
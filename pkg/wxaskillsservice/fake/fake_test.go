@@ -0,0 +1,311 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+)
+
+func publicKey(t *testing.T) string {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b}))
+}
+
+func validSkill(t *testing.T, name string) wxaskillsservice.Skill {
+	return wxaskillsservice.Skill{
+		Name:         wxaskillsservice.String(name),
+		URL:          wxaskillsservice.String("https://example.com/skill"),
+		ContactEmail: wxaskillsservice.String("dev@example.com"),
+		Secret:       wxaskillsservice.String("secret"),
+		PublicKey:    wxaskillsservice.String(publicKey(t)),
+	}
+}
+
+// apiError returns the APIError from err, failing the test if it isn't one.
+func apiError(t *testing.T, err error) *wxaskillsservice.APIError {
+	t.Helper()
+	var apiErr *wxaskillsservice.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want an APIError", err)
+	}
+	return apiErr
+}
+
+// clock returns a Now function which starts at a fixed time and advances a minute on each call.
+func clock() func() time.Time {
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	return func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	srv := NewServer("dev", "token")
+	defer srv.Close()
+	srv.Now = clock()
+	client := srv.Client()
+	ctx := context.Background()
+
+	created, err := client.CreateSkill(ctx, validSkill(t, "Echo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.SkillID == nil || *created.DeveloperID != "dev" || *created.Deleted || created.Secret != nil ||
+		*created.CreatedAt != "2021-06-01T12:01:00Z" || len(created.Languages) != 1 || created.Languages[0] != "en" {
+		t.Errorf("created = %+v", created)
+	}
+	id := *created.SkillID
+
+	got, err := client.GetSkill(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got.Name != "Echo" || got.Secret != nil {
+		t.Errorf("got = %+v", got)
+	}
+
+	updated, err := client.UpdateSkill(ctx, id, wxaskillsservice.Skill{URL: wxaskillsservice.String("https://example.ngrok.io")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *updated.URL != "https://example.ngrok.io" || *updated.Name != "Echo" || *updated.CreatedAt != *created.CreatedAt ||
+		*updated.ModifiedAt == *created.ModifiedAt {
+		t.Errorf("updated = %+v", updated)
+	}
+
+	added := srv.AddSkill(wxaskillsservice.Skill{Name: wxaskillsservice.String("Added")})
+	skills, err := client.ListSkills(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(skills) != 2 || *skills[0].SkillID != id || *skills[1].SkillID != *added.SkillID || skills[0].Secret != nil {
+		t.Errorf("skills = %+v", skills)
+	}
+	if all := srv.Skills(); len(all) != 2 || *all[0].Secret != "secret" {
+		t.Errorf("server skills = %+v", all)
+	}
+
+	_, err = client.GetSkill(ctx, "missing")
+	if apiErr := apiError(t, err); apiErr.StatusCode != http.StatusNotFound || !errors.Is(err, wxaskillsservice.ErrNotFound) ||
+		!strings.HasPrefix(apiErr.RequestID, "FAKE_") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	srv := NewServer("dev", "token")
+	defer srv.Close()
+	srv.Now = clock()
+	client := srv.Client()
+	ctx := context.Background()
+	created, err := client.CreateSkill(ctx, validSkill(t, "Echo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := *created.SkillID
+
+	// a soft deleted skill is still listed but can't be changed or deleted again
+	if err := client.DeleteSkill(ctx, id, false); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.GetSkill(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !*got.Deleted || got.DeletedAt == nil {
+		t.Errorf("soft deleted skill = %+v", got)
+	}
+	if _, err := client.UpdateSkill(ctx, id, wxaskillsservice.Skill{Name: wxaskillsservice.String("Other")}); !errors.Is(err, wxaskillsservice.ErrNotFound) {
+		t.Errorf("update of soft deleted skill: err = %v", err)
+	}
+	if err := client.DeleteSkill(ctx, id, false); !errors.Is(err, wxaskillsservice.ErrNotFound) {
+		t.Errorf("second soft delete: err = %v", err)
+	}
+
+	// its name can be used again
+	if _, err := client.CreateSkill(ctx, validSkill(t, "echo")); err != nil {
+		t.Errorf("create with the name of a soft deleted skill: %s", err)
+	}
+
+	// a hard delete removes it
+	if err := client.DeleteSkill(ctx, id, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetSkill(ctx, id); !errors.Is(err, wxaskillsservice.ErrNotFound) {
+		t.Errorf("get of hard deleted skill: err = %v", err)
+	}
+	if err := client.DeleteSkill(ctx, id, true); !errors.Is(err, wxaskillsservice.ErrNotFound) {
+		t.Errorf("second hard delete: err = %v", err)
+	}
+	if skills := srv.Skills(); len(skills) != 1 || *skills[0].Name != "echo" {
+		t.Errorf("skills = %+v", skills)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	srv := NewServer("dev", "token")
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+	existing, err := client.CreateSkill(ctx, validSkill(t, "Existing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := client.CreateSkill(ctx, validSkill(t, "Other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		update bool
+		change func(s *wxaskillsservice.Skill)
+		want   string
+	}{
+		{name: "missing name", change: func(s *wxaskillsservice.Skill) { s.Name = nil }, want: "name is required"},
+		{name: "blank name", change: func(s *wxaskillsservice.Skill) { s.Name = wxaskillsservice.String("  ") }, want: "name is required"},
+		{name: "duplicate name", change: func(s *wxaskillsservice.Skill) { s.Name = wxaskillsservice.String("EXISTING") }, want: `a skill named "EXISTING" already exists`},
+		{name: "relative url", change: func(s *wxaskillsservice.Skill) { s.URL = wxaskillsservice.String("/skill") }, want: "url must be an absolute http or https url"},
+		{name: "ftp url", change: func(s *wxaskillsservice.Skill) { s.URL = wxaskillsservice.String("ftp://example.com") }, want: "url must be an absolute http or https url"},
+		{name: "invalid email", change: func(s *wxaskillsservice.Skill) { s.ContactEmail = wxaskillsservice.String("dev") }, want: "contact_email must be a valid email address"},
+		{name: "missing secret", change: func(s *wxaskillsservice.Skill) { s.Secret = nil }, want: "secret is required"},
+		{name: "invalid public key", change: func(s *wxaskillsservice.Skill) { s.PublicKey = wxaskillsservice.String("key") }, want: "public_key must be a PEM encoded public key"},
+		{name: "empty language", change: func(s *wxaskillsservice.Skill) { s.Languages = []string{"en", ""} }, want: "languages must not be empty"},
+		{name: "service fields", change: func(s *wxaskillsservice.Skill) { s.SkillID = wxaskillsservice.String("id") }, want: "skill_id, developer_id, created_at and deleted are set by the service"},
+		{
+			name: "several problems",
+			change: func(s *wxaskillsservice.Skill) {
+				s.Name = nil
+				s.Secret = nil
+			},
+			want: "name is required; secret is required",
+		},
+		{name: "update to duplicate name", update: true, change: func(s *wxaskillsservice.Skill) { s.Name = wxaskillsservice.String("existing") }, want: `a skill named "existing" already exists`},
+		{name: "update to invalid url", update: true, change: func(s *wxaskillsservice.Skill) { s.URL = wxaskillsservice.String("example.com") }, want: "url must be an absolute http or https url"},
+		{name: "update to its own name", update: true, change: func(s *wxaskillsservice.Skill) { s.Name = wxaskillsservice.String("OTHER") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			if tt.update {
+				var s wxaskillsservice.Skill
+				tt.change(&s)
+				_, err = client.UpdateSkill(ctx, *other.SkillID, s)
+			} else {
+				s := validSkill(t, "New")
+				tt.change(&s)
+				_, err = client.CreateSkill(ctx, s)
+			}
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if apiErr := apiError(t, err); apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != tt.want {
+				t.Errorf("err = %v, want 400 %q", err, tt.want)
+			}
+		})
+	}
+	if skills := srv.Skills(); len(skills) != 2 || *skills[0].SkillID != *existing.SkillID || *skills[1].Name != "OTHER" {
+		t.Errorf("skills = %+v", skills)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	srv := NewServer("dev", "token")
+	defer srv.Close()
+	ctx := context.Background()
+
+	client := srv.Client()
+	client.Token = "wrong"
+	_, err := client.ListSkills(ctx)
+	if apiErr := apiError(t, err); apiErr.StatusCode != http.StatusUnauthorized || !errors.Is(err, wxaskillsservice.ErrUnauthorized) {
+		t.Errorf("wrong token: err = %v", err)
+	}
+
+	client = srv.Client()
+	client.BaseURL = strings.TrimSuffix(client.BaseURL, "/dev") + "/other"
+	_, err = client.ListSkills(ctx)
+	if apiErr := apiError(t, err); apiErr.StatusCode != http.StatusForbidden || !errors.Is(err, wxaskillsservice.ErrForbidden) {
+		t.Errorf("other developer: err = %v", err)
+	}
+
+	client = srv.Client()
+	token := srv.RotateToken()
+	if token == "token" || srv.CurrentToken() != token {
+		t.Errorf("rotated token = %q, current token %q", token, srv.CurrentToken())
+	}
+	if _, err := client.ListSkills(ctx); !errors.Is(err, wxaskillsservice.ErrUnauthorized) {
+		t.Errorf("rotated token: err = %v", err)
+	}
+	if _, err := srv.Client().ListSkills(ctx); err != nil {
+		t.Errorf("new token: %s", err)
+	}
+}
+
+func TestInjectedFailures(t *testing.T) {
+	srv := NewServer("dev", "token")
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	srv.FailNext(http.StatusServiceUnavailable)
+	srv.FailNext(http.StatusInternalServerError)
+	srv.ThrottleNext(1500 * time.Millisecond)
+	for _, want := range []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusTooManyRequests} {
+		_, err := client.ListSkills(ctx)
+		apiErr := apiError(t, err)
+		if apiErr.StatusCode != want {
+			t.Errorf("status %d, want %d", apiErr.StatusCode, want)
+		}
+		if want == http.StatusTooManyRequests && apiErr.RetryAfter != 2*time.Second {
+			t.Errorf("retry after %s, want 2s", apiErr.RetryAfter)
+		}
+	}
+	if _, err := client.ListSkills(ctx); err != nil {
+		t.Errorf("after failures: %s", err)
+	}
+	if n := srv.Requests(); n != 4 {
+		t.Errorf("%d requests, want 4", n)
+	}
+
+	// failures are injected even if the token is wrong
+	srv.FailNext(http.StatusBadGateway)
+	client.Token = "wrong"
+	if _, err := client.ListSkills(ctx); apiError(t, err).StatusCode != http.StatusBadGateway {
+		t.Errorf("err = %v, want 502", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxaskillsservice
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultRegion is the region of the skills service used by NewClient.
+const DefaultRegion = "us-east-2"
+
+// regionPattern matches region names such as us-east-2.
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]+$`)
+
+// options holds the settings from the options given to NewClient.
+type options struct {
+	region  string
+	baseURL string
+	retry   *RetryPolicy
+
+	tokenSource TokenSource
+}
+
+// Option configures a client created with NewClient.
+type Option func(*options) error
+
+// WithRegion uses the skills service in the region, e.g. "us-east-2".
+func WithRegion(region string) Option {
+	return func(o *options) error {
+		if !regionPattern.MatchString(region) {
+			return fmt.Errorf("invalid region %q", region)
+		}
+		o.region = region
+		return nil
+	}
+}
+
+// WithBaseURL uses the skills service at the base URL instead of the one for the region, e.g.
+// for a staging environment or a fake.  The URL is that of the service, such as
+// "https://assistant.us-east-2.intelligence.webex.com", to which the path for the developer
+// is added.
+func WithBaseURL(baseURL string) Option {
+	return func(o *options) error {
+		u, err := url.Parse(baseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid base url %q", baseURL)
+		}
+		o.baseURL = strings.TrimRight(baseURL, "/")
+		return nil
+	}
+}
+
+// WithRetryPolicy uses the policy for retrying failed requests instead of DefaultRetryPolicy.
+// Use nil to only attempt each request once.
+func WithRetryPolicy(p *RetryPolicy) Option {
+	return func(o *options) error {
+		o.retry = p
+		return nil
+	}
+}
+
+// WithTokenSource gets the access token for each request from the token source instead of
+// using the token given to NewClient, e.g. EnvToken or an OAuthTokenSource.
+func WithTokenSource(ts TokenSource) Option {
+	return func(o *options) error {
+		if ts == nil {
+			return errors.New("token source required")
+		}
+		o.tokenSource = ts
+		return nil
+	}
+}
+
+// serviceURL returns the URL of the skills service for the options.
+func (o *options) serviceURL() string {
+	if o.baseURL != "" {
+		return o.baseURL
+	}
+	return fmt.Sprintf("https://assistant.%s.intelligence.webex.com", o.region)
+}
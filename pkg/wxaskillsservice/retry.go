@@ -0,0 +1,157 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxaskillsservice
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy decides whether and when failed requests are retried.  Requests are retried
+// after network errors and 429, 500, 502, 503 and 504 responses, waiting for an exponentially
+// increasing backoff with jitter, or for as long as the service asks with Retry-After.
+//
+// By default, only idempotent requests, such as GET and DELETE, are retried, except after a
+// 429 response, which means the service rejected the request without processing it.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	MaxAttempts int
+
+	// InitialBackoff is how long to wait before the first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff is the longest to wait between attempts, unless the service asks for longer
+	// with Retry-After.
+	MaxBackoff time.Duration
+
+	// Multiplier is how much the backoff increases after each attempt.
+	Multiplier float64
+
+	// Jitter is the fraction of the backoff which is randomised, from 0 to 1, so that clients
+	// don't retry in step.
+	Jitter float64
+
+	// MaxElapsed is the longest to keep retrying for, from the start of the first attempt.
+	// Zero means there is no limit other than MaxAttempts and the context.
+	MaxElapsed time.Duration
+
+	// RetryNonIdempotent retries requests such as POST, which may have been processed even
+	// though they failed, as well as idempotent ones.
+	RetryNonIdempotent bool
+
+	// OnAttempt, if set, is called after each attempt, e.g. to log or count retries.
+	OnAttempt func(Attempt)
+}
+
+// Attempt describes an attempt at a request, for observing retries.
+type Attempt struct {
+	// Number is the number of the attempt, starting at 1.
+	Number int
+
+	// Method and URL are those of the request.
+	Method string
+	URL    string
+
+	// StatusCode is the status code of the response, or zero if there wasn't one.
+	StatusCode int
+
+	// Err is the error from the attempt, or nil if it succeeded.
+	Err error
+
+	// Duration is how long the attempt took.
+	Duration time.Duration
+
+	// Backoff is how long the client will wait before the next attempt, or zero if the
+	// request won't be retried.
+	Backoff time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used by NewClient: four attempts, backing off from half
+// a second up to ten seconds, for at most a minute.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+		MaxElapsed:     time.Minute,
+	}
+}
+
+// retryable reports whether the attempt at a request with the method should be retried.
+func (p *RetryPolicy) retryable(method string, statusCode int, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if !p.RetryNonIdempotent && !idempotent(method) {
+		return false
+	}
+	switch statusCode {
+	case 0:
+		// no response, so the request couldn't be sent
+		return err != nil
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait after the attempt with the number, which starts at 1.
+func (p *RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= p.Multiplier
+		if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		backoff -= backoff * p.Jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// sleep waits for the duration, returning early with the error if the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxaskillsservice
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer responds to each request with the next status, repeating the last one, and
+// records the body of each request.
+type testServer struct {
+	*httptest.Server
+	header http.Header
+
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func newTestServer(t *testing.T, statuses ...int) *testServer {
+	s := &testServer{statuses: statuses, header: http.Header{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		status := s.statuses[0]
+		if len(s.statuses) > 1 {
+			s.statuses = s.statuses[1:]
+		}
+		s.bodies = append(s.bodies, string(body))
+		s.mu.Unlock()
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		switch {
+		case status >= 300:
+		case r.Method == http.MethodGet:
+			w.Write([]byte(`[]`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+// testClient returns a client for the server which retries quickly and records each attempt.
+func testClient(s *testServer, p *RetryPolicy) (*Client, *[]Attempt) {
+	var mu sync.Mutex
+	attempts := &[]Attempt{}
+	p.OnAttempt = func(a Attempt) {
+		mu.Lock()
+		defer mu.Unlock()
+		*attempts = append(*attempts, a)
+	}
+	return &Client{BaseURL: s.URL, Token: "token", HTTPClient: s.Client(), Retry: p}, attempts
+}
+
+func quickPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Multiplier: 2}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		statuses      []int
+		nonIdempotent bool
+		attempts      int
+		wantErr       error
+	}{
+		{name: "succeeds first time", method: http.MethodGet, statuses: []int{200}, attempts: 1},
+		{name: "retries server errors", method: http.MethodGet, statuses: []int{500, 502, 503, 200}, attempts: 4},
+		{name: "gives up after max attempts", method: http.MethodGet, statuses: []int{504}, attempts: 4, wantErr: ErrUnavailable},
+		{name: "doesn't retry client errors", method: http.MethodGet, statuses: []int{400, 200}, attempts: 1, wantErr: ErrBadRequest},
+		{name: "retries idempotent delete", method: http.MethodDelete, statuses: []int{503, 204}, attempts: 2},
+		{name: "doesn't retry post", method: http.MethodPost, statuses: []int{503, 201}, attempts: 1, wantErr: ErrUnavailable},
+		{name: "doesn't retry patch", method: http.MethodPatch, statuses: []int{500, 200}, attempts: 1, wantErr: ErrInternalError},
+		{name: "retries post after 429", method: http.MethodPost, statuses: []int{429, 201}, attempts: 2},
+		{name: "retries post if allowed", method: http.MethodPost, statuses: []int{503, 201}, nonIdempotent: true, attempts: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, tt.statuses...)
+			p := quickPolicy()
+			p.RetryNonIdempotent = tt.nonIdempotent
+			client, attempts := testClient(srv, p)
+			var err error
+			switch tt.method {
+			case http.MethodGet:
+				_, err = client.ListSkills(context.Background())
+			case http.MethodPost:
+				_, err = client.CreateSkill(context.Background(), Skill{Name: String("Echo")})
+			case http.MethodPatch:
+				_, err = client.UpdateSkill(context.Background(), "id", Skill{Name: String("Echo")})
+			case http.MethodDelete:
+				err = client.DeleteSkill(context.Background(), "id", false)
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if srv.attempts() != tt.attempts || len(*attempts) != tt.attempts {
+				t.Fatalf("%d attempts, %d observed, want %d", srv.attempts(), len(*attempts), tt.attempts)
+			}
+			last := (*attempts)[tt.attempts-1]
+			if last.Number != tt.attempts || last.Method != tt.method || last.Backoff != 0 {
+				t.Errorf("last attempt = %+v", last)
+			}
+			for i, body := range srv.bodies {
+				if body != srv.bodies[0] {
+					t.Errorf("attempt %d sent %q, want %q", i+1, body, srv.bodies[0])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	srv := newTestServer(t, 429, 200)
+	srv.header.Set("Retry-After", "1")
+	client, attempts := testClient(srv, quickPolicy())
+	start := time.Now()
+	if _, err := client.ListSkills(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the service asked for longer than MaxBackoff, which is respected
+	if len(*attempts) != 2 || (*attempts)[0].Backoff != time.Second || (*attempts)[0].StatusCode != 429 {
+		t.Errorf("attempts = %+v", *attempts)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want 1s", elapsed)
+	}
+
+	// unless waiting would take longer than MaxElapsed
+	srv = newTestServer(t, 429, 200)
+	srv.header.Set("Retry-After", "60")
+	p := quickPolicy()
+	p.MaxElapsed = time.Second
+	client, attempts = testClient(srv, p)
+	if _, err := client.ListSkills(context.Background()); !errors.Is(err, ErrTooManyRequests) {
+		t.Errorf("err = %v, want ErrTooManyRequests", err)
+	}
+	if len(*attempts) != 1 {
+		t.Errorf("attempts = %+v", *attempts)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	p := quickPolicy()
+	p.InitialBackoff = time.Minute
+	p.MaxBackoff = time.Minute
+
+	// cancelling the context stops the wait for the next attempt
+	srv := newTestServer(t, 503)
+	client, attempts := testClient(srv, p)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := client.ListSkills(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if time.Since(start) > 10*time.Second || len(*attempts) != 1 {
+		t.Errorf("took %s with attempts %+v", time.Since(start), *attempts)
+	}
+
+	// a backoff past the deadline isn't waited for, so the error from the service is returned
+	srv = newTestServer(t, 503)
+	client, attempts = testClient(srv, p)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := client.ListSkills(ctx); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("err = %v, want ErrUnavailable", err)
+	}
+	if len(*attempts) != 1 {
+		t.Errorf("attempts = %+v", *attempts)
+	}
+
+	// a cancelled request isn't retried
+	srv = newTestServer(t, 200)
+	client, attempts = testClient(srv, quickPolicy())
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.ListSkills(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if len(*attempts) != 1 || srv.attempts() != 0 {
+		t.Errorf("attempts = %+v", *attempts)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000, 1000} {
+		if got := p.backoff(attempt+1, 0); got != want*time.Millisecond {
+			t.Errorf("backoff(%d) = %s, want %s", attempt+1, got, want*time.Millisecond)
+		}
+	}
+	// the cap doesn't overflow after many attempts
+	if got := p.backoff(1000, 0); got != time.Second {
+		t.Errorf("backoff(1000) = %s, want 1s", got)
+	}
+	if got := p.backoff(1, 5*time.Second); got != 5*time.Second {
+		t.Errorf("backoff with Retry-After = %s, want 5s", got)
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(4, 0); got < 400*time.Millisecond || got > 800*time.Millisecond {
+			t.Fatalf("backoff with jitter = %s, want 400ms to 800ms", got)
+		}
+	}
+}
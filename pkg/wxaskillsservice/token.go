@@ -0,0 +1,301 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxaskillsservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTokenEnv is the environment variable EnvToken reads the token from by default.
+const DefaultTokenEnv = "WEBEX_TOKEN"
+
+// DefaultOAuthTokenURL is the Webex endpoint used to refresh OAuth access tokens.
+const DefaultOAuthTokenURL = "https://webexapis.com/v1/access_token"
+
+// TokenSource provides the access token sent with each request.
+type TokenSource interface {
+	// Token returns the current access token.
+	Token(ctx context.Context) (string, error)
+}
+
+// RefreshingTokenSource is a TokenSource which can get a new access token.  If the service
+// rejects a token with 401 Unauthorized, the client refreshes it and retries the request once.
+type RefreshingTokenSource interface {
+	TokenSource
+
+	// Refresh gets a new access token, replacing the current one.
+	Refresh(ctx context.Context) (string, error)
+}
+
+// StaticToken returns a token source which always returns the token, such as a personal access
+// token from developer.webex.com.
+func StaticToken(token string) TokenSource {
+	return staticToken(token)
+}
+
+type staticToken string
+
+func (t staticToken) Token(ctx context.Context) (string, error) {
+	if t == "" {
+		return "", errors.New("token required")
+	}
+	return string(t), nil
+}
+
+// EnvToken returns a token source which reads the token from the environment variable each
+// time it is used, so it can be changed without creating a new client.  If name is empty,
+// DefaultTokenEnv is used.
+func EnvToken(name string) TokenSource {
+	if name == "" {
+		name = DefaultTokenEnv
+	}
+	return envToken(name)
+}
+
+type envToken string
+
+func (t envToken) Token(ctx context.Context) (string, error) {
+	token := os.Getenv(string(t))
+	if token == "" {
+		return "", fmt.Errorf("token required in %s", string(t))
+	}
+	return token, nil
+}
+
+// OAuthTokenSource gets access tokens for a Webex integration using an OAuth refresh token.  An
+// access token is refreshed when it is about to expire, or when the service rejects it, and
+// cached in CacheFile, if set, so it can be reused by later runs.  Create it with
+// NewOAuthTokenSource.
+type OAuthTokenSource struct {
+	// ClientID and ClientSecret are those of the Webex integration.
+	ClientID     string
+	ClientSecret string
+
+	// RefreshToken is used to get access tokens.  If Webex returns a new refresh token, it
+	// replaces this one and is cached.  Cached tokens are only used while RefreshToken is the
+	// one they were obtained with, so changing it, e.g. after authorising again, takes effect.
+	RefreshToken string
+
+	// CacheFile, if set, is where the access and refresh tokens are cached between runs.
+	CacheFile string
+
+	// TokenURL is the endpoint used to refresh tokens.  It defaults to DefaultOAuthTokenURL.
+	TokenURL string
+
+	// HTTPClient is used to refresh tokens.  If nil, a client with a 10 second timeout is used.
+	HTTPClient *http.Client
+
+	// OnCacheError, if set, is called if a refreshed token can't be written to CacheFile, e.g.
+	// to log it.  The token is still used, so the request doesn't fail.
+	OnCacheError func(error)
+
+	mu     sync.Mutex
+	cached *cachedToken
+}
+
+// cachedToken is an access token with its expiry, as stored in the cache file.  Origin is a hash
+// of the configured refresh token the tokens were obtained with.
+type cachedToken struct {
+	AccessToken  string    `json:"access_token"`
+	Expiry       time.Time `json:"expiry"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Origin       string    `json:"origin,omitempty"`
+}
+
+// expiryMargin is how long before an access token expires that it is refreshed.
+const expiryMargin = time.Minute
+
+// NewOAuthTokenSource returns a token source for the integration with the client ID and secret,
+// which uses the refresh token and caches tokens in cacheFile, which may be empty.
+func NewOAuthTokenSource(clientID, clientSecret, refreshToken, cacheFile string) *OAuthTokenSource {
+	return &OAuthTokenSource{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RefreshToken: refreshToken,
+		CacheFile:    cacheFile,
+	}
+}
+
+// Token returns the current access token, from the cache file if there isn't one in memory,
+// refreshing it if it has expired.
+func (s *OAuthTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cached == nil && s.CacheFile != "" {
+		s.cached = s.readCache()
+	}
+	s.checkOrigin()
+	if s.cached != nil && time.Now().Add(expiryMargin).Before(s.cached.Expiry) {
+		return s.cached.AccessToken, nil
+	}
+	return s.refresh(ctx)
+}
+
+// Refresh gets a new access token using the refresh token.
+func (s *OAuthTokenSource) Refresh(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refresh(ctx)
+}
+
+// refreshRejected gets a new access token to replace the rejected one, unless it has already
+// been replaced, e.g. by a concurrent request which was also rejected.
+func (s *OAuthTokenSource) refreshRejected(ctx context.Context, rejected string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkOrigin()
+	if s.cached != nil && s.cached.AccessToken != rejected {
+		return s.cached.AccessToken, nil
+	}
+	return s.refresh(ctx)
+}
+
+// origin returns the hash of the configured refresh token stored with cached tokens, so the
+// refresh token itself isn't stored twice.
+func (s *OAuthTokenSource) origin() string {
+	sum := sha256.Sum256([]byte(s.RefreshToken))
+	return hex.EncodeToString(sum[:8])
+}
+
+// checkOrigin discards the cached tokens if they were obtained with a different refresh token
+// to the one configured.  The caller must hold the lock.
+func (s *OAuthTokenSource) checkOrigin() {
+	if s.cached != nil && s.cached.Origin != s.origin() {
+		s.cached = nil
+	}
+}
+
+// refresh gets a new access token and caches it.  The caller must hold the lock.
+func (s *OAuthTokenSource) refresh(ctx context.Context) (string, error) {
+	s.checkOrigin()
+	refreshToken := s.RefreshToken
+	if s.cached != nil && s.cached.RefreshToken != "" {
+		// a refresh token returned by Webex replaces the one we were given
+		refreshToken = s.cached.RefreshToken
+	}
+	if s.ClientID == "" || s.ClientSecret == "" || refreshToken == "" {
+		return "", errors.New("oauth: client id, client secret and refresh token required")
+	}
+	tokenURL := s.TokenURL
+	if tokenURL == "" {
+		tokenURL = DefaultOAuthTokenURL
+	}
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {s.ClientID},
+		"client_secret": {s.ClientSecret},
+		"refresh_token": {refreshToken},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("oauth: error refreshing token: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oauth: error refreshing token: %w", newAPIError(res))
+	}
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("oauth: invalid token response: %s", err)
+	}
+	if body.AccessToken == "" {
+		return "", errors.New("oauth: no access token in response")
+	}
+	cached := &cachedToken{
+		AccessToken:  body.AccessToken,
+		Expiry:       time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
+		RefreshToken: body.RefreshToken,
+		Origin:       s.origin(),
+	}
+	if cached.RefreshToken == "" {
+		cached.RefreshToken = refreshToken
+	}
+	s.cached = cached
+	if s.CacheFile != "" {
+		// the token is still valid if it can't be cached, so it is returned regardless
+		if err := s.writeCache(cached); err != nil && s.OnCacheError != nil {
+			s.OnCacheError(err)
+		}
+	}
+	return cached.AccessToken, nil
+}
+
+// readCache returns the token in the cache file, or nil if it can't be read.
+func (s *OAuthTokenSource) readCache() *cachedToken {
+	b, err := os.ReadFile(s.CacheFile)
+	if err != nil {
+		return nil
+	}
+	var cached cachedToken
+	if err := json.Unmarshal(b, &cached); err != nil || cached.AccessToken == "" {
+		return nil
+	}
+	return &cached
+}
+
+// writeCache writes the token to the cache file, readable only by the user, replacing it
+// atomically so a concurrent run never reads a partial file.
+func (s *OAuthTokenSource) writeCache(cached *cachedToken) error {
+	b, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(s.CacheFile)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("oauth: error creating token cache directory: %s", err)
+	}
+	f, err := os.CreateTemp(dir, ".token-*")
+	if err != nil {
+		return fmt.Errorf("oauth: error writing token cache: %s", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("oauth: error writing token cache: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("oauth: error writing token cache: %s", err)
+	}
+	if err := os.Rename(f.Name(), s.CacheFile); err != nil {
+		return fmt.Errorf("oauth: error writing token cache: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxaskillsservice_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice/fake"
+)
+
+func TestStaticToken(t *testing.T) {
+	if token, err := wxaskillsservice.StaticToken("token").Token(context.Background()); err != nil || token != "token" {
+		t.Errorf("Token = %q, %v", token, err)
+	}
+	if _, err := wxaskillsservice.StaticToken("").Token(context.Background()); err == nil {
+		t.Error("empty token didn't fail")
+	}
+}
+
+func TestEnvToken(t *testing.T) {
+	t.Setenv(wxaskillsservice.DefaultTokenEnv, "default")
+	t.Setenv("MY_TOKEN", "first")
+	ctx := context.Background()
+	if token, err := wxaskillsservice.EnvToken("").Token(ctx); err != nil || token != "default" {
+		t.Errorf("default Token = %q, %v", token, err)
+	}
+	ts := wxaskillsservice.EnvToken("MY_TOKEN")
+	if token, err := ts.Token(ctx); err != nil || token != "first" {
+		t.Errorf("Token = %q, %v", token, err)
+	}
+	// the variable is read each time
+	t.Setenv("MY_TOKEN", "second")
+	if token, err := ts.Token(ctx); err != nil || token != "second" {
+		t.Errorf("Token after change = %q, %v", token, err)
+	}
+	t.Setenv("MY_TOKEN", "")
+	if _, err := ts.Token(ctx); err == nil || err.Error() != "token required in MY_TOKEN" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+// newOAuthServer returns a fake service which refreshes tokens for the integration, and a
+// token source for it which caches tokens in cacheFile.
+func newOAuthServer(t *testing.T, cacheFile string) (*fake.Server, *wxaskillsservice.OAuthTokenSource) {
+	srv := fake.NewServer("dev", "initial")
+	t.Cleanup(srv.Close)
+	srv.ClientID, srv.ClientSecret, srv.RefreshToken = "client", "secret", "refresh"
+	return srv, newTokenSource(srv, cacheFile)
+}
+
+func newTokenSource(srv *fake.Server, cacheFile string) *wxaskillsservice.OAuthTokenSource {
+	ts := wxaskillsservice.NewOAuthTokenSource("client", "secret", "refresh", cacheFile)
+	ts.TokenURL = srv.TokenURL()
+	ts.HTTPClient = srv.Client().HTTPClient
+	return ts
+}
+
+func oauthClient(srv *fake.Server, ts wxaskillsservice.TokenSource) *wxaskillsservice.Client {
+	client := srv.Client()
+	client.Token = ""
+	client.TokenSource = ts
+	return client
+}
+
+func TestOAuthRefresh(t *testing.T) {
+	srv, ts := newOAuthServer(t, "")
+	client := oauthClient(srv, ts)
+	ctx := context.Background()
+
+	// the first token is refreshed before it is used
+	if _, err := client.ListSkills(ctx); err != nil {
+		t.Fatal(err)
+	}
+	token, err := ts.Token(ctx)
+	if err != nil || token == "initial" || token != srv.CurrentToken() {
+		t.Fatalf("Token = %q, %v, want %q", token, err, srv.CurrentToken())
+	}
+
+	// a token rejected with 401 is refreshed and the request retried once
+	srv.RotateToken()
+	requests := srv.Requests()
+	if _, err := client.ListSkills(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.Requests() - requests; n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+	if token, _ := ts.Token(ctx); token != srv.CurrentToken() {
+		t.Errorf("Token = %q, want %q", token, srv.CurrentToken())
+	}
+
+	// if the refreshed token is also rejected, the error is returned
+	srv.FailNext(401)
+	srv.FailNext(401)
+	requests = srv.Requests()
+	if _, err := client.ListSkills(ctx); !errors.Is(err, wxaskillsservice.ErrUnauthorized) {
+		t.Errorf("err = %v, want ErrUnauthorized", err)
+	}
+	if n := srv.Requests() - requests; n != 2 {
+		t.Errorf("%d requests, want 2", n)
+	}
+
+	// tokens from a static source aren't refreshed
+	srv.RotateToken()
+	static := oauthClient(srv, wxaskillsservice.StaticToken("old"))
+	requests = srv.Requests()
+	if _, err := static.ListSkills(ctx); !errors.Is(err, wxaskillsservice.ErrUnauthorized) {
+		t.Errorf("err = %v, want ErrUnauthorized", err)
+	}
+	if n := srv.Requests() - requests; n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestOAuthRefreshConcurrent(t *testing.T) {
+	srv, ts := newOAuthServer(t, filepath.Join(t.TempDir(), "token.json"))
+	client := oauthClient(srv, ts)
+	ctx := context.Background()
+	if _, err := client.ListSkills(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// every request is rejected with the old token, but only one refresh is needed
+	for round := 0; round < 5; round++ {
+		srv.RotateToken()
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		start := make(chan struct{})
+		for i := range errs {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_, errs[i] = client.ListSkills(ctx)
+			}()
+		}
+		close(start)
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("round %d, request %d: %s", round, i+1, err)
+			}
+		}
+	}
+}
+
+func TestOAuthCache(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "wxa-cli", "token.json")
+	srv, ts := newOAuthServer(t, cacheFile)
+	ctx := context.Background()
+	token, err := ts.Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("cache file mode %o, want 600", perm)
+	}
+	var cached struct {
+		AccessToken  string    `json:"access_token"`
+		Expiry       time.Time `json:"expiry"`
+		RefreshToken string    `json:"refresh_token"`
+	}
+	b, _ := os.ReadFile(cacheFile)
+	if err := json.Unmarshal(b, &cached); err != nil {
+		t.Fatal(err)
+	}
+	if cached.AccessToken != token || cached.RefreshToken != "refresh" || time.Until(cached.Expiry) < 13*24*time.Hour {
+		t.Errorf("cached = %+v", cached)
+	}
+
+	// a later run uses the cached token without refreshing it
+	srv.RefreshToken = ""
+	if got, err := newTokenSource(srv, cacheFile).Token(ctx); err != nil || got != token {
+		t.Errorf("Token from cache = %q, %v, want %q", got, err, token)
+	}
+
+	// unless it is about to expire
+	srv.RefreshToken = "refresh"
+	srv.TokenLifetime = 30 * time.Second
+	if _, err := newTokenSource(srv, cacheFile).Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	next, err := newTokenSource(srv, cacheFile).Token(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == token || next != srv.CurrentToken() {
+		t.Errorf("Token = %q, want a new token %q", next, srv.CurrentToken())
+	}
+
+	// a corrupt cache is ignored
+	if err := os.WriteFile(cacheFile, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := newTokenSource(srv, cacheFile).Token(ctx); err != nil || got != srv.CurrentToken() {
+		t.Errorf("Token with corrupt cache = %q, %v", got, err)
+	}
+}
+
+func TestOAuthCacheRefreshTokenChanged(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "token.json")
+	srv, ts := newOAuthServer(t, cacheFile)
+	ctx := context.Background()
+	srv.TokenLifetime = 30 * time.Second
+	if _, err := ts.Token(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// after authorising again, the old refresh token is revoked and the new one configured, so
+	// the cached refresh token mustn't be used instead
+	srv.RefreshToken = "reauthorised"
+	srv.TokenLifetime = 0
+	ts = newTokenSource(srv, cacheFile)
+	ts.RefreshToken = "reauthorised"
+	token, err := ts.Token(ctx)
+	if err != nil || token != srv.CurrentToken() {
+		t.Fatalf("Token = %q, %v, want %q", token, err, srv.CurrentToken())
+	}
+
+	// an unexpired access token cached for another refresh token isn't used either
+	srv.RefreshToken = "another"
+	ts = newTokenSource(srv, cacheFile)
+	ts.RefreshToken = "another"
+	next, err := ts.Token(ctx)
+	if err != nil || next == token || next != srv.CurrentToken() {
+		t.Errorf("Token = %q, %v, want a new token %q", next, err, srv.CurrentToken())
+	}
+
+	// changing the refresh token of a source in use also discards its tokens
+	srv.RefreshToken = "changed"
+	ts.RefreshToken = "changed"
+	if got, err := ts.Token(ctx); err != nil || got == next || got != srv.CurrentToken() {
+		t.Errorf("Token after change = %q, %v, want a new token %q", got, err, srv.CurrentToken())
+	}
+}
+
+func TestOAuthCacheWriteError(t *testing.T) {
+	// the cache can't be written because its directory is a file
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "wxa-cli"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	srv, ts := newOAuthServer(t, filepath.Join(dir, "wxa-cli", "token.json"))
+	var cacheErrs []error
+	ts.OnCacheError = func(err error) { cacheErrs = append(cacheErrs, err) }
+	ctx := context.Background()
+	token, err := ts.Token(ctx)
+	if err != nil || token != srv.CurrentToken() {
+		t.Fatalf("Token = %q, %v, want %q", token, err, srv.CurrentToken())
+	}
+	if len(cacheErrs) != 1 {
+		t.Errorf("cache errors = %v, want 1", cacheErrs)
+	}
+
+	// the token is kept in memory, so requests don't fail
+	if _, err := oauthClient(srv, ts).ListSkills(ctx); err != nil {
+		t.Error(err)
+	}
+	if got, _ := ts.Token(ctx); got != token {
+		t.Errorf("Token = %q, want %q", got, token)
+	}
+}
+
+func TestOAuthErrors(t *testing.T) {
+	srv, ts := newOAuthServer(t, "")
+	ts.ClientSecret = "wrong"
+	_, err := ts.Token(context.Background())
+	var apiErr *wxaskillsservice.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 || apiErr.Message != "invalid client or refresh token" {
+		t.Errorf("err = %v, want 400 APIError", err)
+	}
+
+	// the client returns the refresh error rather than the 401
+	srv.RotateToken()
+	if _, err := oauthClient(srv, ts).ListSkills(context.Background()); !errors.Is(err, wxaskillsservice.ErrBadRequest) {
+		t.Errorf("err = %v, want ErrBadRequest", err)
+	}
+
+	ts = wxaskillsservice.NewOAuthTokenSource("client", "", "refresh", "")
+	if _, err := ts.Token(context.Background()); err == nil || err.Error() != "oauth: client id, client secret and refresh token required" {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -19,7 +19,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -55,14 +58,143 @@ type Skill struct {
 
 // Error Constants
 const (
-	ErrBadRequest    = Err("api: bad request")
-	ErrUnauthorized  = Err("api: unauthorized request")
-	ErrForbidden     = Err("api: forbidden")
-	ErrNotFound      = Err("api: resource not found")
-	ErrInternalError = Err("api: internal error")
-	ErrUnknown       = Err("api: unexpected error occurred")
+	ErrBadRequest      = Err("api: bad request")
+	ErrUnauthorized    = Err("api: unauthorized request")
+	ErrForbidden       = Err("api: forbidden")
+	ErrNotFound        = Err("api: resource not found")
+	ErrTooManyRequests = Err("api: too many requests")
+	ErrInternalError   = Err("api: internal error")
+	ErrUnavailable     = Err("api: service unavailable")
+	ErrUnknown         = Err("api: unexpected error occurred")
 )
 
+// APIError is returned when the skills service responds with an error status.  It wraps one of
+// the error constants, so it can be checked with errors.Is, e.g. errors.Is(err, ErrBadRequest),
+// and provides the details from the response using errors.As.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Message is the error message from the service, if there was one.
+	Message string
+
+	// Details holds any further details of the error from the service, such as the problem
+	// with each field, as raw JSON.
+	Details json.RawMessage
+
+	// RequestID is the tracking ID of the request, which the service can use to find it.
+	RequestID string
+
+	// RetryAfter is how long the service asked the client to wait before retrying, or zero.
+	RetryAfter time.Duration
+
+	// Err is the error constant for the status code.
+	Err error
+}
+
+// Error returns the error constant's message followed by the message from the service.
+func (e *APIError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Err.Error())
+	if e.Message != "" {
+		b.WriteString(": ")
+		b.WriteString(e.Message)
+	}
+	if len(e.Details) > 0 {
+		b.WriteString(" ")
+		b.Write(e.Details)
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (tracking id %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+// Unwrap returns the error constant for the status code.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// requestIDHeaders are the response headers which may hold the tracking ID of a request.
+var requestIDHeaders = []string{"TrackingID", "X-Request-ID", "X-Trace-ID"}
+
+// newAPIError returns an error for the response, including the message and details from its
+// body if there are any.
+func newAPIError(res *http.Response) *APIError {
+	e := &APIError{StatusCode: res.StatusCode}
+	switch res.StatusCode {
+	case 400:
+		e.Err = ErrBadRequest
+	case 401:
+		e.Err = ErrUnauthorized
+	case 403:
+		e.Err = ErrForbidden
+	case 404:
+		e.Err = ErrNotFound
+	case 429:
+		e.Err = ErrTooManyRequests
+	case 500:
+		e.Err = ErrInternalError
+	case 502, 503, 504:
+		e.Err = ErrUnavailable
+	default:
+		e.Err = ErrUnknown
+	}
+	for _, h := range requestIDHeaders {
+		if id := res.Header.Get(h); id != "" {
+			e.RequestID = id
+			break
+		}
+	}
+	e.RetryAfter = retryAfter(res.Header.Get("Retry-After"), time.Now())
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	var fields struct {
+		Message    string          `json:"message"`
+		Error      string          `json:"error"`
+		Detail     string          `json:"detail"`
+		Details    json.RawMessage `json:"details"`
+		Errors     json.RawMessage `json:"errors"`
+		TrackingID string          `json:"trackingId"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		// not JSON, so use the body as the message if it is short enough to be one
+		if text := strings.TrimSpace(string(body)); len(text) <= 200 && !strings.HasPrefix(text, "<") {
+			e.Message = text
+		}
+		return e
+	}
+	for _, m := range []string{fields.Message, fields.Error, fields.Detail} {
+		if m != "" {
+			e.Message = m
+			break
+		}
+	}
+	if len(fields.Details) > 0 && string(fields.Details) != "null" {
+		e.Details = fields.Details
+	} else if len(fields.Errors) > 0 && string(fields.Errors) != "null" {
+		e.Details = fields.Errors
+	}
+	if e.RequestID == "" {
+		e.RequestID = fields.TrackingID
+	}
+	return e
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds or a date.
+func retryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
 // Client is the main client for interacting with the library.  It can be created using NewClient
 type Client struct {
 	// BaseURL for API.  Set using NewClient or you can set directly.
@@ -71,21 +203,41 @@ type Client struct {
 	// DeveloperID is the base64 decoded developer ID
 	DeveloperID string
 
-	// Token is the personal access token for interacting with the skills service
+	// Token is the personal access token for interacting with the skills service.  It is only used
+	// if TokenSource is nil.
 	Token string
 
+	// TokenSource provides the access token for each request, e.g. an OAuthTokenSource.  If it is
+	// a RefreshingTokenSource, the token is refreshed and the request retried once if the service
+	// responds with 401 Unauthorized.
+	TokenSource TokenSource
+
 	//HTTP Client to use for making requests, allowing the user to supply their own if required.
 	HTTPClient *http.Client
+
+	// Retry is the policy for retrying failed requests.  NewClient uses DefaultRetryPolicy.  If
+	// nil, each request is only attempted once.
+	Retry *RetryPolicy
 }
 
 // NewClient is a helper function that returns an new api client given a token and developer ID.
 // Optionally you can provide your own http client or use nil to use the default.  This is done to
 // ensure you're aware of the decision you're making to not provide your own http client.
-func NewClient(developerID, token string, client *http.Client) (*Client, error) {
-	if token == "" {
-		return nil, errors.New("token required")
-	}
+//
+// The client uses the skills service in DefaultRegion, unless WithRegion or WithBaseURL are
+// given, and retries failed requests using DefaultRetryPolicy unless WithRetryPolicy is given.
+// The token may be empty if WithTokenSource is given.
+func NewClient(developerID, token string, client *http.Client, opts ...Option) (*Client, error) {
 	if developerID == "" {
+		return nil, errors.New("developer id required")
+	}
+	o := &options{region: DefaultRegion, retry: DefaultRetryPolicy()}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	if token == "" && o.tokenSource == nil {
 		return nil, errors.New("token required")
 	}
 	if client == nil {
@@ -94,9 +246,12 @@ func NewClient(developerID, token string, client *http.Client) (*Client, error)
 		}
 	}
 	c := &Client{
-		BaseURL:    fmt.Sprintf("https://assistant.us-east-2.intelligence.webex.com/skills/api/developers/%s", developerID),
-		HTTPClient: client,
-		Token:      token,
+		BaseURL:     fmt.Sprintf("%s/skills/api/developers/%s", o.serviceURL(), url.PathEscape(developerID)),
+		DeveloperID: developerID,
+		HTTPClient:  client,
+		Token:       token,
+		TokenSource: o.tokenSource,
+		Retry:       o.retry,
 	}
 	return c, nil
 }
@@ -133,10 +288,54 @@ func (c *Client) CreateSkill(ctx context.Context, skill Skill) (*Skill, error) {
 	return s, nil
 }
 
+// GetSkill will get the skill with the given ID
+func (c *Client) GetSkill(ctx context.Context, id string) (*Skill, error) {
+	s := &Skill{}
+	url, err := c.skillURL(id)
+	if err != nil {
+		return s, err
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return s, err
+	}
+	if err := c.makeRequest(ctx, req, s); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// UpdateSkill will update the skill with the given ID.  Only the fields which are set are
+// changed, so the skill ID is kept, e.g. to change just the URL:
+//
+//	client.UpdateSkill(ctx, id, Skill{URL: String("https://example.ngrok.io")})
+func (c *Client) UpdateSkill(ctx context.Context, id string, skill Skill) (*Skill, error) {
+	s := &Skill{}
+	url, err := c.skillURL(id)
+	if err != nil {
+		return s, err
+	}
+	payload, err := json.Marshal(skill)
+	if err != nil {
+		return s, err
+	}
+	req, err := http.NewRequest("PATCH", url, strings.NewReader(string(payload)))
+	if err != nil {
+		return s, err
+	}
+	if err := c.makeRequest(ctx, req, s); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
 // DeleteSkill is used delete a skill. It is required to pass the ID.
 func (c *Client) DeleteSkill(ctx context.Context, id string, hardDelete bool) error {
-	url := fmt.Sprintf("%s/skills/%s?HARD_DELETE=%t", c.BaseURL, id, hardDelete)
-	req, err := http.NewRequest("DELETE", url, nil)
+	url, err := c.skillURL(id)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s?HARD_DELETE=%t", url, hardDelete), nil)
 	if err != nil {
 		return err
 	}
@@ -146,42 +345,124 @@ func (c *Client) DeleteSkill(ctx context.Context, id string, hardDelete bool) er
 	return nil
 }
 
-// makeRequest provides a single function to add common items to the request.
+// skillURL returns the URL of the skill with the given ID, escaping it so that it can't change
+// the path.
+func (c *Client) skillURL(id string) (string, error) {
+	if id == "" {
+		return "", errors.New("skill id required")
+	}
+	return fmt.Sprintf("%s/skills/%s", c.BaseURL, url.PathEscape(id)), nil
+}
+
+// makeRequest provides a single function to add common items to the request, and retries it
+// according to the retry policy.  If the token is rejected and the token source can refresh
+// it, the request is retried once with the new token, which counts as an attempt.
 func (c *Client) makeRequest(ctx context.Context, req *http.Request, v interface{}) error {
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Content-Type", "application/json")
-	rc := req.WithContext(ctx)
-	res, err := c.HTTPClient.Do(rc)
+	ts := c.TokenSource
+	if ts == nil {
+		ts = StaticToken(c.Token)
+	}
+	start := time.Now()
+	refreshed := false
+	for attempt := 1; ; attempt++ {
+		token, err := ts.Token(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		rc := req.WithContext(ctx)
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			rc.Body = body
+		}
+		attemptStart := time.Now()
+		statusCode, retryAfter, err := c.do(rc, v)
+		backoff := c.retryBackoff(rc, attempt, statusCode, retryAfter, err, start)
+		if c.Retry != nil && c.Retry.OnAttempt != nil {
+			c.Retry.OnAttempt(Attempt{
+				Number:     attempt,
+				Method:     req.Method,
+				URL:        req.URL.String(),
+				StatusCode: statusCode,
+				Err:        err,
+				Duration:   time.Since(attemptStart),
+				Backoff:    backoff,
+			})
+		}
+		if rs, ok := ts.(RefreshingTokenSource); ok && statusCode == http.StatusUnauthorized && !refreshed {
+			refreshed = true
+			if _, rerr := refreshToken(ctx, rs, token); rerr != nil {
+				return rerr
+			}
+			continue
+		}
+		if backoff == 0 {
+			return err
+		}
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+	}
+}
+
+// refreshToken refreshes the token rejected by the service.  An OAuthTokenSource only refreshes
+// it if it is still current, so concurrent requests rejected with the same token share one
+// refresh rather than each replacing the token the others have just been given.
+func refreshToken(ctx context.Context, rs RefreshingTokenSource, rejected string) (string, error) {
+	if s, ok := rs.(*OAuthTokenSource); ok {
+		return s.refreshRejected(ctx, rejected)
+	}
+	return rs.Refresh(ctx)
+}
+
+// retryBackoff returns how long to wait before retrying the request, or zero if it shouldn't
+// be retried.
+func (c *Client) retryBackoff(req *http.Request, attempt, statusCode int, retryAfter time.Duration, err error, start time.Time) time.Duration {
+	p := c.Retry
+	if err == nil || p == nil || attempt >= p.MaxAttempts || !p.retryable(req.Method, statusCode, err) {
+		return 0
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// the body can't be sent again
+		return 0
+	}
+	backoff := p.backoff(attempt, retryAfter)
+	if p.MaxElapsed > 0 && time.Since(start)+backoff > p.MaxElapsed {
+		return 0
+	}
+	if deadline, ok := req.Context().Deadline(); ok && time.Now().Add(backoff).After(deadline) {
+		return 0
+	}
+	if backoff <= 0 {
+		backoff = time.Nanosecond
+	}
+	return backoff
+}
+
+// do makes a single attempt at the request, returning the status code and Retry-After delay
+// of the response, if there was one.
+func (c *Client) do(req *http.Request, v interface{}) (int, time.Duration, error) {
+	res, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	defer res.Body.Close()
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
-		var apiError error
-		switch res.StatusCode {
-		case 400:
-			apiError = ErrBadRequest
-		case 401:
-			apiError = ErrUnauthorized
-		case 403:
-			apiError = ErrForbidden
-		case 404:
-			apiError = ErrNotFound
-		case 500:
-			apiError = ErrInternalError
-		default:
-			apiError = ErrUnknown
-		}
-		return apiError
+		apiErr := newAPIError(res)
+		return res.StatusCode, apiErr.RetryAfter, apiErr
 	}
 	if res.StatusCode == http.StatusNoContent {
-		return nil
+		return res.StatusCode, 0, nil
 	}
 	if err = json.NewDecoder(res.Body).Decode(&v); err != nil {
-		return err
+		return res.StatusCode, 0, err
 	}
-	return nil
+	return res.StatusCode, 0, nil
 }
 
 // Bool is a helper routine that allocates a new bool value
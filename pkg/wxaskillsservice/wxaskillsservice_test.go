@@ -0,0 +1,293 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxaskillsservice_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice"
+	"github.com/darrenparkinson/wxa-skills-go/pkg/wxaskillsservice/fake"
+)
+
+func TestAPIError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  map[string]string
+		body    string
+		want    error
+		message string
+		details string
+		id      string
+		retry   time.Duration
+		text    string
+	}{
+		{
+			name:    "json message and details",
+			status:  http.StatusBadRequest,
+			header:  map[string]string{"TrackingID": "ROUTER_1"},
+			body:    `{"message":"invalid skill","details":{"url":"must be https"}}`,
+			want:    wxaskillsservice.ErrBadRequest,
+			message: "invalid skill",
+			details: `{"url":"must be https"}`,
+			id:      "ROUTER_1",
+			text:    `api: bad request: invalid skill {"url":"must be https"} (tracking id ROUTER_1)`,
+		},
+		{
+			name:    "error and errors fields",
+			status:  http.StatusForbidden,
+			body:    `{"error":"not your skill","errors":[{"description":"forbidden"}],"trackingId":"BODY_1"}`,
+			want:    wxaskillsservice.ErrForbidden,
+			message: "not your skill",
+			details: `[{"description":"forbidden"}]`,
+			id:      "BODY_1",
+			text:    `api: forbidden: not your skill [{"description":"forbidden"}] (tracking id BODY_1)`,
+		},
+		{
+			name:    "tracking id header is preferred",
+			status:  http.StatusNotFound,
+			header:  map[string]string{"X-Request-ID": "HEADER_1"},
+			body:    `{"detail":"no such skill","trackingId":"BODY_1"}`,
+			want:    wxaskillsservice.ErrNotFound,
+			message: "no such skill",
+			id:      "HEADER_1",
+			text:    "api: resource not found: no such skill (tracking id HEADER_1)",
+		},
+		{
+			name:    "text body",
+			status:  http.StatusUnauthorized,
+			body:    "token expired\n",
+			want:    wxaskillsservice.ErrUnauthorized,
+			message: "token expired",
+			text:    "api: unauthorized request: token expired",
+		},
+		{
+			name:   "html body is ignored",
+			status: http.StatusInternalServerError,
+			body:   "<html><body>Internal Server Error</body></html>",
+			want:   wxaskillsservice.ErrInternalError,
+			text:   "api: internal error",
+		},
+		{
+			name:   "long text body is ignored",
+			status: http.StatusInternalServerError,
+			body:   strings.Repeat("x", 201),
+			want:   wxaskillsservice.ErrInternalError,
+			text:   "api: internal error",
+		},
+		{
+			name:    "retry after",
+			status:  http.StatusTooManyRequests,
+			header:  map[string]string{"Retry-After": "7"},
+			body:    `{"message":"slow down"}`,
+			want:    wxaskillsservice.ErrTooManyRequests,
+			message: "slow down",
+			retry:   7 * time.Second,
+			text:    "api: too many requests: slow down",
+		},
+		{
+			name:    "bad gateway",
+			status:  http.StatusBadGateway,
+			body:    "upstream failed",
+			want:    wxaskillsservice.ErrUnavailable,
+			message: "upstream failed",
+			text:    "api: service unavailable: upstream failed",
+		},
+		{
+			name:   "service unavailable",
+			status: http.StatusServiceUnavailable,
+			header: map[string]string{"Retry-After": "30"},
+			want:   wxaskillsservice.ErrUnavailable,
+			retry:  30 * time.Second,
+			text:   "api: service unavailable",
+		},
+		{
+			name:   "gateway timeout",
+			status: http.StatusGatewayTimeout,
+			want:   wxaskillsservice.ErrUnavailable,
+			text:   "api: service unavailable",
+		},
+		{
+			name:   "unexpected status",
+			status: http.StatusTeapot,
+			want:   wxaskillsservice.ErrUnknown,
+			text:   "api: unexpected error occurred",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			client := &wxaskillsservice.Client{BaseURL: srv.URL, Token: "token", HTTPClient: srv.Client()}
+
+			_, err := client.ListSkills(context.Background())
+			var apiErr *wxaskillsservice.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("err = %v, want an APIError", err)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want it to wrap %v", err, tt.want)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Message != tt.message || string(apiErr.Details) != tt.details ||
+				apiErr.RequestID != tt.id || apiErr.RetryAfter != tt.retry {
+				t.Errorf("APIError = %+v", apiErr)
+			}
+			if err.Error() != tt.text {
+				t.Errorf("Error() = %q, want %q", err.Error(), tt.text)
+			}
+		})
+	}
+}
+
+func TestClient(t *testing.T) {
+	srv := fake.NewServer("dev", "token")
+	defer srv.Close()
+	client, err := wxaskillsservice.NewClient("dev", "token", srv.Client().HTTPClient, wxaskillsservice.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.BaseURL != srv.BaseURL() {
+		t.Fatalf("BaseURL = %q, want %q", client.BaseURL, srv.BaseURL())
+	}
+	ctx := context.Background()
+
+	skills, err := client.ListSkills(ctx)
+	if err != nil || skills == nil || len(skills) != 0 {
+		t.Fatalf("ListSkills = %v, %v", skills, err)
+	}
+	added := srv.AddSkill(wxaskillsservice.Skill{Name: wxaskillsservice.String("Echo")})
+	got, err := client.GetSkill(ctx, *added.SkillID)
+	if err != nil || *got.Name != "Echo" {
+		t.Fatalf("GetSkill = %+v, %v", got, err)
+	}
+	if err := client.DeleteSkill(ctx, *added.SkillID, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetSkill(ctx, *added.SkillID); !errors.Is(err, wxaskillsservice.ErrNotFound) {
+		t.Errorf("GetSkill after delete: err = %v", err)
+	}
+}
+
+func TestSkillID(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	client, err := wxaskillsservice.NewClient("dev", "token", srv.Client(), wxaskillsservice.WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	update := wxaskillsservice.Skill{Name: wxaskillsservice.String("Echo")}
+
+	// the ID is escaped so that it can't change the path
+	const id = "../a/b?c"
+	if _, err := client.GetSkill(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.UpdateSkill(ctx, id, update); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteSkill(ctx, id, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if !strings.HasSuffix(path, "/skills/..%2Fa%2Fb%3Fc") {
+			t.Errorf("path = %q, want the escaped ID", path)
+		}
+	}
+
+	// an empty ID would refer to the list of skills
+	paths = nil
+	if _, err := client.GetSkill(ctx, ""); err == nil {
+		t.Error("GetSkill with no ID didn't return an error")
+	}
+	if _, err := client.UpdateSkill(ctx, "", update); err == nil {
+		t.Error("UpdateSkill with no ID didn't return an error")
+	}
+	if err := client.DeleteSkill(ctx, "", false); err == nil {
+		t.Error("DeleteSkill with no ID didn't return an error")
+	}
+	if len(paths) != 0 {
+		t.Errorf("requests sent without an ID: %q", paths)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		developerID string
+		token       string
+		opts        []wxaskillsservice.Option
+		wantBaseURL string
+		wantErr     string
+	}{
+		{name: "valid", developerID: "dev", token: "token", wantBaseURL: "https://assistant.us-east-2.intelligence.webex.com/skills/api/developers/dev"},
+		{name: "token source", developerID: "dev", opts: []wxaskillsservice.Option{wxaskillsservice.WithTokenSource(wxaskillsservice.StaticToken("token"))},
+			wantBaseURL: "https://assistant.us-east-2.intelligence.webex.com/skills/api/developers/dev"},
+		{name: "escaped developer", developerID: "a/b", token: "token", wantBaseURL: "https://assistant.us-east-2.intelligence.webex.com/skills/api/developers/a%2Fb"},
+		{name: "region", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithRegion("eu-central-1")},
+			wantBaseURL: "https://assistant.eu-central-1.intelligence.webex.com/skills/api/developers/dev"},
+		{name: "base url", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithBaseURL("http://localhost:8080/")},
+			wantBaseURL: "http://localhost:8080/skills/api/developers/dev"},
+		{name: "base url overrides region", developerID: "dev", token: "token",
+			opts:        []wxaskillsservice.Option{wxaskillsservice.WithBaseURL("https://staging.example.com"), wxaskillsservice.WithRegion("eu-central-1")},
+			wantBaseURL: "https://staging.example.com/skills/api/developers/dev"},
+		{name: "no developer", token: "token", wantErr: "developer id required"},
+		{name: "no token", developerID: "dev", wantErr: "token required"},
+		{name: "empty region", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithRegion("")}, wantErr: `invalid region ""`},
+		{name: "upper case region", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithRegion("US-EAST-2")}, wantErr: `invalid region "US-EAST-2"`},
+		{name: "region without number", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithRegion("us-east")}, wantErr: `invalid region "us-east"`},
+		{name: "region with host", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithRegion("evil.com/us-east-2")}, wantErr: `invalid region "evil.com/us-east-2"`},
+		{name: "base url without scheme", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithBaseURL("example.com")}, wantErr: `invalid base url "example.com"`},
+		{name: "base url scheme", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithBaseURL("ftp://example.com")}, wantErr: `invalid base url "ftp://example.com"`},
+		{name: "base url without host", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithBaseURL("https://")}, wantErr: `invalid base url "https://"`},
+		{name: "unparseable base url", developerID: "dev", token: "token", opts: []wxaskillsservice.Option{wxaskillsservice.WithBaseURL("http://[::1")}, wantErr: `invalid base url "http://[::1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := wxaskillsservice.NewClient(tt.developerID, tt.token, nil, tt.opts...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if client.HTTPClient == nil || client.Retry == nil {
+				t.Errorf("client = %+v", client)
+			}
+			if client.BaseURL != tt.wantBaseURL {
+				t.Errorf("BaseURL = %q, want %q", client.BaseURL, tt.wantBaseURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ratelimit provides token bucket rate limiting of skill requests by org and user.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout is how long a bucket can go unused before it is removed.
+const idleTimeout = 10 * time.Minute
+
+// Limit is the rate and burst for a token bucket.  The zero value means no limit.
+type Limit struct {
+	// Rate is the number of requests allowed per second.
+	Rate float64
+
+	// Burst is the maximum number of requests allowed at once.
+	Burst int
+}
+
+// Config holds the limits to apply.
+type Config struct {
+	// Org is the default limit for each org.
+	Org Limit
+
+	// User is the limit for each user.
+	User Limit
+
+	// Orgs overrides the default org limit for specific org IDs.
+	Orgs map[string]Limit
+}
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter limits requests by org and by user.  It can be created using New.
+type Limiter struct {
+	config    Config
+	mu        sync.Mutex
+	orgs      map[string]*bucket
+	users     map[string]*bucket
+	lastSweep time.Time
+}
+
+// New returns a new limiter for the given config.
+func New(cfg Config) *Limiter {
+	return &Limiter{
+		config:    cfg,
+		orgs:      map[string]*bucket{},
+		users:     map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow reports whether a request from the org and user may proceed.  Empty IDs are not limited.
+// A request is only counted against the org and user if both allow it, so a throttled user
+// doesn't use up the org's budget.
+func (l *Limiter) Allow(orgID, userID string) bool {
+	return l.allow(orgID, userID, time.Now())
+}
+
+func (l *Limiter) allow(orgID, userID string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > idleTimeout {
+		l.sweep(now)
+	}
+	var limiters []*rate.Limiter
+	if orgID != "" {
+		lim := l.config.Org
+		if o, ok := l.config.Orgs[orgID]; ok {
+			lim = o
+		}
+		if rl := limiter(l.orgs, orgID, lim, now); rl != nil {
+			limiters = append(limiters, rl)
+		}
+	}
+	if userID != "" {
+		if rl := limiter(l.users, userID, l.config.User, now); rl != nil {
+			limiters = append(limiters, rl)
+		}
+	}
+	for _, rl := range limiters {
+		if rl.TokensAt(now) < 1 {
+			return false
+		}
+	}
+	for _, rl := range limiters {
+		rl.AllowN(now, 1)
+	}
+	return true
+}
+
+// limiter returns the token bucket for the key, creating it if needed, or nil if there is no limit.
+func limiter(buckets map[string]*bucket, key string, lim Limit, now time.Time) *rate.Limiter {
+	if lim.Rate <= 0 {
+		return nil
+	}
+	b, ok := buckets[key]
+	if !ok {
+		burst := lim.Burst
+		if burst < 1 {
+			burst = int(math.Max(1, math.Ceil(lim.Rate)))
+		}
+		b = &bucket{limiter: rate.NewLimiter(rate.Limit(lim.Rate), burst)}
+		buckets[key] = b
+	}
+	b.lastSeen = now
+	return b.limiter
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for _, buckets := range []map[string]*bucket{l.orgs, l.users} {
+		for k, b := range buckets {
+			if now.Sub(b.lastSeen) > idleTimeout {
+				delete(buckets, k)
+			}
+		}
+	}
+	l.lastSweep = now
+}
+
+// ParseLimit parses a limit in the form "<requests>/<s|m|h>[:<burst>]", e.g. "5/s" or "100/m:10".
+// An empty string returns the zero Limit, meaning no limit.  A rate of zero is rejected rather
+// than treated as no limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+	var lim Limit
+	if i := strings.Index(s, ":"); i >= 0 {
+		burst, err := strconv.Atoi(s[i+1:])
+		if err != nil || burst < 1 {
+			return lim, fmt.Errorf("invalid burst in rate limit %q", s)
+		}
+		lim.Burst = burst
+		s = s[:i]
+	}
+	parts := strings.SplitN(s, "/", 2)
+	n, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || n < 0 {
+		return lim, fmt.Errorf("invalid rate limit %q", s)
+	}
+	if n == 0 {
+		// a zero rate would mean no limit, which is the opposite of what was intended
+		return lim, fmt.Errorf("rate limit %q must be greater than zero, leave it empty for no limit", s)
+	}
+	per := time.Second
+	if len(parts) == 2 {
+		switch parts[1] {
+		case "s":
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return lim, fmt.Errorf("invalid unit in rate limit %q", s)
+		}
+	}
+	lim.Rate = n / per.Seconds()
+	return lim, nil
+}
+
+// ParseOrgLimits parses a comma separated list of per org limits in the form
+// "<org id>=<limit>", e.g. "org1=5/s,org2=100/m:10".
+func ParseOrgLimits(s string) (map[string]Limit, error) {
+	limits := map[string]Limit{}
+	if strings.TrimSpace(s) == "" {
+		return limits, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid org rate limit %q", pair)
+		}
+		lim, err := ParseLimit(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		limits[kv[0]] = lim
+	}
+	return limits, nil
+}
+
+// ParseConfig parses the default org limit, user limit and per org overrides using ParseLimit
+// and ParseOrgLimits.
+func ParseConfig(org, user, orgs string) (Config, error) {
+	var cfg Config
+	var err error
+	if cfg.Org, err = ParseLimit(org); err != nil {
+		return cfg, err
+	}
+	if cfg.User, err = ParseLimit(user); err != nil {
+		return cfg, err
+	}
+	if cfg.Orgs, err = ParseOrgLimits(orgs); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,197 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	type step struct {
+		after       time.Duration
+		org, user   string
+		wantAllowed bool
+	}
+	tests := []struct {
+		name  string
+		cfg   Config
+		steps []step
+	}{
+		{
+			name: "no limits",
+			cfg:  Config{},
+			steps: []step{
+				{org: "o1", user: "u1", wantAllowed: true},
+				{org: "o1", user: "u1", wantAllowed: true},
+			},
+		},
+		{
+			name: "user limit",
+			cfg:  Config{User: Limit{Rate: 1, Burst: 2}},
+			steps: []step{
+				{org: "o1", user: "u1", wantAllowed: true},
+				{org: "o1", user: "u1", wantAllowed: true},
+				{org: "o1", user: "u1", wantAllowed: false},
+				{org: "o1", user: "u2", wantAllowed: true},
+				{after: time.Second, org: "o1", user: "u1", wantAllowed: true},
+			},
+		},
+		{
+			name: "org limit shared by users",
+			cfg:  Config{Org: Limit{Rate: 1, Burst: 2}},
+			steps: []step{
+				{org: "o1", user: "u1", wantAllowed: true},
+				{org: "o1", user: "u2", wantAllowed: true},
+				{org: "o1", user: "u3", wantAllowed: false},
+				{org: "o2", user: "u3", wantAllowed: true},
+			},
+		},
+		{
+			name: "throttled user doesn't use the org budget",
+			cfg:  Config{Org: Limit{Rate: 1, Burst: 3}, User: Limit{Rate: 1, Burst: 1}},
+			steps: []step{
+				{org: "o1", user: "noisy", wantAllowed: true},
+				{org: "o1", user: "noisy", wantAllowed: false},
+				{org: "o1", user: "noisy", wantAllowed: false},
+				{org: "o1", user: "noisy", wantAllowed: false},
+				{org: "o1", user: "u2", wantAllowed: true},
+				{org: "o1", user: "u3", wantAllowed: true},
+				{org: "o1", user: "u4", wantAllowed: false},
+			},
+		},
+		{
+			name: "throttled org doesn't use the user budget",
+			cfg:  Config{Org: Limit{Rate: 1, Burst: 1}, User: Limit{Rate: 1, Burst: 1}},
+			steps: []step{
+				{org: "o1", user: "u1", wantAllowed: true},
+				{org: "o1", user: "u2", wantAllowed: false},
+				{org: "o2", user: "u2", wantAllowed: true},
+			},
+		},
+		{
+			name: "org override",
+			cfg:  Config{Org: Limit{Rate: 1, Burst: 1}, Orgs: map[string]Limit{"big": {Rate: 10, Burst: 3}}},
+			steps: []step{
+				{org: "big", user: "u1", wantAllowed: true},
+				{org: "big", user: "u2", wantAllowed: true},
+				{org: "big", user: "u3", wantAllowed: true},
+				{org: "big", user: "u4", wantAllowed: false},
+				{org: "small", user: "u1", wantAllowed: true},
+				{org: "small", user: "u2", wantAllowed: false},
+			},
+		},
+		{
+			name: "empty ids aren't limited",
+			cfg:  Config{Org: Limit{Rate: 1, Burst: 1}, User: Limit{Rate: 1, Burst: 1}},
+			steps: []step{
+				{wantAllowed: true},
+				{wantAllowed: true},
+				{org: "o1", wantAllowed: true},
+				{org: "o1", wantAllowed: false},
+				{user: "u1", wantAllowed: true},
+				{user: "u1", wantAllowed: false},
+			},
+		},
+		{
+			name: "default burst is the rate",
+			cfg:  Config{User: Limit{Rate: 2}},
+			steps: []step{
+				{user: "u1", wantAllowed: true},
+				{user: "u1", wantAllowed: true},
+				{user: "u1", wantAllowed: false},
+				{after: 500 * time.Millisecond, user: "u1", wantAllowed: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.cfg)
+			now := time.Now()
+			for i, s := range tt.steps {
+				now = now.Add(s.after)
+				if got := l.allow(s.org, s.user, now); got != s.wantAllowed {
+					t.Errorf("step %d: allow(%q, %q) = %t, want %t", i, s.org, s.user, got, s.wantAllowed)
+				}
+			}
+		})
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l := New(Config{Org: Limit{Rate: 1}, User: Limit{Rate: 1}})
+	now := time.Now()
+	l.allow("o1", "u1", now)
+	l.allow("o1", "u2", now.Add(idleTimeout+time.Second))
+	if _, ok := l.users["u1"]; ok {
+		t.Error("idle user bucket wasn't removed")
+	}
+	if len(l.orgs) != 1 || len(l.users) != 1 {
+		t.Errorf("got %d org and %d user buckets, want 1 each", len(l.orgs), len(l.users))
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{in: "", want: Limit{}},
+		{in: "5", want: Limit{Rate: 5}},
+		{in: "5/s", want: Limit{Rate: 5}},
+		{in: "120/m:10", want: Limit{Rate: 2, Burst: 10}},
+		{in: "3600/h", want: Limit{Rate: 1}},
+		{in: "5/d", wantErr: true},
+		{in: "x/s", wantErr: true},
+		{in: "-1/s", wantErr: true},
+		{in: "0/s", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "0.0/m:5", wantErr: true},
+		{in: "5/s:0", wantErr: true},
+		{in: "5/s:x", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := ParseConfig("10/s", "1/s:2", "org1=5/s, org2=60/m:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Config{
+		Org:  Limit{Rate: 10},
+		User: Limit{Rate: 1, Burst: 2},
+		Orgs: map[string]Limit{"org1": {Rate: 5}, "org2": {Rate: 1, Burst: 3}},
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("ParseConfig = %+v, want %+v", cfg, want)
+	}
+	for _, orgs := range []string{"org1", "=5/s", "org1=fast", "org1=0/s"} {
+		if _, err := ParseConfig("", "", orgs); err == nil {
+			t.Errorf("ParseConfig with orgs %q didn't return an error", orgs)
+		}
+	}
+}
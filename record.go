@@ -0,0 +1,133 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+)
+
+// TurnRecorder records the details of each turn, e.g. a *debug.Recorder.
+type TurnRecorder interface {
+	RecordTurn(TurnRecord)
+}
+
+// TurnRecord holds the details of a single turn for diagnosing issues.  The message, response and
+// NLU values have been redacted using the skill's Redaction policy and the challenge removed.
+type TurnRecord struct {
+	ID            string                  `json:"id"`
+	Skill         string                  `json:"skill,omitempty"`
+	Time          time.Time               `json:"time"`
+	Latency       time.Duration           `json:"latency_ns"`
+	Outcome       string                  `json:"outcome"`
+	Intent        string                  `json:"intent,omitempty"`
+	DialogueState string                  `json:"dialogue_state,omitempty"`
+	Fallback      bool                    `json:"fallback"`
+	NLU           map[string]string       `json:"nlu,omitempty"`
+	Error         string                  `json:"error,omitempty"`
+	Message       *WebexAssistantMessage  `json:"message,omitempty"`
+	Response      *WebexAssistantResponse `json:"response,omitempty"`
+}
+
+// Redact returns a copy of the response with the text, prompt, title and payload values of each
+// directive and the frame values redacted according to the policy, since they often repeat what
+// the user said.
+func (r WebexAssistantResponse) Redact(p *redact.Policy) WebexAssistantResponse {
+	directives := make([]WebexAssistantDirective, len(r.Directives))
+	for i, d := range r.Directives {
+		switch t := d.Payload.Text.(type) {
+		case string:
+			d.Payload.Text = p.String(redact.FieldText, t)
+		case *string:
+			d.Payload.Text = redactString(p, redact.FieldText, t)
+		case []string:
+			texts := make([]string, len(t))
+			for j := range t {
+				texts[j] = p.String(redact.FieldText, t[j])
+			}
+			d.Payload.Text = texts
+		case []interface{}:
+			// a list of texts decoded from JSON, e.g. for a ui-hint
+			texts := make([]interface{}, len(t))
+			for j := range t {
+				if s, ok := t[j].(string); ok {
+					texts[j] = p.String(redact.FieldText, s)
+				} else {
+					texts[j], _ = redactValue(p, redact.FieldText, t[j])
+				}
+			}
+			d.Payload.Text = texts
+		}
+		d.Payload.Prompt = redactString(p, redact.FieldText, d.Payload.Prompt)
+		d.Payload.Title = redactString(p, redact.FieldText, d.Payload.Title)
+		d.Payload.Payload = redactMap(p, redact.FieldText, d.Payload.Payload)
+		directives[i] = d
+	}
+	r.Directives = directives
+	r.Frame = redactFrame(p, r.Frame)
+	return r
+}
+
+// redactMap returns a copy of the map with each value redacted as the field, dropping any values
+// the policy drops.
+func redactMap(p *redact.Policy, field string, m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	rm := make(map[string]string, len(m))
+	for k, v := range m {
+		if v, ok := p.Value(field, v); ok {
+			rm[k] = v
+		}
+	}
+	return rm
+}
+
+// recordTurn passes the details of the turn to the Recorder, if there is one.
+func (s *Skill) recordTurn(start time.Time, latency time.Duration, outcome string, turn *Turn, msg *WebexAssistantMessage, resp *WebexAssistantResponse, err error) {
+	if s.Recorder == nil {
+		return
+	}
+	rec := TurnRecord{
+		ID:            turn.ID,
+		Skill:         s.Name,
+		Time:          start,
+		Latency:       latency,
+		Outcome:       outcome,
+		Intent:        turn.Intent,
+		DialogueState: turn.DialogueState,
+		Fallback:      turn.Fallback,
+		NLU:           redactMap(s.Redaction, redact.FieldText, turn.NLU),
+	}
+	if rec.ID == "" {
+		// the turn failed before the message was decrypted
+		rec.ID = turnID("")
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if msg != nil {
+		m := msg.Redact(s.Redaction)
+		m.Challenge = ""
+		rec.Message = &m
+	}
+	if resp != nil {
+		r := resp.Redact(s.Redaction)
+		r.Challenge = ""
+		rec.Response = &r
+	}
+	s.Recorder.RecordTurn(rec)
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+)
+
+type recorderFunc func(TurnRecord)
+
+func (f recorderFunc) RecordTurn(t TurnRecord) { f(t) }
+
+func TestResponseRedact(t *testing.T) {
+	resp := WebexAssistantResponse{
+		Challenge: "challenge",
+		Directives: []WebexAssistantDirective{
+			{Name: DirectiveNameReply, Payload: Payload{Text: "call 555-1234"}},
+			{Name: DirectiveNameSpeak, Payload: Payload{Text: strPtr("call 555-1234")}},
+			{Name: DirectiveNameUIHint, Payload: Payload{Text: []string{"call 555-1234", "email a@example.com"}, Prompt: strPtr("try 555-1234")}},
+			{Name: DirectiveNameDisplayWebView, Payload: Payload{Title: strPtr("order 42"), URL: strPtr("https://example.com/1")}},
+			{Name: DirectiveNameAssistantEvent, Payload: Payload{Payload: map[string]string{"body": "room 101"}}},
+			// a ui-hint decoded from JSON, e.g. by wxa-cli record
+			{Name: DirectiveNameUIHint, Payload: Payload{Text: []interface{}{"call 555-1234", "yes"}}},
+		},
+		Frame: Frame{"phone": "555-1234", "turns": 1},
+	}
+	tests := []struct {
+		name      string
+		policy    *redact.Policy
+		want      []Payload
+		wantFrame Frame
+	}{
+		{
+			name:   "mask",
+			policy: redact.Default(),
+			want: []Payload{
+				{Text: "call [number]"},
+				{Text: strPtr("call [number]")},
+				{Text: []string{"call [number]", "email [email]"}, Prompt: strPtr("try [number]")},
+				{Title: strPtr("order [number]"), URL: strPtr("https://example.com/1")},
+				{Payload: map[string]string{"body": "room [number]"}},
+				{Text: []interface{}{"call [number]", "yes"}},
+			},
+			wantFrame: Frame{"phone": "[number]", "turns": "[number]"},
+		},
+		{
+			name:   "drop",
+			policy: redact.Strict(),
+			want: []Payload{
+				{Text: ""},
+				{Text: (*string)(nil)},
+				{Text: []string{"", ""}},
+				{URL: strPtr("https://example.com/1")},
+				{Payload: map[string]string{}},
+				{Text: []interface{}{"", ""}},
+			},
+			wantFrame: Frame{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resp.Redact(tt.policy)
+			if len(got.Directives) != len(tt.want) {
+				t.Fatalf("%d directives, want %d", len(got.Directives), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if !reflect.DeepEqual(got.Directives[i].Payload, want) {
+					t.Errorf("%s payload = %+v, want %+v", got.Directives[i].Name, got.Directives[i].Payload, want)
+				}
+			}
+			if !reflect.DeepEqual(got.Frame, tt.wantFrame) {
+				t.Errorf("frame = %v, want %v", got.Frame, tt.wantFrame)
+			}
+			if got.Challenge != "challenge" {
+				t.Error("challenge was changed")
+			}
+		})
+	}
+	if resp.Directives[4].Payload.Payload["body"] != "room 101" {
+		t.Error("original response was modified")
+	}
+}
+
+func TestRecordTurn(t *testing.T) {
+	var got TurnRecord
+	s := &Skill{
+		Name:      "test",
+		Redaction: redact.Default(),
+		Recorder:  recorderFunc(func(t TurnRecord) { got = t }),
+	}
+	turn := &Turn{ID: "turn-1", Intent: "order", NLU: map[string]string{"phone": "555-1234", "item": "pizza"}}
+	msg := &WebexAssistantMessage{Text: "my number is 555-1234", Context: Context{UserID: strPtr("user-1")}, Frame: Frame{"number": "555-1234"}, Challenge: "c"}
+	resp := &WebexAssistantResponse{
+		Challenge: "c",
+		Directives: []WebexAssistantDirective{
+			{Name: DirectiveNameReply, Payload: Payload{Text: "calling 555-1234"}},
+			{Name: DirectiveNameUIHint, Payload: Payload{Text: []interface{}{"call 555-1234"}}},
+		},
+		Frame: Frame{"number": "555-1234"},
+	}
+	s.recordTurn(time.Now(), time.Millisecond, OutcomeOK, turn, msg, resp, errors.New("boom"))
+
+	if got.ID != "turn-1" || got.Skill != "test" || got.Intent != "order" || got.Error != "boom" {
+		t.Errorf("record = %+v", got)
+	}
+	if want := map[string]string{"phone": "[number]", "item": "pizza"}; !reflect.DeepEqual(got.NLU, want) {
+		t.Errorf("NLU = %v, want %v", got.NLU, want)
+	}
+	if turn.NLU["phone"] != "555-1234" {
+		t.Error("turn NLU was modified")
+	}
+	if got.Message.Text != "my number is [number]" || *got.Message.Context.UserID != redact.HashValue("", "user-1") || got.Message.Challenge != "" {
+		t.Errorf("message = %+v", got.Message)
+	}
+	if got.Message.Frame["number"] != "[number]" {
+		t.Errorf("message frame = %v", got.Message.Frame)
+	}
+	if got.Response.Directives[0].Payload.Text != "calling [number]" || got.Response.Challenge != "" {
+		t.Errorf("response = %+v", got.Response)
+	}
+	if hint := got.Response.Directives[1].Payload.Text; !reflect.DeepEqual(hint, []interface{}{"call [number]"}) {
+		t.Errorf("ui hint text = %v", hint)
+	}
+	if got.Response.Frame["number"] != "[number]" {
+		t.Errorf("response frame = %v", got.Response.Frame)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+)
+
+// Redact returns a copy of the message with the text and context IDs redacted according to the
+// policy.  The values in the frame and dynamic resource are redacted as text, since they often
+// hold what the user said.  It should be used before a message is logged or otherwise stored.
+func (m WebexAssistantMessage) Redact(p *redact.Policy) WebexAssistantMessage {
+	m.Text = p.String(redact.FieldText, m.Text)
+	m.Frame = redactFrame(p, m.Frame)
+	if m.Params.DynamicResource != nil {
+		m.Params.DynamicResource, _ = redactValue(p, redact.FieldText, m.Params.DynamicResource)
+	}
+	m.Context.OrgID = redactString(p, redact.FieldOrgID, m.Context.OrgID)
+	m.Context.UserID = redactString(p, redact.FieldUserID, m.Context.UserID)
+	m.Context.DeveloperDeviceID = redactString(p, redact.FieldDeveloperDeviceID, m.Context.DeveloperDeviceID)
+	return m
+}
+
+func redactString(p *redact.Policy, field string, s *string) *string {
+	if s == nil {
+		return nil
+	}
+	v, ok := p.Value(field, *s)
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// redactFrame returns a copy of the frame with each value redacted as text.
+func redactFrame(p *redact.Policy, f Frame) Frame {
+	if f == nil {
+		return nil
+	}
+	v, _ := redactValue(p, redact.FieldText, map[string]interface{}(f))
+	return Frame(v.(map[string]interface{}))
+}
+
+// redactValue returns a copy of a JSON value with each string and number redacted as the field,
+// dropping any the policy drops.  Numbers are redacted as strings, since they may be phone
+// numbers or similar.  Other types are converted to their JSON form first.  Values are returned
+// unchanged if the policy keeps the field.
+func redactValue(p *redact.Policy, field string, v interface{}) (interface{}, bool) {
+	if p.Action(field) == redact.Keep {
+		return v, true
+	}
+	switch t := v.(type) {
+	case nil, bool:
+		return t, true
+	case string:
+		return p.Value(field, t)
+	case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, json.Number:
+		return p.Value(field, fmt.Sprint(t))
+	case Frame:
+		return redactValue(p, field, map[string]interface{}(t))
+	case map[string]interface{}:
+		rm := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			if v, ok := redactValue(p, field, v); ok {
+				rm[k] = v
+			}
+		}
+		return rm, true
+	case []interface{}:
+		rs := make([]interface{}, 0, len(t))
+		for _, v := range t {
+			if v, ok := redactValue(p, field, v); ok {
+				rs = append(rs, v)
+			}
+		}
+		return rs, true
+	default:
+		// e.g. a struct or typed slice set by the handler
+		js, err := json.Marshal(t)
+		if err != nil {
+			return nil, false
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(js, &decoded); err != nil {
+			return nil, false
+		}
+		return redactValue(p, field, decoded)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler is a slog.Handler which applies a policy to attributes whose key matches a field in
+// the policy before passing them to the next handler.
+type Handler struct {
+	next   slog.Handler
+	policy *Policy
+}
+
+// NewHandler returns a handler which redacts attributes using the policy before passing them to next.
+func NewHandler(next slog.Handler, policy *Policy) *Handler {
+	return &Handler{next: next, policy: policy}
+}
+
+// Enabled implements the slog.Handler interface.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle implements the slog.Handler interface.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a, ok := h.redact(a); ok {
+			nr.AddAttrs(a)
+		}
+		return true
+	})
+	return h.next.Handle(ctx, nr)
+}
+
+// WithAttrs implements the slog.Handler interface.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var ra []slog.Attr
+	for _, a := range attrs {
+		if a, ok := h.redact(a); ok {
+			ra = append(ra, a)
+		}
+	}
+	return &Handler{next: h.next.WithAttrs(ra), policy: h.policy}
+}
+
+// WithGroup implements the slog.Handler interface.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), policy: h.policy}
+}
+
+func (h *Handler) redact(a slog.Attr) (slog.Attr, bool) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		var ga []slog.Attr
+		for _, ca := range a.Value.Group() {
+			if ca, ok := h.redact(ca); ok {
+				ga = append(ga, ca)
+			}
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(ga...)}, true
+	}
+	if h.policy.Action(a.Key) == Keep {
+		return a, true
+	}
+	v, ok := h.policy.Value(a.Key, a.Value.String())
+	if !ok {
+		return a, false
+	}
+	return slog.String(a.Key, v), true
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact provides field level policies for removing personal information from logs,
+// traces and transcripts of skill requests.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Action is the action to take for a field.
+type Action string
+
+// Action Constants
+const (
+	// Keep leaves the value as is.
+	Keep Action = "keep"
+	// Hash replaces the value with a salted hash so it can still be correlated.
+	Hash Action = "hash"
+	// Mask replaces any email addresses and numbers in the value.
+	Mask Action = "mask"
+	// Drop removes the value entirely.
+	Drop Action = "drop"
+)
+
+// Field Constants.  These are also the keys used when logging the values.
+const (
+	FieldText              = "text"
+	FieldOrgID             = "org_id"
+	FieldUserID            = "user_id"
+	FieldDeveloperDeviceID = "developer_device_id"
+)
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	numberPattern = regexp.MustCompile(`\d[\d\-. ]*\d|\d`)
+)
+
+// Policy maps fields to the action to take for them.  Fields that aren't in the policy are kept.
+// A nil *Policy is valid and keeps everything.
+type Policy struct {
+	// Fields maps a field name to the action to take.
+	Fields map[string]Action
+
+	// Salt is added to values before they are hashed to make them harder to reverse.
+	Salt string
+}
+
+// Default returns a policy which hashes the org, user and device IDs and masks the text.
+func Default() *Policy {
+	return &Policy{
+		Fields: map[string]Action{
+			FieldText:              Mask,
+			FieldOrgID:             Hash,
+			FieldUserID:            Hash,
+			FieldDeveloperDeviceID: Hash,
+		},
+	}
+}
+
+// Strict returns a policy which hashes the org, user and device IDs and drops the text entirely.
+func Strict() *Policy {
+	p := Default()
+	p.Fields[FieldText] = Drop
+	return p
+}
+
+// Parse returns a policy from a string, which is either "none", "default", "strict" or a comma
+// separated list of field=action pairs, e.g. "text=drop,user_id=hash".
+func Parse(s string) (*Policy, error) {
+	switch strings.TrimSpace(s) {
+	case "", "none":
+		return nil, nil
+	case "default":
+		return Default(), nil
+	case "strict":
+		return Strict(), nil
+	}
+	p := &Policy{Fields: map[string]Action{}}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid redaction policy %q", pair)
+		}
+		a := Action(strings.ToLower(kv[1]))
+		switch a {
+		case Keep, Hash, Mask, Drop:
+		default:
+			return nil, fmt.Errorf("invalid redaction action %q for %s", kv[1], kv[0])
+		}
+		p.Fields[kv[0]] = a
+	}
+	return p, nil
+}
+
+// Action returns the action to take for the given field.
+func (p *Policy) Action(field string) Action {
+	if p == nil {
+		return Keep
+	}
+	if a, ok := p.Fields[field]; ok {
+		return a
+	}
+	return Keep
+}
+
+// Value applies the policy for the field to the value.  It returns false if the value should be
+// dropped.
+func (p *Policy) Value(field, value string) (string, bool) {
+	switch p.Action(field) {
+	case Hash:
+		return HashValue(p.Salt, value), true
+	case Mask:
+		return MaskText(value), true
+	case Drop:
+		return "", false
+	default:
+		return value, true
+	}
+}
+
+// String applies the policy for the field to the value, returning an empty string if the value
+// should be dropped.
+func (p *Policy) String(field, value string) string {
+	v, _ := p.Value(field, value)
+	return v
+}
+
+// HashValue returns a shortened, salted SHA256 hash of the value.  Empty values are not hashed.
+func HashValue(salt, value string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(salt + value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// MaskText replaces any email addresses and numbers in the text.
+func MaskText(text string) string {
+	text = emailPattern.ReplaceAllString(text, "[email]")
+	return numberPattern.ReplaceAllString(text, "[number]")
+}
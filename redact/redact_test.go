@@ -0,0 +1,147 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    *Policy
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "none", want: nil},
+		{in: "default", want: Default()},
+		{in: " strict ", want: Strict()},
+		{in: "text=drop,user_id=HASH", want: &Policy{Fields: map[string]Action{FieldText: Drop, FieldUserID: Hash}}},
+		{in: "text=mask, org_id=keep", want: &Policy{Fields: map[string]Action{FieldText: Mask, FieldOrgID: Keep}}},
+		{in: "text", wantErr: true},
+		{in: "=drop", wantErr: true},
+		{in: "text=shred", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyValue(t *testing.T) {
+	const text = "email me at jane@example.com or call 555-1234"
+	hashed := HashValue("", "user-1")
+	tests := []struct {
+		name   string
+		policy *Policy
+		field  string
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{name: "nil policy keeps", policy: nil, field: FieldText, value: text, want: text, wantOK: true},
+		{name: "unknown field kept", policy: Default(), field: "intent", value: "weather", want: "weather", wantOK: true},
+		{name: "default masks text", policy: Default(), field: FieldText, value: text, want: "email me at [email] or call [number]", wantOK: true},
+		{name: "default hashes org", policy: Default(), field: FieldOrgID, value: "user-1", want: hashed, wantOK: true},
+		{name: "default hashes user", policy: Default(), field: FieldUserID, value: "user-1", want: hashed, wantOK: true},
+		{name: "default hashes device", policy: Default(), field: FieldDeveloperDeviceID, value: "user-1", want: hashed, wantOK: true},
+		{name: "strict drops text", policy: Strict(), field: FieldText, value: text, want: "", wantOK: false},
+		{name: "salted hash", policy: &Policy{Fields: map[string]Action{FieldUserID: Hash}, Salt: "pepper"}, field: FieldUserID, value: "user-1", want: HashValue("pepper", "user-1"), wantOK: true},
+		{name: "explicit keep", policy: &Policy{Fields: map[string]Action{FieldText: Keep}}, field: FieldText, value: text, want: text, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.policy.Value(tt.field, tt.value)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Value(%q, %q) = %q, %t, want %q, %t", tt.field, tt.value, got, ok, tt.want, tt.wantOK)
+			}
+			if s := tt.policy.String(tt.field, tt.value); s != tt.want {
+				t.Errorf("String(%q, %q) = %q, want %q", tt.field, tt.value, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashValue(t *testing.T) {
+	if got := HashValue("salt", ""); got != "" {
+		t.Errorf("HashValue of empty value = %q, want empty", got)
+	}
+	a, b := HashValue("salt", "user-1"), HashValue("salt", "user-2")
+	if a == b || len(a) != 16 {
+		t.Errorf("HashValue = %q and %q, want distinct 16 character hashes", a, b)
+	}
+	if a != HashValue("salt", "user-1") {
+		t.Error("HashValue isn't stable")
+	}
+	if a == HashValue("other", "user-1") {
+		t.Error("HashValue ignores the salt")
+	}
+}
+
+func TestMaskText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "what's the weather", want: "what's the weather"},
+		{in: "call 07700 900123", want: "call [number]"},
+		{in: "room 4", want: "room [number]"},
+		{in: "mail a.b+c@mail.example.co.uk now", want: "mail [email] now"},
+		{in: "1.5 and 2", want: "[number] and [number]"},
+	}
+	for _, tt := range tests {
+		if got := MaskText(tt.in); got != tt.want {
+			t.Errorf("MaskText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buf, nil), Strict()))
+	logger.With(FieldOrgID, "org-1").Info("turn",
+		FieldText, "my number is 555-1234",
+		FieldUserID, "user-1",
+		"intent", "weather",
+		slog.Group("context", FieldDeveloperDeviceID, "device-1"),
+	)
+	var got map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid log output %q: %s", buf.String(), err)
+	}
+	if _, ok := got[FieldText]; ok {
+		t.Errorf("text wasn't dropped: %s", buf.String())
+	}
+	want := map[string]interface{}{
+		FieldOrgID:  HashValue("", "org-1"),
+		FieldUserID: HashValue("", "user-1"),
+		"intent":    "weather",
+		"context":   map[string]interface{}{FieldDeveloperDeviceID: HashValue("", "device-1")},
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestMessageRedact(t *testing.T) {
+	msg := WebexAssistantMessage{
+		Text: "call me on 555-1234",
+		Context: Context{
+			OrgID:             strPtr("org-1"),
+			UserID:            strPtr("user-1"),
+			DeveloperDeviceID: strPtr("device-1"),
+			UserType:          strPtr("user"),
+		},
+		Frame:     Frame{"phone": "555-1234", "turns": 2.0, "seen": []interface{}{"a@example.com", true}},
+		Params:    Params{DynamicResource: map[string]interface{}{"contact": "Bob 555-1234"}},
+		Challenge: "challenge",
+	}
+	tests := []struct {
+		name         string
+		policy       *redact.Policy
+		wantText     string
+		wantOrg      *string
+		wantUser     *string
+		wantDevice   *string
+		wantFrame    Frame
+		wantResource interface{}
+	}{
+		{name: "none", policy: nil, wantText: msg.Text, wantOrg: strPtr("org-1"), wantUser: strPtr("user-1"), wantDevice: strPtr("device-1"),
+			wantFrame: msg.Frame, wantResource: msg.Params.DynamicResource},
+		{name: "default", policy: redact.Default(), wantText: "call me on [number]",
+			wantOrg: strPtr(redact.HashValue("", "org-1")), wantUser: strPtr(redact.HashValue("", "user-1")), wantDevice: strPtr(redact.HashValue("", "device-1")),
+			wantFrame:    Frame{"phone": "[number]", "turns": "[number]", "seen": []interface{}{"[email]", true}},
+			wantResource: map[string]interface{}{"contact": "Bob [number]"}},
+		{name: "drop everything", policy: &redact.Policy{Fields: map[string]redact.Action{
+			redact.FieldText: redact.Drop, redact.FieldOrgID: redact.Drop, redact.FieldUserID: redact.Drop, redact.FieldDeveloperDeviceID: redact.Drop,
+		}}, wantFrame: Frame{"seen": []interface{}{true}}, wantResource: map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := msg.Redact(tt.policy)
+			if got.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", got.Text, tt.wantText)
+			}
+			for _, f := range []struct {
+				name      string
+				got, want *string
+			}{
+				{redact.FieldOrgID, got.Context.OrgID, tt.wantOrg},
+				{redact.FieldUserID, got.Context.UserID, tt.wantUser},
+				{redact.FieldDeveloperDeviceID, got.Context.DeveloperDeviceID, tt.wantDevice},
+			} {
+				if stringValue(f.got) != stringValue(f.want) || (f.got == nil) != (f.want == nil) {
+					t.Errorf("%s = %v, want %v", f.name, stringValue(f.got), stringValue(f.want))
+				}
+			}
+			if !reflect.DeepEqual(got.Frame, tt.wantFrame) {
+				t.Errorf("frame = %v, want %v", got.Frame, tt.wantFrame)
+			}
+			if !reflect.DeepEqual(got.Params.DynamicResource, tt.wantResource) {
+				t.Errorf("dynamic resource = %v, want %v", got.Params.DynamicResource, tt.wantResource)
+			}
+			if stringValue(got.Context.UserType) != "user" || got.Challenge != "challenge" {
+				t.Errorf("unredacted fields changed: %+v", got)
+			}
+			if *msg.Context.UserID != "user-1" || msg.Frame["phone"] != "555-1234" {
+				t.Error("original message was modified")
+			}
+		})
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	type item struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	tests := []struct {
+		name  string
+		value interface{}
+		want  interface{}
+	}{
+		{name: "string", value: "555-1234", want: "[number]"},
+		{name: "int", value: 42, want: "[number]"},
+		{name: "bool", value: true, want: true},
+		{name: "nil", value: nil, want: nil},
+		{name: "nested", value: map[string]interface{}{"a": []interface{}{"x 1", map[string]interface{}{"b": "y 2"}}},
+			want: map[string]interface{}{"a": []interface{}{"x [number]", map[string]interface{}{"b": "y [number]"}}}},
+		{name: "struct", value: item{Name: "order 7", Count: 3}, want: map[string]interface{}{"name": "order [number]", "count": "[number]"}},
+		{name: "typed slice", value: []string{"a 1", "b"}, want: []interface{}{"a [number]", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := redactValue(redact.Default(), redact.FieldText, tt.value)
+			if !ok || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redactValue = %#v, %t, want %#v", got, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,498 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/darrenparkinson/wxa-skills-go/health"
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is the name of the tracer used by the skill.
+const instrumentationName = "github.com/darrenparkinson/wxa-skills-go"
+
+// Handler responds to a decrypted message from Webex Assistant.
+type Handler interface {
+	ServeSkill(ctx context.Context, msg WebexAssistantMessage) (WebexAssistantResponse, error)
+}
+
+// HandlerFunc is an adapter to allow the use of ordinary functions as skill handlers.
+type HandlerFunc func(ctx context.Context, msg WebexAssistantMessage) (WebexAssistantResponse, error)
+
+// ServeSkill calls f(ctx, msg).
+func (f HandlerFunc) ServeSkill(ctx context.Context, msg WebexAssistantMessage) (WebexAssistantResponse, error) {
+	return f(ctx, msg)
+}
+
+// Limiter decides whether a turn from an org and user may proceed, e.g. a *ratelimit.Limiter.
+type Limiter interface {
+	Allow(orgID, userID string) bool
+}
+
+// DefaultThrottledText is the text sent to users whose turns are rate limited.
+const DefaultThrottledText = "Sorry, I'm a little busy right now. Please try again shortly."
+
+// Turn holds details about a single interaction with the skill which the handler can fill in,
+// for example the intent that was identified.  These are used when recording metrics, traces and logs.
+type Turn struct {
+	// ID correlates the logs and traces for the turn.  It is derived from the challenge.
+	ID            string
+	Intent        string
+	DialogueState string
+	Fallback      bool
+
+	// NLU holds any other results from natural language understanding, such as slot values,
+	// which are shown in the turn records.
+	NLU map[string]string
+}
+
+type turnKey struct{}
+
+type loggerKey struct{}
+
+// TurnFromContext returns the Turn for the current request.  It never returns nil, so a handler
+// can safely set fields even when it is called outside of a Skill.
+func TurnFromContext(ctx context.Context) *Turn {
+	if t, ok := ctx.Value(turnKey{}).(*Turn); ok {
+		return t
+	}
+	return &Turn{}
+}
+
+// LoggerFromContext returns the logger for the current request, which includes the turn ID along
+// with the org and user IDs.  If there is no logger in the context, slog.Default() is returned.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// Skill is an http.Handler that responds to the Webex Assistant health check and verifies and
+// decrypts requests before passing them to the Handler.  It can be created using NewSkill.
+type Skill struct {
+	// Name identifies the skill in turn records.  It is optional.
+	Name string
+
+	// PrivateKey is the PEM encoded private key for the skill.  Use SetCredentials to change it
+	// once the skill is serving requests.
+	PrivateKey string
+
+	// Secret is the secret used to sign requests to the skill.  Use SetCredentials to change it
+	// once the skill is serving requests.
+	Secret string
+
+	// Handler is called with each decrypted message.
+	Handler Handler
+
+	// Metrics is used to record metrics for the skill.  If nil, no metrics are recorded.
+	Metrics *Metrics
+
+	// Logger is used for all logging by the skill and is passed to the handler in the context.
+	// If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// TracerProvider is used to create spans for each turn.  If nil, the global provider is used.
+	TracerProvider trace.TracerProvider
+
+	// Redaction is applied to the org and user IDs recorded on spans.  If nil, they are recorded
+	// as is.  To redact logs, wrap the Logger handler with redact.NewHandler.
+	Redaction *redact.Policy
+
+	// Limiter is checked after each message is decrypted.  Turns which aren't allowed are sent a
+	// reply asking the user to try again instead of being passed to the Handler.  If nil, turns
+	// aren't limited.
+	Limiter Limiter
+
+	// ThrottledText is the reply sent when a turn is rate limited.  If empty, DefaultThrottledText is used.
+	ThrottledText string
+
+	// Health is run for the Webex Assistant health check, which reports a degraded status if any
+	// critical dependency fails.  If nil, the status is always OK.
+	Health *health.Registry
+
+	// MaxAge is how far the message timestamp may be from the current time before the message is
+	// rejected as stale, which limits the replay of captured requests.  If zero, the timestamp
+	// isn't checked.
+	MaxAge time.Duration
+
+	// Recorder is passed the details of every turn, redacted using the Redaction policy.  If nil,
+	// turns aren't recorded.
+	Recorder TurnRecorder
+
+	credentials atomic.Pointer[credentials]
+}
+
+// credentials are the private key and secret used for a turn.  They are swapped as a pair so
+// that a turn never sees the secret from one set and the key from another.
+type credentials struct {
+	privateKey string
+	secret     string
+}
+
+// NewSkill is a helper function that returns a new skill given the private key, secret and handler.
+func NewSkill(privateKey, secret string, handler Handler) (*Skill, error) {
+	if privateKey == "" {
+		return nil, errors.New("private key required")
+	}
+	if secret == "" {
+		return nil, errors.New("secret required")
+	}
+	if handler == nil {
+		return nil, errors.New("handler required")
+	}
+	s := &Skill{
+		PrivateKey: privateKey,
+		Secret:     secret,
+		Handler:    handler,
+	}
+	return s, nil
+}
+
+// SetCredentials replaces the private key and secret used by the skill, for example when they
+// are rotated.  The private key is checked before it is used and, if it is invalid, an error is
+// returned and the current credentials are kept.  Turns already in progress complete using the
+// credentials they started with.
+func (s *Skill) SetCredentials(privateKey, secret string) error {
+	if secret == "" {
+		return errors.New("secret required")
+	}
+	if _, err := parsePrivateKey(privateKey); err != nil {
+		return err
+	}
+	s.credentials.Store(&credentials{privateKey: privateKey, secret: secret})
+	return nil
+}
+
+// currentCredentials returns the credentials set by SetCredentials, or the PrivateKey and
+// Secret fields if they haven't been set.
+func (s *Skill) currentCredentials() *credentials {
+	if c := s.credentials.Load(); c != nil {
+		return c
+	}
+	return &credentials{privateKey: s.PrivateKey, secret: s.Secret}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Skill) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleHealthCheck(w, r)
+	case http.MethodPost:
+		s.handleTurn(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		s.errorResponse(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Skill) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, span := s.startSpan(r, "skill.health_check")
+	defer span.End()
+	creds := s.currentCredentials()
+	encodedSignature := r.URL.Query().Get("signature")
+	encodedCipher := r.URL.Query().Get("challenge")
+	if encodedSignature == "" {
+		s.errorResponse(w, r, http.StatusBadRequest, "missing signature")
+		return
+	}
+	if encodedCipher == "" {
+		s.errorResponse(w, r, http.StatusBadRequest, "missing message")
+		return
+	}
+	if err := verifyEncodedSignature(creds.secret, encodedCipher, encodedSignature); err != nil {
+		recordError(span, err)
+		s.logger().Warn("message has invalid signature and will not be processed", "error", err)
+		s.wireError(w, r, err)
+		return
+	}
+	decryptedChallenge, err := DecryptMessage(creds.privateKey, encodedCipher)
+	if err != nil {
+		recordError(span, err)
+		s.logger().Warn("unable to decrypt message", "error", err)
+		s.wireError(w, r, err)
+		return
+	}
+	report := s.Health.Run(ctx)
+	span.SetAttributes(attribute.String("wxas.health_status", report.Status))
+	whr := WebexAssistantHealthResponse{
+		Challenge: decryptedChallenge,
+		Status:    report.Status,
+	}
+	renderJSON(w, whr)
+}
+
+func (s *Skill) handleTurn(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	outcome := OutcomeOK
+	turn := &Turn{}
+	var (
+		msg     *WebexAssistantMessage
+		resp    *WebexAssistantResponse
+		turnErr error
+	)
+	logger := s.logger()
+	creds := s.currentCredentials()
+	ctx, span := s.startSpan(r, "skill.turn")
+	defer func() {
+		latency := time.Since(start)
+		s.Metrics.observeTurn(outcome, latency)
+		s.recordTurn(start, latency, outcome, turn, msg, resp, turnErr)
+		level := slog.LevelInfo
+		if outcome != OutcomeOK {
+			level = slog.LevelWarn
+		}
+		logger.Log(ctx, level, "turn complete",
+			"outcome", outcome,
+			"intent", turn.Intent,
+			"dialogue_state", turn.DialogueState,
+			"fallback", turn.Fallback,
+			"latency_ms", latency.Milliseconds(),
+		)
+		span.SetAttributes(
+			attribute.String("wxas.turn_id", turn.ID),
+			attribute.String("wxas.outcome", outcome),
+			attribute.String("wxas.intent", turn.Intent),
+			attribute.String("wxas.dialogue_state", turn.DialogueState),
+			attribute.Bool("wxas.fallback", turn.Fallback),
+		)
+		if outcome != OutcomeOK && outcome != OutcomeThrottled {
+			span.SetStatus(codes.Error, outcome)
+		}
+		span.End()
+	}()
+
+	var wr WebexAssistantRequest
+	err := decodeJSONBody(w, r, &wr)
+	if err != nil {
+		outcome = OutcomeInvalidRequest
+		turnErr = err
+		logger.Warn("invalid request", "error", err)
+		var mr *malformedRequest
+		if errors.As(err, &mr) {
+			s.errorResponse(w, r, mr.status, mr.msg)
+		} else {
+			s.errorResponse(w, r, http.StatusBadRequest, "invalid request")
+		}
+		return
+	}
+
+	if wr.Signature == "" || wr.Message == "" {
+		outcome = OutcomeInvalidRequest
+		turnErr = errors.New("missing signature or message")
+		s.errorResponse(w, r, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	_, verifySpan := s.tracer().Start(ctx, "skill.verify_signature")
+	if err := verifyEncodedSignature(creds.secret, wr.Message, wr.Signature); err != nil {
+		outcome = OutcomeBadSignature
+		turnErr = err
+		recordError(verifySpan, err)
+		verifySpan.End()
+		logger.Warn("message has invalid signature and will not be processed", "error", err)
+		s.wireError(w, r, err)
+		return
+	}
+	verifySpan.End()
+
+	_, decryptSpan := s.tracer().Start(ctx, "skill.decrypt")
+	decryptStart := time.Now()
+	decryptedMessage, err := DecryptMessage(creds.privateKey, wr.Message)
+	s.Metrics.observeDecrypt(time.Since(decryptStart))
+	if err != nil {
+		outcome = OutcomeDecryptFailure
+		turnErr = err
+		recordError(decryptSpan, err)
+		decryptSpan.End()
+		logger.Warn("unable to decrypt message", "error", err)
+		s.wireError(w, r, err)
+		return
+	}
+	decryptSpan.End()
+
+	_, parseSpan := s.tracer().Start(ctx, "skill.parse_message")
+	wam, err := ParseMessage(decryptedMessage)
+	if err != nil {
+		outcome = OutcomeMalformedJSON
+		turnErr = err
+		recordError(parseSpan, err)
+		parseSpan.End()
+		logger.Warn("error unmarshalling message", "error", err)
+		s.wireError(w, r, err)
+		return
+	}
+	parseSpan.SetAttributes(attribute.String("wxas.target_dialogue_state", wam.Params.TargetDialogueState))
+	parseSpan.End()
+	msg = &wam
+	if err := checkFresh(wam, s.MaxAge, time.Now()); err != nil {
+		outcome = OutcomeStale
+		turnErr = err
+		logger.Warn("message is stale and will not be processed", "error", err)
+		s.wireError(w, r, err)
+		return
+	}
+
+	turn.ID = turnID(wam.Challenge)
+	logger = logger.With(
+		"turn_id", turn.ID,
+		"org_id", stringValue(wam.Context.OrgID),
+		"user_id", stringValue(wam.Context.UserID),
+	)
+	span.SetAttributes(
+		attribute.String("wxas.org_id", s.Redaction.String(redact.FieldOrgID, stringValue(wam.Context.OrgID))),
+		attribute.String("wxas.user_id", s.Redaction.String(redact.FieldUserID, stringValue(wam.Context.UserID))),
+	)
+	if s.Limiter != nil && !s.Limiter.Allow(stringValue(wam.Context.OrgID), stringValue(wam.Context.UserID)) {
+		outcome = OutcomeThrottled
+		text := s.ThrottledText
+		if text == "" {
+			text = DefaultThrottledText
+		}
+		throttled := textResponse(text, wam.Challenge)
+		resp = &throttled
+		renderJSON(w, throttled)
+		return
+	}
+
+	ctx = context.WithValue(ctx, turnKey{}, turn)
+	ctx = context.WithValue(ctx, loggerKey{}, logger)
+	ctx, handlerSpan := s.tracer().Start(ctx, "skill.handler")
+	handlerStart := time.Now()
+	handlerResp, err := s.Handler.ServeSkill(ctx, wam)
+	s.Metrics.observeHandler(time.Since(handlerStart), turn, handlerResp)
+	if err != nil {
+		outcome = OutcomeHandlerError
+		turnErr = err
+		recordError(handlerSpan, err)
+		handlerSpan.End()
+		logger.Error("error from skill handler", "error", err)
+		s.errorResponse(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		return
+	}
+	handlerSpan.End()
+	resp = &handlerResp
+	renderJSON(w, handlerResp)
+}
+
+// textResponse returns a response which replies with and speaks the text before going to sleep.
+func textResponse(text, challenge string) WebexAssistantResponse {
+	return WebexAssistantResponse{
+		Directives: []WebexAssistantDirective{
+			{Name: DirectiveNameReply, Type: DirectiveTypeView, Payload: Payload{Text: text}},
+			{Name: DirectiveNameSpeak, Type: DirectiveTypeAction, Payload: Payload{Text: text}},
+			{Name: DirectiveNameSleep, Type: DirectiveTypeAction},
+		},
+		Challenge: challenge,
+	}
+}
+
+// startSpan starts the top level span for a request, continuing any trace propagated by the caller.
+func (s *Skill) startSpan(r *http.Request, name string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return s.tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+func (s *Skill) tracer() trace.Tracer {
+	tp := s.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// wireError responds to an error reading the request, using the status for the error.  Only
+// the text of the sentinel error is sent, so the details aren't disclosed.
+func (s *Skill) wireError(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusCode(err)
+	message := http.StatusText(status)
+	for _, sentinel := range []Err{ErrMalformedToken, ErrBadSignature, ErrDecrypt, ErrPayloadJSON, ErrStale} {
+		if errors.Is(err, sentinel) {
+			message = strings.TrimPrefix(string(sentinel), "wxas: ")
+			break
+		}
+	}
+	s.errorResponse(w, r, status, message)
+}
+
+// verifyEncodedSignature checks the base64 encoded signature of the payload, returning an error
+// wrapping ErrBadSignature if it isn't valid.
+func verifyEncodedSignature(secret, payload, encodedSignature string) error {
+	signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return fmt.Errorf("%w: error decoding signature: %s", ErrBadSignature, err)
+	}
+	if !VerifySignature(secret, payload, signature) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+func (s *Skill) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
+	env := envelope{"error": message}
+	err := writeJSON(w, status, env, nil)
+	if err != nil {
+		s.logger().Error("error writing response", "error", err)
+		w.WriteHeader(500)
+	}
+}
+
+func (s *Skill) logger() *slog.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return slog.Default()
+}
+
+// turnID derives a correlation ID for the turn from the challenge, which is unique per request.
+// The challenge is hashed so that it is never logged.  If there is no challenge, a random ID is used.
+func turnID(challenge string) string {
+	if challenge == "" {
+		b := make([]byte, 8)
+		rand.Read(b)
+		return hex.EncodeToString(b)
+	}
+	sum := sha256.Sum256([]byte(challenge))
+	return hex.EncodeToString(sum[:8])
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,233 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wxas
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/darrenparkinson/wxa-skills-go/health"
+	"github.com/darrenparkinson/wxa-skills-go/redact"
+)
+
+// testSkill returns a skill with the private key and the secret "secret" which doesn't log.
+func testSkill(t testing.TB, privateKey string, handler Handler) *Skill {
+	t.Helper()
+	skill, err := NewSkill(privateKey, "secret", handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skill.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return skill
+}
+
+// signedRequest returns a turn request with the message encrypted with the public key and
+// signed with the secret, in the same way as Webex Assistant.
+func signedRequest(t testing.TB, publicKey, secret, message string) *http.Request {
+	t.Helper()
+	token, err := EncryptMessage(publicKey, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]string{"signature": SignMessage(secret, token), "message": token})
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// FuzzParseMessage checks that no decrypted message causes a panic, that every error wraps
+// ErrPayloadJSON, and that a parsed message is unchanged by encoding and parsing it again.
+// Seeds are in testdata/fuzz/FuzzParseMessage.
+func FuzzParseMessage(f *testing.F) {
+	f.Fuzz(func(t *testing.T, decrypted string) {
+		wam, err := ParseMessage(decrypted)
+		if err != nil {
+			if !errors.Is(err, ErrPayloadJSON) {
+				t.Errorf("unexpected error for %q: %s", decrypted, err)
+			}
+			return
+		}
+		js, err := json.Marshal(wam)
+		if err != nil {
+			t.Fatalf("error encoding %q: %s", decrypted, err)
+		}
+		again, err := ParseMessage(string(js))
+		if err != nil {
+			t.Fatalf("error parsing encoded message %s: %s", js, err)
+		}
+		if js2, _ := json.Marshal(again); !bytes.Equal(js, js2) {
+			t.Errorf("message changed when parsed again:\n%s\n%s", js, js2)
+		}
+	})
+}
+
+// FuzzServeHTTP fuzzes the body of requests to a skill, which records and redacts each turn,
+// checking that no request causes a panic or an internal server error and that accepted turns
+// are answered with a valid response.  Seeds are in testdata/fuzz/FuzzServeHTTP.
+func FuzzServeHTTP(f *testing.F) {
+	privateKey, publicKey := testKeys(f)
+	handler := HandlerFunc(func(ctx context.Context, msg WebexAssistantMessage) (WebexAssistantResponse, error) {
+		resp := textResponse(msg.Text, msg.Challenge)
+		resp.Frame = msg.Frame
+		return resp, nil
+	})
+	skill := testSkill(f, privateKey, handler)
+	skill.Redaction = redact.Strict()
+	skill.Recorder = recorderFunc(func(TurnRecord) {})
+
+	for _, message := range []string{
+		`{"text":"hello","challenge":"abc"}`,
+		`{"params":{"target_dialogue_state":"skill_intro"},"frame":{"turns":1},"challenge":"abc"}`,
+		`{"text":"my number is 0123456789","context":{"orgId":"o","userId":"u"},"challenge":"abc"}`,
+		`{"text":1}`,
+	} {
+		body, _ := io.ReadAll(signedRequest(f, publicKey, "secret", message).Body)
+		f.Add(body)
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		skill.ServeHTTP(rec, req)
+		switch rec.Code {
+		case http.StatusOK:
+			var resp WebexAssistantResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Errorf("invalid response %q for %q: %s", rec.Body, body, err)
+			}
+		case http.StatusInternalServerError:
+			t.Errorf("internal server error for %q", body)
+		}
+	})
+}
+
+// limiterFunc adapts a function to the Limiter interface.
+type limiterFunc func(orgID, userID string) bool
+
+func (f limiterFunc) Allow(orgID, userID string) bool { return f(orgID, userID) }
+
+// TestSkillThrottled checks that turns denied by the limiter are answered with the throttled
+// text without calling the handler, and recorded with the throttled outcome.
+func TestSkillThrottled(t *testing.T) {
+	privateKey, publicKey := testKeys(t)
+	tests := []struct {
+		name          string
+		throttledText string
+		want          string
+	}{
+		{name: "default", want: DefaultThrottledText},
+		{name: "custom", throttledText: "Slow down!", want: "Slow down!"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			skill := testSkill(t, privateKey, HandlerFunc(func(ctx context.Context, msg WebexAssistantMessage) (WebexAssistantResponse, error) {
+				called = true
+				return textResponse(msg.Text, msg.Challenge), nil
+			}))
+			var orgID, userID string
+			skill.Limiter = limiterFunc(func(o, u string) bool {
+				orgID, userID = o, u
+				return false
+			})
+			skill.ThrottledText = tc.throttledText
+			var record TurnRecord
+			skill.Recorder = recorderFunc(func(r TurnRecord) { record = r })
+
+			rec := httptest.NewRecorder()
+			skill.ServeHTTP(rec, signedRequest(t, publicKey, "secret", `{"text":"hello","context":{"orgId":"o","userId":"u"},"challenge":"abc"}`))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			var resp WebexAssistantResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Challenge != "abc" {
+				t.Errorf("challenge = %q, want abc", resp.Challenge)
+			}
+			if len(resp.Directives) != 3 || resp.Directives[0].Payload.Text != tc.want || resp.Directives[1].Payload.Text != tc.want {
+				t.Errorf("directives = %+v, want reply and speak %q", resp.Directives, tc.want)
+			}
+			if called {
+				t.Error("handler was called for a throttled turn")
+			}
+			if orgID != "o" || userID != "u" {
+				t.Errorf("limiter called with %q, %q, want o, u", orgID, userID)
+			}
+			if record.Outcome != OutcomeThrottled {
+				t.Errorf("outcome = %q, want %q", record.Outcome, OutcomeThrottled)
+			}
+		})
+	}
+}
+
+// TestSkillHealthCheck checks that the signed health check returns the decrypted challenge and
+// the status of the skill's health checks.
+func TestSkillHealthCheck(t *testing.T) {
+	privateKey, publicKey := testKeys(t)
+	skill := testSkill(t, privateKey, HandlerFunc(func(ctx context.Context, msg WebexAssistantMessage) (WebexAssistantResponse, error) {
+		return textResponse(msg.Text, msg.Challenge), nil
+	}))
+	var depErr error
+	registry := health.NewRegistry()
+	registry.CacheTTL = 0
+	registry.Register("dependency", true, health.CheckerFunc(func(ctx context.Context) error { return depErr }))
+
+	tests := []struct {
+		name     string
+		err      error
+		noChecks bool
+		want     string
+	}{
+		{name: "no checks", noChecks: true, want: health.StatusOK},
+		{name: "critical check ok", want: health.StatusOK},
+		{name: "critical check fails", err: errors.New("down"), want: health.StatusDegraded},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			depErr = tc.err
+			skill.Health = registry
+			if tc.noChecks {
+				skill.Health = nil
+			}
+			challenge, err := EncryptMessage(publicKey, "health-challenge")
+			if err != nil {
+				t.Fatal(err)
+			}
+			q := url.Values{"challenge": {challenge}, "signature": {SignMessage("secret", challenge)}}
+			rec := httptest.NewRecorder()
+			skill.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+q.Encode(), nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			var resp WebexAssistantHealthResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Challenge != "health-challenge" || resp.Status != tc.want {
+				t.Errorf("response = %+v, want challenge health-challenge and status %s", resp, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// Keys holds a key pair and secret for a skill.
+type Keys struct {
+	PrivateKey string
+	PublicKey  string
+	Secret     string
+}
+
+// GenerateKeys returns a new PEM encoded RSA key pair and a random secret.  The keys are 2048
+// bits, which is quicker to generate than the keys created by wxa-cli but only suitable for testing.
+func GenerateKeys() (Keys, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return Keys{}, fmt.Errorf("cannot generate RSA key: %s", err)
+	}
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return Keys{}, fmt.Errorf("error creating public key: %s", err)
+	}
+	secret, err := randomString(16)
+	if err != nil {
+		return Keys{}, err
+	}
+	return Keys{
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		PublicKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes})),
+		Secret:     secret,
+	}, nil
+}
+
+var (
+	sharedKeysOnce sync.Once
+	sharedKeys     Keys
+	sharedKeysErr  error
+)
+
+// throwawayKeys returns a key pair which is generated once per process, since generating RSA
+// keys for every test is slow, along with a new secret.
+func throwawayKeys() (Keys, error) {
+	sharedKeysOnce.Do(func() {
+		sharedKeys, sharedKeysErr = GenerateKeys()
+	})
+	if sharedKeysErr != nil {
+		return Keys{}, sharedKeysErr
+	}
+	keys := sharedKeys
+	secret, err := randomString(16)
+	if err != nil {
+		return Keys{}, err
+	}
+	keys.Secret = secret
+	return keys, nil
+}
+
+// Client sends encrypted and signed turns to a skill, in the same way as Webex Assistant.
+type Client struct {
+	// URL is the URL of the skill.
+	URL string
+
+	// PublicKey is the PEM encoded public key for the skill.
+	PublicKey string
+
+	// Secret is the secret for the skill.
+	Secret string
+
+	// HTTPClient is used to send requests.  If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// Result is the result of sending a turn to a skill.
+type Result struct {
+	// Message is the message that was sent, before it was encrypted.
+	Message wxas.WebexAssistantMessage
+
+	// Request is the encrypted and signed request body that was sent.
+	Request []byte
+
+	// StatusCode is the HTTP status code returned by the skill.
+	StatusCode int
+
+	// Header is the response header.
+	Header http.Header
+
+	// Body is the raw response body.
+	Body []byte
+
+	// Challenge is the challenge that was sent to the skill.
+	Challenge string
+
+	// Response is the decoded response.  It is only set if the status code is 200.
+	Response wxas.WebexAssistantResponse
+}
+
+// Send encrypts, signs and sends the message to the skill.  If the message has no challenge, a
+// random one is used.  An error is only returned if the request can't be sent or the response
+// can't be decoded; a non 200 status code is reported in the Result.
+func (c *Client) Send(ctx context.Context, msg wxas.WebexAssistantMessage) (*Result, error) {
+	if msg.Challenge == "" {
+		challenge, err := randomString(32)
+		if err != nil {
+			return nil, err
+		}
+		msg.Challenge = challenge
+	}
+	js, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	token, err := wxas.EncryptMessage(c.PublicKey, string(js))
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(map[string]string{
+		"signature": wxas.SignMessage(c.Secret, token),
+		"message":   token,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp, respBody, err := c.do(ctx, http.MethodPost, c.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Message: msg, Request: body, StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody, Challenge: msg.Challenge}
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(result.Body, &result.Response); err != nil {
+			return nil, fmt.Errorf("error decoding response: %s", err)
+		}
+	}
+	return result, nil
+}
+
+// do sends a request to the skill, returning the response and its body.
+func (c *Client) do(ctx context.Context, method, url string, body []byte) (*http.Response, []byte, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, respBody, nil
+}
+
+// Directives returns the names of the directives in the response, in order.
+func (r *Result) Directives() []string {
+	names := make([]string, len(r.Response.Directives))
+	for i, d := range r.Response.Directives {
+		names[i] = d.Name.String()
+	}
+	return names
+}
+
+// Has reports whether the response contains the directive.
+func (r *Result) Has(name wxas.DirectiveName) bool {
+	for _, d := range r.Response.Directives {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Texts returns the text of each directive with the given name.  Directives with a list of
+// texts, such as ui-hint, contribute each item.
+func (r *Result) Texts(name wxas.DirectiveName) []string {
+	var texts []string
+	for _, d := range r.Response.Directives {
+		if d.Name == name {
+			texts = append(texts, DirectiveTexts(d)...)
+		}
+	}
+	return texts
+}
+
+// DirectiveTexts returns the text of the directive, which may be a single text or a list.
+func DirectiveTexts(d wxas.WebexAssistantDirective) []string {
+	switch t := d.Payload.Text.(type) {
+	case string:
+		return []string{t}
+	case *string:
+		if t != nil {
+			return []string{*t}
+		}
+	case []string:
+		return t
+	case []interface{}:
+		texts := make([]string, len(t))
+		for i, v := range t {
+			texts[i] = fmt.Sprint(v)
+		}
+		return texts
+	}
+	return nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// bodyText returns the body of a response for showing in failures, with JSON bodies such as
+// the skill's error responses compacted onto a single line.
+func bodyText(body []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err == nil {
+		return buf.String()
+	}
+	return string(bytes.TrimSpace(body))
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// Diff returns a line by line diff of want and got, with removed lines prefixed by "- " and
+// added lines by "+ ".  Long runs of unchanged lines are elided.  It returns an empty string if
+// they are the same, and says so if they only differ in the trailing newline.
+func Diff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var ops []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, "- "+a[i])
+			i++
+		default:
+			ops = append(ops, "+ "+b[j])
+			j++
+		}
+	}
+	// only show unchanged lines within diffContext lines of a change
+	show := make([]bool, len(ops))
+	changed := false
+	for k, op := range ops {
+		if op[0] == ' ' {
+			continue
+		}
+		changed = true
+		for c := k - diffContext; c <= k+diffContext; c++ {
+			if c >= 0 && c < len(ops) {
+				show[c] = true
+			}
+		}
+	}
+	if !changed {
+		return "want and got differ only in the trailing newline\n"
+	}
+	var sb strings.Builder
+	sb.WriteString("--- want\n+++ got\n")
+	for k, op := range ops {
+		if !show[k] {
+			if k == 0 || show[k-1] {
+				sb.WriteString("  ...\n")
+			}
+			continue
+		}
+		sb.WriteString(op + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	lines := func(n int, change map[int]string) string {
+		var s []string
+		for i := 1; i <= n; i++ {
+			if c, ok := change[i]; ok {
+				s = append(s, c)
+				continue
+			}
+			s = append(s, "line "+string(rune('a'+i-1)))
+		}
+		return strings.Join(s, "\n")
+	}
+	tests := []struct {
+		name      string
+		want, got string
+		diff      string
+	}{
+		{name: "same", want: "a\nb", got: "a\nb", diff: ""},
+		{name: "changed line", want: "a\nb\nc", got: "a\nx\nc", diff: "--- want\n+++ got\n  a\n- b\n+ x\n  c\n"},
+		{name: "added line", want: "a\nc", got: "a\nb\nc", diff: "--- want\n+++ got\n  a\n+ b\n  c\n"},
+		{name: "trailing newline", want: "a\nb\n", got: "a\nb", diff: "want and got differ only in the trailing newline\n"},
+		{name: "removed line", want: "a\nb\nc", got: "a\nc", diff: "--- want\n+++ got\n  a\n- b\n  c\n"},
+		{
+			name: "unchanged lines elided",
+			want: lines(12, nil),
+			got:  lines(12, map[int]string{6: "changed"}),
+			diff: "--- want\n+++ got\n  ...\n  line c\n  line d\n  line e\n- line f\n+ changed\n  line g\n  line h\n  line i\n  ...\n",
+		},
+		{
+			name: "separate changes",
+			want: lines(12, nil),
+			got:  lines(12, map[int]string{1: "first", 12: "last"}),
+			diff: "--- want\n+++ got\n- line a\n+ first\n  line b\n  line c\n  line d\n  ...\n  line i\n  line j\n  line k\n- line l\n+ last\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.want, tt.got); got != tt.diff {
+				t.Errorf("Diff =\n%s\nwant\n%s", got, tt.diff)
+			}
+		})
+	}
+}
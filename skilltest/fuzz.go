@@ -0,0 +1,158 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// FuzzDecrypt fuzzes wxas.DecryptMessage, checking that no token causes a panic and that every
+// error is one of the wire errors.  Call it from a fuzz target:
+//
+//	func FuzzDecrypt(f *testing.F) { skilltest.FuzzDecrypt(f) }
+func FuzzDecrypt(f *testing.F) {
+	f.Helper()
+	keys := fuzzKeys(f)
+	valid, err := wxas.EncryptMessage(keys.PublicKey, `{"text":"hello"}`)
+	if err != nil {
+		f.Fatalf("skilltest: %s", err)
+	}
+	for _, seed := range append(malformedTokens, valid, valid+".", valid[:len(valid)/2], "."+valid) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		_, err := wxas.DecryptMessage(keys.PrivateKey, token)
+		if err != nil && wxas.StatusCode(err) != http.StatusBadRequest {
+			t.Errorf("unexpected error for %q: %s", token, err)
+		}
+	})
+}
+
+// FuzzRequest fuzzes the body of requests to a skill with the handler, checking that no request
+// causes a panic or an internal server error unless the handler returned an error.  Call it
+// from a fuzz target:
+//
+//	func FuzzRequest(f *testing.F) { skilltest.FuzzRequest(f, wxas.HandlerFunc(handleMessage)) }
+func FuzzRequest(f *testing.F, handler wxas.Handler) {
+	f.Helper()
+	keys := fuzzKeys(f)
+	skill, handlerErrors := fuzzSkill(f, keys, handler)
+	token, err := wxas.EncryptMessage(keys.PublicKey, `{"text":"hello","challenge":"abc"}`)
+	if err != nil {
+		f.Fatalf("skilltest: %s", err)
+	}
+	valid, _ := json.Marshal(map[string]string{"signature": wxas.SignMessage(keys.Secret, token), "message": token})
+	f.Add(valid)
+	f.Add([]byte(`{}`))
+	f.Add([]byte(`{"signature":"","message":""}`))
+	f.Add([]byte(`{"signature":"AAAA","message":"a.b"}`))
+	for _, t := range malformedTokens {
+		body, _ := json.Marshal(map[string]string{"signature": wxas.SignMessage(keys.Secret, t), "message": t})
+		f.Add(body)
+	}
+	f.Fuzz(func(t *testing.T, body []byte) {
+		before := handlerErrors.Load()
+		status := serve(skill, body)
+		if status == http.StatusInternalServerError && handlerErrors.Load() == before {
+			t.Errorf("internal server error for %q", body)
+		}
+	})
+}
+
+// FuzzMessage fuzzes the decrypted message sent to a skill with the handler.  Each input is
+// encrypted and signed correctly, so the message parsing and the handler itself are exercised.
+// It checks that no message causes a panic or an internal server error unless the handler
+// returned an error.  Call it from a fuzz target:
+//
+//	func FuzzMessage(f *testing.F) { skilltest.FuzzMessage(f, wxas.HandlerFunc(handleMessage)) }
+func FuzzMessage(f *testing.F, handler wxas.Handler) {
+	f.Helper()
+	keys := fuzzKeys(f)
+	skill, handlerErrors := fuzzSkill(f, keys, handler)
+	for _, seed := range []string{
+		`{"text":"hello","challenge":"abc"}`,
+		`{"text":"","params":{"target_dialogue_state":"skill_intro"},"challenge":"abc"}`,
+		`{"text":"bonjour","params":{"locale":"fr_FR","timestamp":1},"context":{"orgId":"o","userId":"u"}}`,
+		`{"frame":{"a":[1,{"b":null}]}}`,
+		`{"text":1}`,
+		`null`,
+		``,
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, message string) {
+		token, err := wxas.EncryptMessage(keys.PublicKey, message)
+		if err != nil {
+			t.Fatalf("skilltest: %s", err)
+		}
+		body, _ := json.Marshal(map[string]string{"signature": wxas.SignMessage(keys.Secret, token), "message": token})
+		before := handlerErrors.Load()
+		status := serve(skill, body)
+		if status == http.StatusInternalServerError && handlerErrors.Load() == before {
+			t.Errorf("internal server error for %q", message)
+		}
+	})
+}
+
+// malformedTokens are seeds for tokens which aren't in the expected format.
+var malformedTokens = []string{"", ".", "..", "a", "a.", ".a", "a.b", "a.b.c", "!!!.???", "AAAA.AAAA", "AAAA.AAAA.AAAA"}
+
+func fuzzKeys(f *testing.F) Keys {
+	f.Helper()
+	keys, err := throwawayKeys()
+	if err != nil {
+		f.Fatalf("skilltest: %s", err)
+	}
+	return keys
+}
+
+// fuzzSkill returns a skill which doesn't log, along with a count of the errors returned by
+// the handler.
+func fuzzSkill(f *testing.F, keys Keys, handler wxas.Handler) (*wxas.Skill, *atomic.Int64) {
+	f.Helper()
+	var handlerErrors atomic.Int64
+	counting := wxas.HandlerFunc(func(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+		resp, err := handler.ServeSkill(ctx, msg)
+		if err != nil {
+			handlerErrors.Add(1)
+		}
+		return resp, err
+	})
+	skill, err := wxas.NewSkill(keys.PrivateKey, keys.Secret, counting)
+	if err != nil {
+		f.Fatalf("skilltest: %s", err)
+	}
+	skill.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return skill, &handlerErrors
+}
+
+// serve sends the body to the skill in-process, so a panic fails the fuzz target.
+func serve(skill *wxas.Skill, body []byte) int {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	skill.ServeHTTP(rec, req)
+	return rec.Code
+}
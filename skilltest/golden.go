@@ -0,0 +1,144 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// DefaultGoldenDir is the directory golden files are kept in, relative to the package being tested.
+const DefaultGoldenDir = "testdata/golden"
+
+// UpdateEnv is an environment variable which, if set to a non-empty value, makes new
+// harnesses rewrite golden files instead of comparing with them.
+const UpdateEnv = "SKILLTEST_UPDATE"
+
+// normaliser replaces volatile text, such as times, in snapshots.
+type normaliser struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+// Normalise replaces every match of the regular expression with the replacement in snapshots,
+// so that volatile text in responses, such as times or random choices, doesn't cause
+// failures.  The challenge is always normalised.
+func (h *Harness) Normalise(pattern, replacement string) *Harness {
+	h.normalisers = append(h.normalisers, normaliser{re: regexp.MustCompile(pattern), replacement: replacement})
+	return h
+}
+
+type snapshot struct {
+	Message  wxas.WebexAssistantMessage   `json:"message"`
+	Status   int                          `json:"status"`
+	Response *wxas.WebexAssistantResponse `json:"response,omitempty"`
+	Body     string                       `json:"body,omitempty"`
+}
+
+// Snapshot sends the turn, if it hasn't already been sent, and returns the message and full
+// response as indented JSON with the challenge and any other volatile text normalised.
+func (t *Turn) Snapshot() []byte {
+	t.h.t.Helper()
+	r := t.Send()
+	s := snapshot{Message: t.msg, Status: r.StatusCode}
+	s.Message.Challenge = "<challenge>"
+	if r.StatusCode == 200 {
+		resp := r.Response
+		if resp.Challenge == r.Challenge {
+			resp.Challenge = "<challenge>"
+		} else {
+			resp.Challenge = "<wrong challenge>"
+		}
+		s.Response = &resp
+	} else {
+		s.Body = bodyText(r.Body)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		t.h.t.Fatalf("skilltest: %s", err)
+	}
+	js := bytes.ReplaceAll(buf.Bytes(), []byte(r.Challenge), []byte("<challenge>"))
+	for _, n := range t.h.normalisers {
+		js = n.re.ReplaceAll(js, []byte(n.replacement))
+	}
+	return js
+}
+
+// ExpectGolden checks the snapshot of the turn matches the golden file named name in the
+// harness GoldenDir.  Run the tests with SKILLTEST_UPDATE set, or set the harness UpdateGolden,
+// to write the golden files.
+func (t *Turn) ExpectGolden(name string) *Turn {
+	t.h.t.Helper()
+	t.h.expectGolden(name, t.Snapshot())
+	return t
+}
+
+// ExpectGolden sends each turn and checks their snapshots, in order, match a single golden
+// file, e.g. to record the responses to every intent of the skill.
+func (h *Harness) ExpectGolden(name string, turns ...*Turn) {
+	h.t.Helper()
+	var b bytes.Buffer
+	for i, t := range turns {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.Write(t.Snapshot())
+	}
+	h.expectGolden(name, b.Bytes())
+}
+
+func (h *Harness) expectGolden(name string, got []byte) {
+	h.t.Helper()
+	path := filepath.Join(h.GoldenDir, name+".golden")
+	diff, err := CompareGolden(path, got, h.UpdateGolden)
+	if err != nil {
+		h.t.Fatalf("skilltest: %s", err)
+	}
+	if diff != "" {
+		h.t.Errorf("%s doesn't match, set %s=1 to accept the changes:\n%s", path, UpdateEnv, diff)
+	}
+}
+
+// CompareGolden compares got with the golden file at path, returning a diff if they differ.  If
+// update is true, the file is written instead.
+func CompareGolden(path string, got []byte, update bool) (string, error) {
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("error creating golden directory: %s", err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			return "", fmt.Errorf("error writing golden file: %s", err)
+		}
+		return "", nil
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("golden file %s doesn't exist, set %s=1 to create it", path, UpdateEnv)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading golden file: %s", err)
+	}
+	return Diff(string(want), string(got)), nil
+}
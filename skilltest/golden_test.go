@@ -0,0 +1,82 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+func TestExpectGolden(t *testing.T) {
+	h := New(t, wxas.HandlerFunc(testHandler))
+	h.Normalise("Welcome", "<greeting>")
+	h.ExpectGolden("turns", h.Intro(), h.Say("hello"), h.Say("fail"))
+	h.Say("hello").ExpectGolden("hello")
+}
+
+func TestExpectGoldenUpdate(t *testing.T) {
+	t.Setenv(UpdateEnv, "1")
+	dir := t.TempDir()
+	h := New(t, wxas.HandlerFunc(testHandler))
+	if !h.UpdateGolden {
+		t.Fatal("UpdateGolden not set by the environment variable")
+	}
+	h.GoldenDir = dir
+	h.Say("hello").ExpectGolden("hello")
+	got, err := os.ReadFile(filepath.Join(dir, "hello.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join("testdata", "golden", "hello.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("wrote:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestExpectGoldenFailures(t *testing.T) {
+	t.Setenv(UpdateEnv, "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.golden"), []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &recorder{TB: t}
+	h := New(rec, wxas.HandlerFunc(testHandler))
+	if h.UpdateGolden {
+		t.Fatal("UpdateGolden set without the environment variable")
+	}
+	h.GoldenDir = dir
+	rec.run(func() { h.Say("hello").ExpectGolden("hello") })
+	want := filepath.Join(dir, "hello.golden") + " doesn't match, set SKILLTEST_UPDATE=1 to accept the changes:\n--- want\n+++ got\n- {}\n+ {\n+   \"message\": {\n"
+	if len(rec.failures) != 1 || !strings.HasPrefix(rec.failures[0], want) {
+		t.Errorf("failures:\n%s\nwant prefix:\n%s", strings.Join(rec.failures, "\n"), want)
+	}
+
+	rec = &recorder{TB: t}
+	h = New(rec, wxas.HandlerFunc(testHandler))
+	h.GoldenDir = dir
+	rec.run(func() { h.Say("hello").ExpectGolden("missing") })
+	want = "skilltest: golden file " + filepath.Join(dir, "missing.golden") + " doesn't exist, set SKILLTEST_UPDATE=1 to create it"
+	if !rec.fatal || len(rec.failures) != 1 || rec.failures[0] != want {
+		t.Errorf("failures:\n%s\nwant:\n%s", strings.Join(rec.failures, "\n"), want)
+	}
+}
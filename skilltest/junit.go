@@ -0,0 +1,98 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",cdata"`
+}
+
+// WriteJUnit writes the results as JUnit XML, with a test suite for each script and a test case
+// for each conversation, so they can be shown by CI systems.
+func WriteJUnit(w io.Writer, results []*ScriptResult) error {
+	var suites junitTestSuites
+	var total time.Duration
+	for _, r := range results {
+		suite := junitTestSuite{Name: r.Name, Tests: len(r.Conversations)}
+		var d time.Duration
+		for _, c := range r.Conversations {
+			tc := junitTestCase{Name: c.Name, ClassName: r.Name, Time: seconds(c.Duration)}
+			switch {
+			case c.Error != "":
+				tc.Error = &junitProblem{Message: "unable to send turn", Body: c.Error}
+				suite.Errors++
+			case c.Failure != "":
+				tc.Failure = &junitProblem{Message: "unexpected response", Body: c.Failure}
+				suite.Failures++
+			}
+			d += c.Duration
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		suite.Time = seconds(d)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		total += d
+		suites.Suites = append(suites.Suites, suite)
+	}
+	suites.Time = seconds(total)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
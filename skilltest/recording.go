@@ -0,0 +1,104 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// Recording is a turn recorded from real traffic by wxa-cli record, which can be replayed
+// against another build of the skill.
+type Recording struct {
+	// Time is when the turn was recorded.
+	Time time.Time `json:"time"`
+
+	// Message is the decrypted message sent to the skill.
+	Message wxas.WebexAssistantMessage `json:"message"`
+
+	// Status is the status code returned by the skill.
+	Status int `json:"status"`
+
+	// Response is the response from the skill.  It is only set if the status code is 200.
+	Response *wxas.WebexAssistantResponse `json:"response,omitempty"`
+
+	// Body is the response body, if the status code isn't 200.
+	Body string `json:"body,omitempty"`
+}
+
+// ReadRecordings reads recordings from JSONL, one recording per line.
+func ReadRecordings(r io.Reader) ([]Recording, error) {
+	var recordings []Recording
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec Recording
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: invalid recording: %s", line, err)
+		}
+		recordings = append(recordings, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return recordings, nil
+}
+
+// Replay returns the message to send to replay the recorded turn.  The timestamp is updated
+// so the message isn't rejected as stale, and the challenge is cleared so a new one is used.
+func (rec Recording) Replay() wxas.WebexAssistantMessage {
+	msg := rec.Message
+	msg.Challenge = ""
+	if msg.Params.Timestamp != 0 {
+		msg.Params.Timestamp = time.Now().Unix()
+	}
+	return msg
+}
+
+// Compare compares the result of replaying the turn with the recording, returning a diff of
+// the status and directives if they differ.
+func (rec Recording) Compare(r *Result) string {
+	want := comparable(rec.Status, rec.Response, rec.Body)
+	got := comparable(r.StatusCode, &r.Response, string(r.Body))
+	if want == got {
+		return ""
+	}
+	return Diff(want, got)
+}
+
+func comparable(status int, resp *wxas.WebexAssistantResponse, body string) string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "status: %d\n", status)
+	if status != 200 || resp == nil {
+		fmt.Fprintf(&buf, "body: %s\n", bodyText([]byte(body)))
+		return buf.String()
+	}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(resp.Directives)
+	return buf.String()
+}
@@ -0,0 +1,337 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+	"gopkg.in/yaml.v2"
+)
+
+// Script is a set of conversations to test a skill with, written in YAML so tests can be written
+// without Go:
+//
+//	name: Echo skill
+//	conversations:
+//	  - name: introduces itself
+//	    turns:
+//	      - intro: true
+//	        expect:
+//	          reply_match: echo skill
+//	          listen: true
+//	  - name: echoes what the user says
+//	    turns:
+//	      - say: hello
+//	        locale: fr_FR
+//	        expect:
+//	          reply: hello
+//	          sleep: true
+//
+// The frame returned by the skill in each turn is sent with the next turn of the conversation.
+type Script struct {
+	Name          string         `yaml:"name"`
+	Conversations []Conversation `yaml:"conversations"`
+
+	// File is the file the script was loaded from.
+	File string `yaml:"-"`
+}
+
+// Conversation is a series of turns with the skill.
+type Conversation struct {
+	Name  string       `yaml:"name"`
+	Turns []ScriptTurn `yaml:"turns"`
+}
+
+// ScriptTurn is a single turn in a conversation and what the skill is expected to respond with.
+type ScriptTurn struct {
+	// Say is what the user says.
+	Say string `yaml:"say"`
+
+	// Intro invokes the skill without saying anything else, by setting the target dialogue
+	// state to skill_intro.
+	Intro bool `yaml:"intro"`
+
+	Locale              string `yaml:"locale"`
+	Language            string `yaml:"language"`
+	TimeZone            string `yaml:"time_zone"`
+	TargetDialogueState string `yaml:"target_dialogue_state"`
+	OrgID               string `yaml:"org_id"`
+	UserID              string `yaml:"user_id"`
+
+	// Frame, if set, is sent instead of the frame from the previous turn.
+	Frame map[string]interface{} `yaml:"frame"`
+
+	Expect Expectation `yaml:"expect"`
+}
+
+// Expectation is what the skill is expected to respond with.  Only the fields which are set
+// are checked.
+type Expectation struct {
+	// Status is the expected HTTP status code.  If zero, 200 is expected.
+	Status int `yaml:"status"`
+
+	Reply      string `yaml:"reply"`
+	ReplyMatch string `yaml:"reply_match"`
+	Speak      string `yaml:"speak"`
+	SpeakMatch string `yaml:"speak_match"`
+	Listen     *bool  `yaml:"listen"`
+	Sleep      *bool  `yaml:"sleep"`
+
+	// Directives is the exact list of directive names expected, in order.
+	Directives []string `yaml:"directives"`
+
+	// Frame holds values expected in the frame.  Other values in the frame are ignored.
+	Frame map[string]interface{} `yaml:"frame"`
+}
+
+// ScriptResult is the result of running a script.
+type ScriptResult struct {
+	Name          string
+	File          string
+	Conversations []ConversationResult
+}
+
+// ConversationResult is the result of running a conversation.
+type ConversationResult struct {
+	Name     string
+	Duration time.Duration
+
+	// Failure describes the turns which didn't respond as expected, including a diff.
+	Failure string
+
+	// Error is set if a turn couldn't be sent.
+	Error string
+}
+
+// Passed reports whether every turn in the conversation responded as expected.
+func (r ConversationResult) Passed() bool {
+	return r.Failure == "" && r.Error == ""
+}
+
+// Failed returns the number of conversations which failed or errored.
+func (r *ScriptResult) Failed() int {
+	n := 0
+	for _, c := range r.Conversations {
+		if !c.Passed() {
+			n++
+		}
+	}
+	return n
+}
+
+// LoadScript reads a script from a YAML file.
+func LoadScript(path string) (*Script, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Script
+	if err := yaml.UnmarshalStrict(b, &s); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", path, err)
+	}
+	s.File = path
+	if s.Name == "" {
+		s.Name = path
+	}
+	for i, c := range s.Conversations {
+		if c.Name == "" {
+			s.Conversations[i].Name = fmt.Sprintf("conversation %d", i+1)
+		}
+		for j, t := range c.Turns {
+			for _, pattern := range []string{t.Expect.ReplyMatch, t.Expect.SpeakMatch} {
+				if _, err := regexp.Compile(pattern); err != nil {
+					return nil, fmt.Errorf("%s: %s turn %d: invalid pattern %q: %s", path, s.Conversations[i].Name, j+1, pattern, err)
+				}
+			}
+		}
+	}
+	return &s, nil
+}
+
+// RunScript runs each conversation in the script against the skill using the client.
+func RunScript(ctx context.Context, c *Client, s *Script) *ScriptResult {
+	result := &ScriptResult{Name: s.Name, File: s.File}
+	for _, conv := range s.Conversations {
+		result.Conversations = append(result.Conversations, RunConversation(ctx, c, conv))
+	}
+	return result
+}
+
+// RunConversation sends each turn of the conversation to the skill using the client, carrying
+// the frame from one turn to the next.  It stops at the first turn which can't be sent.
+func RunConversation(ctx context.Context, c *Client, conv Conversation) ConversationResult {
+	start := time.Now()
+	result := ConversationResult{Name: conv.Name}
+	var failures []string
+	var frame wxas.Frame
+	for i, t := range conv.Turns {
+		msg := t.message(frame)
+		r, err := c.Send(ctx, msg)
+		if err != nil {
+			result.Error = fmt.Sprintf("turn %d %q: %s", i+1, t.Say, err)
+			break
+		}
+		frame = r.Response.Frame
+		if diff := t.Expect.check(r); diff != "" {
+			failures = append(failures, fmt.Sprintf("turn %d %q:\n%s", i+1, t.Say, diff))
+		}
+	}
+	result.Failure = strings.Join(failures, "\n")
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (t ScriptTurn) message(frame wxas.Frame) wxas.WebexAssistantMessage {
+	msg := wxas.WebexAssistantMessage{
+		Text:  t.Say,
+		Frame: frame,
+		Params: wxas.Params{
+			Locale:              t.Locale,
+			Language:            t.Language,
+			TimeZone:            t.TimeZone,
+			TargetDialogueState: t.TargetDialogueState,
+			Timestamp:           time.Now().Unix(),
+		},
+	}
+	if t.Intro {
+		msg.Params.TargetDialogueState = "skill_intro"
+	}
+	if t.Frame != nil {
+		msg.Frame = normalise(t.Frame).(map[string]interface{})
+	}
+	if t.OrgID != "" {
+		msg.Context.OrgID = &t.OrgID
+	}
+	if t.UserID != "" {
+		msg.Context.UserID = &t.UserID
+	}
+	return msg
+}
+
+// check compares the result with the expectation, returning a diff if they don't match.  Lines
+// are only rendered for what was expected, and the actual line is rendered the same as the
+// expected line when it matches so that the diff only shows the differences.
+func (e Expectation) check(r *Result) string {
+	var want, got []string
+	add := func(w, g string, ok bool) {
+		want = append(want, w)
+		if ok {
+			g = w
+		}
+		got = append(got, g)
+	}
+	status := e.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	add(fmt.Sprintf("status: %d", status), fmt.Sprintf("status: %d %s", r.StatusCode, bodyText(r.Body)), r.StatusCode == status)
+	if r.StatusCode != http.StatusOK {
+		return Diff(strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+	texts := func(name wxas.DirectiveName, text, pattern string) {
+		actual := r.Texts(name)
+		if text != "" {
+			add(fmt.Sprintf("%s: %q", name, text), fmt.Sprintf("%s: %s", name, quoteList(actual)), contains(actual, text))
+		}
+		if pattern != "" {
+			re := regexp.MustCompile(pattern)
+			ok := false
+			for _, s := range actual {
+				ok = ok || re.MatchString(s)
+			}
+			add(fmt.Sprintf("%s: /%s/", name, pattern), fmt.Sprintf("%s: %s", name, quoteList(actual)), ok)
+		}
+	}
+	texts(wxas.DirectiveNameReply, e.Reply, e.ReplyMatch)
+	texts(wxas.DirectiveNameSpeak, e.Speak, e.SpeakMatch)
+	for _, d := range []struct {
+		name   wxas.DirectiveName
+		expect *bool
+	}{{wxas.DirectiveNameListen, e.Listen}, {wxas.DirectiveNameSleep, e.Sleep}} {
+		if d.expect != nil {
+			has := r.Has(d.name)
+			add(fmt.Sprintf("%s: %t", d.name, *d.expect), fmt.Sprintf("%s: %t", d.name, has), has == *d.expect)
+		}
+	}
+	if e.Directives != nil {
+		w := "directives: [" + strings.Join(e.Directives, ", ") + "]"
+		g := "directives: " + directiveList(r)
+		add(w, g, w == g)
+	}
+	keys := make([]string, 0, len(e.Frame))
+	for k := range e.Frame {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w, _ := json.Marshal(normalise(e.Frame[k]))
+		g := []byte("<missing>")
+		if v, ok := r.Response.Frame[k]; ok {
+			g, _ = json.Marshal(v)
+		}
+		add(fmt.Sprintf("frame.%s: %s", k, w), fmt.Sprintf("frame.%s: %s", k, g), string(w) == string(g))
+	}
+	for i := range want {
+		if want[i] != got[i] {
+			return Diff(strings.Join(want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+	return ""
+}
+
+// normalise converts the maps decoded from YAML into maps with string keys so they can be
+// encoded as JSON.
+func normalise(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = normalise(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[k] = normalise(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, val := range v {
+			s[i] = normalise(val)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func contains(texts []string, text string) bool {
+	for _, s := range texts {
+		if s == text {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+func TestLoadScript(t *testing.T) {
+	s, err := LoadScript(filepath.Join("testdata", "conversations.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "Test skill" || len(s.Conversations) != 4 || s.File != filepath.Join("testdata", "conversations.yaml") {
+		t.Errorf("script = %s from %s with %d conversations", s.Name, s.File, len(s.Conversations))
+	}
+
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{name: "unknown field", yaml: "conversations:\n  - turns:\n      - sey: hello\n", wantErr: "field sey not found"},
+		{name: "invalid pattern", yaml: "conversations:\n  - name: bad\n    turns:\n      - say: hi\n        expect:\n          reply_match: \"(\"\n", wantErr: `bad turn 1: invalid pattern "("`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "script.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadScript(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadScript error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "defaults.yaml")
+	if err := os.WriteFile(path, []byte("conversations:\n  - turns:\n      - say: hi\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err = LoadScript(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != path || s.Conversations[0].Name != "conversation 1" {
+		t.Errorf("default names are %q and %q", s.Name, s.Conversations[0].Name)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	h := New(t, wxas.HandlerFunc(testHandler))
+	yes, no := true, false
+	s := &Script{Name: "script", Conversations: []Conversation{
+		{Name: "passes", Turns: []ScriptTurn{{Say: "hello", Expect: Expectation{Reply: "hello", Sleep: &yes}}}},
+		{Name: "frame", Turns: []ScriptTurn{
+			{Say: "count", Frame: map[string]interface{}{"turns": 4}, Expect: Expectation{Reply: "turn 5"}},
+			{Say: "count", Expect: Expectation{Frame: map[string]interface{}{"turns": 6, "missing": "x"}}},
+		}},
+		{Name: "fails", Turns: []ScriptTurn{
+			{Say: "hello", Expect: Expectation{SpeakMatch: "^bye", Listen: &yes, Sleep: &no, Directives: []string{"reply"}}},
+		}},
+		{Name: "wrong status", Turns: []ScriptTurn{{Say: "fail", Expect: Expectation{Reply: "hello"}}}},
+	}}
+	r := RunScript(context.Background(), h.Client, s)
+	if r.Name != "script" || len(r.Conversations) != 4 || r.Failed() != 3 {
+		t.Fatalf("result = %+v", r)
+	}
+	if !r.Conversations[0].Passed() {
+		t.Errorf("passes failed:\n%s", r.Conversations[0].Failure)
+	}
+	want := map[string]string{
+		"frame": `turn 2 "count":
+--- want
++++ got
+  status: 200
+- frame.missing: "x"
++ frame.missing: <missing>
+  frame.turns: 6
+`,
+		"fails": `turn 1 "hello":
+--- want
++++ got
+  status: 200
+- speak: /^bye/
+- listen: true
+- sleep: false
+- directives: [reply]
++ speak: "hello"
++ listen: false
++ sleep: true
++ directives: [reply, speak, sleep]
+`,
+		"wrong status": `turn 1 "fail":
+--- want
++++ got
+- status: 200
++ status: 500 {"error":"Internal Server Error"}
+`,
+	}
+	for _, c := range r.Conversations[1:] {
+		if c.Failure != want[c.Name] {
+			t.Errorf("%s failure:\n%s\nwant:\n%s", c.Name, c.Failure, want[c.Name])
+		}
+	}
+
+	broken := &Client{URL: "http://127.0.0.1:0", PublicKey: h.Keys.PublicKey, Secret: h.Keys.Secret}
+	r = RunScript(context.Background(), broken, &Script{Conversations: []Conversation{{Name: "unreachable", Turns: []ScriptTurn{{Say: "a"}, {Say: "b"}}}}})
+	if c := r.Conversations[0]; c.Passed() || !strings.HasPrefix(c.Error, `turn 1 "a": `) {
+		t.Errorf("unreachable skill result = %+v", c)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	results := []*ScriptResult{
+		{Name: "first", Conversations: []ConversationResult{
+			{Name: "ok", Duration: 1500 * time.Millisecond},
+			{Name: "failed", Duration: time.Second, Failure: "--- want\n+++ got\n- a\n+ b\n"},
+		}},
+		{Name: "second", Conversations: []ConversationResult{
+			{Name: "errored", Duration: 250 * time.Millisecond, Error: "connection refused"},
+		}},
+	}
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Errorf("output doesn't start with the XML header:\n%s", buf.String())
+	}
+	var got junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid XML: %s\n%s", err, buf.String())
+	}
+	if got.Tests != 3 || got.Failures != 1 || got.Errors != 1 || got.Time != "2.750" || len(got.Suites) != 2 {
+		t.Errorf("test suites = %+v", got)
+	}
+	first := got.Suites[0]
+	if first.Name != "first" || first.Tests != 2 || first.Failures != 1 || first.Time != "2.500" {
+		t.Errorf("first suite = %+v", first)
+	}
+	if tc := first.TestCases[1]; tc.ClassName != "first" || tc.Failure == nil || tc.Failure.Body != results[0].Conversations[1].Failure || tc.Error != nil {
+		t.Errorf("failed test case = %+v", tc)
+	}
+	if tc := got.Suites[1].TestCases[0]; tc.Error == nil || tc.Error.Body != "connection refused" || tc.Failure != nil {
+		t.Errorf("errored test case = %+v", tc)
+	}
+}
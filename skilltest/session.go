@@ -0,0 +1,106 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"context"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// Session is an interactive conversation with a skill.  Like Webex Assistant, it carries the
+// frame from one turn to the next while the skill is listening, and starts a new conversation
+// once the skill sleeps.
+type Session struct {
+	// Client sends the turns to the skill.
+	Client *Client
+
+	// Params are sent with each turn, e.g. to set the locale.  The target dialogue state and
+	// timestamp are set for each turn.
+	Params wxas.Params
+
+	// Context is sent with each turn, e.g. to set the supported directives.
+	Context wxas.Context
+
+	// Frame is the frame returned by the skill in the previous turn.
+	Frame wxas.Frame
+
+	// Listening reports whether the skill asked to listen for a reply in the previous turn.
+	// When it isn't listening, the next turn starts a new conversation.
+	Listening bool
+}
+
+// NewSession returns a session which sends turns using the client with the en_US locale.
+func NewSession(client *Client) *Session {
+	return &Session{
+		Client: client,
+		Params: wxas.Params{Locale: "en_US", Language: "en"},
+	}
+}
+
+// Message returns the message which would be sent for the text, as the next turn.
+func (s *Session) Message(text string) wxas.WebexAssistantMessage {
+	msg := wxas.WebexAssistantMessage{
+		Text:    text,
+		Params:  s.Params,
+		Context: s.Context,
+	}
+	msg.Params.Timestamp = time.Now().Unix()
+	msg.Params.TargetDialogueState = ""
+	if s.Listening {
+		msg.Frame = s.Frame
+	}
+	return msg
+}
+
+// Say sends the text to the skill.
+func (s *Session) Say(ctx context.Context, text string) (*Result, error) {
+	return s.Send(ctx, s.Message(text))
+}
+
+// Intro invokes the skill without saying anything else, starting a new conversation, so the
+// skill should introduce itself.
+func (s *Session) Intro(ctx context.Context) (*Result, error) {
+	s.Reset()
+	msg := s.Message("")
+	msg.Params.TargetDialogueState = "skill_intro"
+	return s.Send(ctx, msg)
+}
+
+// Send sends the message to the skill and keeps the frame from the response, if the skill
+// is listening.
+func (s *Session) Send(ctx context.Context, msg wxas.WebexAssistantMessage) (*Result, error) {
+	r, err := s.Client.Send(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != 200 {
+		return r, nil
+	}
+	s.Listening = r.Has(wxas.DirectiveNameListen) && !r.Has(wxas.DirectiveNameSleep)
+	if s.Listening {
+		s.Frame = r.Response.Frame
+	} else {
+		s.Frame = nil
+	}
+	return r, nil
+}
+
+// Reset ends the conversation, so the next turn starts a new one.
+func (s *Session) Reset() {
+	s.Frame = nil
+	s.Listening = false
+}
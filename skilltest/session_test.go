@@ -0,0 +1,82 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"context"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+func TestSession(t *testing.T) {
+	h := New(t, wxas.HandlerFunc(testHandler))
+	s := NewSession(h.Client)
+	ctx := context.Background()
+
+	say := func(text, want string) {
+		t.Helper()
+		r, err := s.Say(ctx, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Texts(wxas.DirectiveNameReply); len(got) != 1 || got[0] != want {
+			t.Errorf("say %q: reply %q, want %q", text, got, want)
+		}
+	}
+
+	// the frame is carried while the skill is listening
+	r, err := s.Intro(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Listening || s.Frame["turns"] != float64(1) || r.Message.Params.TargetDialogueState != "skill_intro" {
+		t.Fatalf("after intro: listening %t, frame %v", s.Listening, s.Frame)
+	}
+	say("count", "turn 2")
+	say("count", "turn 3")
+
+	// sleeping ends the conversation
+	say("hello", "hello")
+	if s.Listening || s.Frame != nil {
+		t.Fatalf("after sleep: listening %t, frame %v", s.Listening, s.Frame)
+	}
+	if msg := s.Message("count"); msg.Frame != nil {
+		t.Errorf("frame %v sent after the skill slept", msg.Frame)
+	}
+	say("count", "turn 1")
+
+	// as does an intro or a reset
+	say("count", "turn 2")
+	if _, err := s.Intro(ctx); err != nil {
+		t.Fatal(err)
+	}
+	say("count", "turn 2")
+	s.Reset()
+	if s.Listening || s.Frame != nil {
+		t.Fatalf("after reset: listening %t, frame %v", s.Listening, s.Frame)
+	}
+	say("count", "turn 1")
+
+	// a failed turn leaves the conversation as it was
+	r, err = s.Say(ctx, "fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.StatusCode != 500 || !s.Listening || s.Frame["turns"] != float64(1) {
+		t.Errorf("after failure: status %d, listening %t, frame %v", r.StatusCode, s.Listening, s.Frame)
+	}
+	say("count", "turn 2")
+}
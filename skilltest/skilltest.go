@@ -0,0 +1,362 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package skilltest provides an in-process harness for testing skills.  It serves the skill
+// with httptest using a throwaway key pair and secret, and sends it encrypted and signed turns
+// in the same way as Webex Assistant:
+//
+//	func TestEcho(t *testing.T) {
+//		h := skilltest.New(t, wxas.HandlerFunc(handleMessage))
+//		h.Say("hello").WithLocale("fr_FR").
+//			ExpectReply("hello").
+//			ExpectSleep().
+//			ExpectChallengeEcho()
+//		h.Intro().ExpectListen()
+//	}
+package skilltest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// Harness serves a skill for the duration of a test.  It can be created using New.
+type Harness struct {
+	// Skill is the skill being tested.  Its fields, such as Limiter or Health, can be changed
+	// before sending turns.
+	Skill *wxas.Skill
+
+	// Server serves the skill.  It is closed when the test completes.
+	Server *httptest.Server
+
+	// Keys are the throwaway keys and secret used by the skill.
+	Keys Keys
+
+	// Client sends turns to the skill.
+	Client *Client
+
+	// GoldenDir is the directory golden files are kept in.  It defaults to DefaultGoldenDir.
+	GoldenDir string
+
+	// UpdateGolden rewrites golden files instead of comparing with them.  It defaults to true
+	// if the SKILLTEST_UPDATE environment variable is set.
+	UpdateGolden bool
+
+	t           testing.TB
+	normalisers []normaliser
+}
+
+// New serves the handler as a skill using throwaway keys and returns a harness for sending it
+// turns.  The key pair is shared by every harness in the test binary to keep tests fast, but
+// each harness has its own secret.
+func New(t testing.TB, handler wxas.Handler) *Harness {
+	t.Helper()
+	keys, err := throwawayKeys()
+	if err != nil {
+		t.Fatalf("skilltest: %s", err)
+	}
+	skill, err := wxas.NewSkill(keys.PrivateKey, keys.Secret, handler)
+	if err != nil {
+		t.Fatalf("skilltest: %s", err)
+	}
+	skill.Logger = slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	srv := httptest.NewServer(skill)
+	t.Cleanup(srv.Close)
+	return &Harness{
+		Skill:        skill,
+		Server:       srv,
+		Keys:         keys,
+		Client:       &Client{URL: srv.URL, PublicKey: keys.PublicKey, Secret: keys.Secret, HTTPClient: srv.Client()},
+		GoldenDir:    DefaultGoldenDir,
+		UpdateGolden: os.Getenv(UpdateEnv) != "",
+		t:            t,
+	}
+}
+
+// testWriter writes the skill logs to the test log, so they are only shown when a test fails
+// or with go test -v.
+type testWriter struct {
+	t testing.TB
+}
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// Say returns a turn in which the user says the text.  It is sent when an expectation is
+// checked or Send is called.
+func (h *Harness) Say(text string) *Turn {
+	return &Turn{h: h, msg: wxas.WebexAssistantMessage{Text: text}}
+}
+
+// Intro returns a turn in which the user invokes the skill without saying anything else, so
+// the skill should introduce itself.
+func (h *Harness) Intro() *Turn {
+	return h.Say("").WithTargetDialogueState("skill_intro")
+}
+
+// Turn is a single turn sent to the skill.  It is built using the With methods and sent the
+// first time Send or an Expect method is called.  Expect methods report failures using the
+// test's Errorf, so several can be chained.
+type Turn struct {
+	h      *Harness
+	msg    wxas.WebexAssistantMessage
+	result *Result
+}
+
+// WithLocale sets the locale of the turn, e.g. "fr_FR".
+func (t *Turn) WithLocale(locale string) *Turn {
+	t.msg.Params.Locale = locale
+	return t
+}
+
+// WithLanguage sets the language of the turn, e.g. "fr".
+func (t *Turn) WithLanguage(language string) *Turn {
+	t.msg.Params.Language = language
+	return t
+}
+
+// WithTimeZone sets the time zone of the turn, e.g. "Europe/London".
+func (t *Turn) WithTimeZone(tz string) *Turn {
+	t.msg.Params.TimeZone = tz
+	return t
+}
+
+// WithTargetDialogueState sets the target dialogue state of the turn.
+func (t *Turn) WithTargetDialogueState(state string) *Turn {
+	t.msg.Params.TargetDialogueState = state
+	return t
+}
+
+// WithOrgID sets the org ID of the user making the request.
+func (t *Turn) WithOrgID(orgID string) *Turn {
+	t.msg.Context.OrgID = &orgID
+	return t
+}
+
+// WithUserID sets the ID of the user making the request.
+func (t *Turn) WithUserID(userID string) *Turn {
+	t.msg.Context.UserID = &userID
+	return t
+}
+
+// WithSupportedDirectives sets the directives supported by the device making the request.
+func (t *Turn) WithSupportedDirectives(directives ...string) *Turn {
+	t.msg.Context.SupportedDirectives = directives
+	return t
+}
+
+// WithChallenge sets the challenge instead of using a random one.
+func (t *Turn) WithChallenge(challenge string) *Turn {
+	t.msg.Challenge = challenge
+	return t
+}
+
+// WithMessage calls f to change any other part of the message.
+func (t *Turn) WithMessage(f func(msg *wxas.WebexAssistantMessage)) *Turn {
+	f(&t.msg)
+	return t
+}
+
+// Message returns the message that is sent for the turn.
+func (t *Turn) Message() wxas.WebexAssistantMessage {
+	return t.msg
+}
+
+// Send sends the turn, if it hasn't already been sent, and returns the result.  The test fails
+// immediately if the turn can't be sent.
+func (t *Turn) Send() *Result {
+	t.h.t.Helper()
+	if t.result != nil {
+		return t.result
+	}
+	result, err := t.h.Client.Send(context.Background(), t.msg)
+	if err != nil {
+		t.h.t.Fatalf("skilltest: unable to send %q: %s", t.msg.Text, err)
+	}
+	t.result = result
+	return result
+}
+
+// ExpectStatus checks the skill responded with the HTTP status code.
+func (t *Turn) ExpectStatus(code int) *Turn {
+	t.h.t.Helper()
+	if r := t.Send(); r.StatusCode != code {
+		t.errorf("expected status %d, got %d: %s", code, r.StatusCode, bodyText(r.Body))
+	}
+	return t
+}
+
+// ExpectReply checks the response contains a reply directive with the text.
+func (t *Turn) ExpectReply(text string) *Turn {
+	t.h.t.Helper()
+	t.expectText(wxas.DirectiveNameReply, text)
+	return t
+}
+
+// ExpectReplyMatch checks the response contains a reply directive matching the regular expression.
+func (t *Turn) ExpectReplyMatch(pattern string) *Turn {
+	t.h.t.Helper()
+	t.expectMatch(wxas.DirectiveNameReply, pattern)
+	return t
+}
+
+// ExpectSpeak checks the response contains a speak directive with the text.
+func (t *Turn) ExpectSpeak(text string) *Turn {
+	t.h.t.Helper()
+	t.expectText(wxas.DirectiveNameSpeak, text)
+	return t
+}
+
+// ExpectSpeakMatch checks the response contains a speak directive matching the regular expression.
+func (t *Turn) ExpectSpeakMatch(pattern string) *Turn {
+	t.h.t.Helper()
+	t.expectMatch(wxas.DirectiveNameSpeak, pattern)
+	return t
+}
+
+// ExpectListen checks the response asks the device to listen for the user's reply.
+func (t *Turn) ExpectListen() *Turn {
+	t.h.t.Helper()
+	return t.ExpectDirective(wxas.DirectiveNameListen)
+}
+
+// ExpectSleep checks the response ends the interaction.
+func (t *Turn) ExpectSleep() *Turn {
+	t.h.t.Helper()
+	return t.ExpectDirective(wxas.DirectiveNameSleep)
+}
+
+// ExpectDirective checks the response contains the directive.
+func (t *Turn) ExpectDirective(name wxas.DirectiveName) *Turn {
+	t.h.t.Helper()
+	if r := t.sendOK(); r != nil && !r.Has(name) {
+		t.errorf("expected a %s directive, got %s", name, directiveList(r))
+	}
+	return t
+}
+
+// ExpectChallengeEcho checks the response returns the challenge that was sent.
+func (t *Turn) ExpectChallengeEcho() *Turn {
+	t.h.t.Helper()
+	if r := t.sendOK(); r != nil && r.Response.Challenge != r.Challenge {
+		t.errorf("expected challenge %q to be echoed, got %q", r.Challenge, r.Response.Challenge)
+	}
+	return t
+}
+
+func (t *Turn) expectText(name wxas.DirectiveName, text string) {
+	t.h.t.Helper()
+	r := t.sendOK()
+	if r == nil {
+		return
+	}
+	texts := r.Texts(name)
+	for _, got := range texts {
+		if got == text {
+			return
+		}
+	}
+	t.errorf("expected %s %q, got %s", name, text, quoteList(texts))
+}
+
+func (t *Turn) expectMatch(name wxas.DirectiveName, pattern string) {
+	t.h.t.Helper()
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		t.h.t.Fatalf("skilltest: invalid pattern %q: %s", pattern, err)
+	}
+	r := t.sendOK()
+	if r == nil {
+		return
+	}
+	texts := r.Texts(name)
+	for _, got := range texts {
+		if re.MatchString(got) {
+			return
+		}
+	}
+	t.errorf("expected %s matching %q, got %s", name, pattern, quoteList(texts))
+}
+
+// sendOK sends the turn and returns the result, or reports an error and returns nil if the
+// skill didn't respond with a 200.
+func (t *Turn) sendOK() *Result {
+	t.h.t.Helper()
+	r := t.Send()
+	if r.StatusCode != http.StatusOK {
+		t.errorf("expected status 200, got %d: %s", r.StatusCode, bodyText(r.Body))
+		return nil
+	}
+	return r
+}
+
+func (t *Turn) errorf(format string, args ...interface{}) {
+	t.h.t.Helper()
+	t.h.t.Errorf("turn %q: %s", t.msg.Text, fmt.Sprintf(format, args...))
+}
+
+func directiveList(r *Result) string {
+	return "[" + strings.Join(r.Directives(), ", ") + "]"
+}
+
+func quoteList(texts []string) string {
+	if len(texts) == 0 {
+		return "none"
+	}
+	quoted := make([]string, len(texts))
+	for i, s := range texts {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// RunScript runs each conversation in the YAML script at path as a subtest, reporting any
+// turns which didn't respond as expected with a diff.
+func (h *Harness) RunScript(path string) {
+	h.t.Helper()
+	s, err := LoadScript(path)
+	if err != nil {
+		h.t.Fatalf("skilltest: %s", err)
+	}
+	t, ok := h.t.(*testing.T)
+	for _, conv := range s.Conversations {
+		run := func(tb testing.TB) {
+			tb.Helper()
+			r := RunConversation(context.Background(), h.Client, conv)
+			if r.Error != "" {
+				tb.Fatalf("%s: %s", conv.Name, r.Error)
+			}
+			if r.Failure != "" {
+				tb.Errorf("%s:\n%s", conv.Name, r.Failure)
+			}
+		}
+		if ok {
+			t.Run(conv.Name, func(t *testing.T) { run(t) })
+		} else {
+			run(h.t)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// testHandler is a small skill used to test the harness.  It introduces itself and listens,
+// counts the turns of a conversation in the frame, fails when asked to and otherwise echoes
+// what the user said and sleeps.
+func testHandler(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+	switch {
+	case msg.Params.TargetDialogueState == "skill_intro":
+		return testResponse(msg, "Welcome to the test skill", wxas.DirectiveNameListen, wxas.Frame{"turns": 1}), nil
+	case msg.Text == "fail":
+		return wxas.WebexAssistantResponse{}, errors.New("handler failed")
+	case msg.Text == "count":
+		n, _ := msg.Frame["turns"].(float64)
+		return testResponse(msg, fmt.Sprintf("turn %d", int(n)+1), wxas.DirectiveNameListen, wxas.Frame{"turns": n + 1}), nil
+	case msg.Params.Locale == "fr_FR":
+		return testResponse(msg, "bonjour "+msg.Text, wxas.DirectiveNameSleep, nil), nil
+	default:
+		return testResponse(msg, msg.Text, wxas.DirectiveNameSleep, nil), nil
+	}
+}
+
+func testResponse(msg wxas.WebexAssistantMessage, text string, next wxas.DirectiveName, frame wxas.Frame) wxas.WebexAssistantResponse {
+	return wxas.WebexAssistantResponse{
+		Challenge: msg.Challenge,
+		Frame:     frame,
+		Directives: []wxas.WebexAssistantDirective{
+			{Name: wxas.DirectiveNameReply, Type: wxas.DirectiveTypeView, Payload: wxas.Payload{Text: text}},
+			{Name: wxas.DirectiveNameSpeak, Type: wxas.DirectiveTypeAction, Payload: wxas.Payload{Text: &text}},
+			{Name: next, Type: wxas.DirectiveTypeAction},
+		},
+	}
+}
+
+// errFatal is used to stop a test function when the recorder's Fatalf is called.
+var errFatal = errors.New("fatal")
+
+// recorder is a testing.TB which records failures instead of failing the test, so the failure
+// messages from the harness can be checked.
+type recorder struct {
+	testing.TB
+	failures []string
+	fatal    bool
+}
+
+func (r *recorder) Helper()                   {}
+func (r *recorder) Log(args ...interface{})   {}
+func (r *recorder) Error(args ...interface{}) { r.failures = append(r.failures, fmt.Sprint(args...)) }
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	r.fatal = true
+	panic(errFatal)
+}
+
+// run calls f with the recorder, stopping at the first fatal failure.
+func (r *recorder) run(f func()) {
+	defer func() {
+		if v := recover(); v != nil && v != errFatal {
+			panic(v)
+		}
+	}()
+	f()
+}
+
+func TestHarness(t *testing.T) {
+	h := New(t, wxas.HandlerFunc(testHandler))
+	h.Say("hello").
+		ExpectStatus(http.StatusOK).
+		ExpectReply("hello").
+		ExpectReplyMatch("^hel+o$").
+		ExpectSpeak("hello").
+		ExpectSpeakMatch("ell").
+		ExpectSleep().
+		ExpectChallengeEcho()
+	h.Say("hello").WithLocale("fr_FR").ExpectReply("bonjour hello")
+	h.Intro().ExpectReplyMatch("Welcome").ExpectListen().ExpectDirective(wxas.DirectiveNameSpeak)
+	h.Say("fail").ExpectStatus(http.StatusInternalServerError)
+
+	turn := h.Say("hello").
+		WithOrgID("org").
+		WithUserID("user").
+		WithLanguage("en").
+		WithTimeZone("Europe/London").
+		WithSupportedDirectives("reply", "sleep").
+		WithChallenge("my-challenge").
+		WithMessage(func(msg *wxas.WebexAssistantMessage) { msg.Params.Timestamp = 1 })
+	msg := turn.Message()
+	if *msg.Context.OrgID != "org" || *msg.Context.UserID != "user" || msg.Params.Language != "en" ||
+		msg.Params.TimeZone != "Europe/London" || len(msg.Context.SupportedDirectives) != 2 || msg.Params.Timestamp != 1 {
+		t.Errorf("message = %+v", msg)
+	}
+	r := turn.ExpectChallengeEcho().Send()
+	if r.Challenge != "my-challenge" || turn.Send() != r {
+		t.Error("turn was sent more than once or with the wrong challenge")
+	}
+}
+
+func TestHarnessFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(h *Harness)
+		want []string
+	}{
+		{
+			name: "wrong reply",
+			run:  func(h *Harness) { h.Say("hello").ExpectReply("goodbye") },
+			want: []string{`turn "hello": expected reply "goodbye", got "hello"`},
+		},
+		{
+			name: "reply doesn't match",
+			run:  func(h *Harness) { h.Say("hello").ExpectReplyMatch("^bye") },
+			want: []string{`turn "hello": expected reply matching "^bye", got "hello"`},
+		},
+		{
+			name: "wrong speech",
+			run:  func(h *Harness) { h.Say("hello").ExpectSpeak("hi") },
+			want: []string{`turn "hello": expected speak "hi", got "hello"`},
+		},
+		{
+			name: "missing directive",
+			run:  func(h *Harness) { h.Say("hello").ExpectListen() },
+			want: []string{`turn "hello": expected a listen directive, got [reply, speak, sleep]`},
+		},
+		{
+			name: "chained failures are all reported",
+			run:  func(h *Harness) { h.Say("hello").ExpectReply("a").ExpectSpeak("b").ExpectSleep() },
+			want: []string{
+				`turn "hello": expected reply "a", got "hello"`,
+				`turn "hello": expected speak "b", got "hello"`,
+			},
+		},
+		{
+			name: "unexpected status",
+			run:  func(h *Harness) { h.Say("fail").ExpectReply("hello").ExpectStatus(http.StatusOK) },
+			want: []string{
+				`turn "fail": expected status 200, got 500: {"error":"Internal Server Error"}`,
+				`turn "fail": expected status 200, got 500: {"error":"Internal Server Error"}`,
+			},
+		},
+		{
+			name: "invalid pattern",
+			run:  func(h *Harness) { h.Say("hello").ExpectReplyMatch("(") },
+			want: []string{"skilltest: invalid pattern \"(\": error parsing regexp: missing closing ): `(`"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{TB: t}
+			h := New(rec, wxas.HandlerFunc(testHandler))
+			rec.run(func() { tt.run(h) })
+			if strings.Join(rec.failures, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("failures:\n%s\nwant:\n%s", strings.Join(rec.failures, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestHarnessRunScript(t *testing.T) {
+	h := New(t, wxas.HandlerFunc(testHandler))
+	h.RunScript(filepath.Join("testdata", "conversations.yaml"))
+
+	path := filepath.Join(t.TempDir(), "failing.yaml")
+	script := `
+name: failing
+conversations:
+  - name: wrong reply
+    turns:
+      - say: hello
+        expect:
+          reply: goodbye
+          sleep: true
+`
+	if err := os.WriteFile(path, []byte(script), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rec := &recorder{TB: t}
+	h = New(rec, wxas.HandlerFunc(testHandler))
+	rec.run(func() { h.RunScript(path) })
+	want := `wrong reply:
+turn 1 "hello":
+--- want
++++ got
+  status: 200
+- reply: "goodbye"
++ reply: "hello"
+  sleep: true
+`
+	if len(rec.failures) != 1 || rec.failures[0] != want {
+		t.Errorf("failures:\n%s\nwant:\n%s", strings.Join(rec.failures, "\n"), want)
+	}
+
+	rec = &recorder{TB: t}
+	h = New(rec, wxas.HandlerFunc(testHandler))
+	rec.run(func() { h.RunScript(filepath.Join("testdata", "missing.yaml")) })
+	if !rec.fatal {
+		t.Error("missing script didn't fail the test")
+	}
+}
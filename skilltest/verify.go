@@ -0,0 +1,335 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+// DefaultMaxLatency is how long a skill may take to respond to a turn before Verify fails.
+const DefaultMaxLatency = 3 * time.Second
+
+// Check is the result of one of the protocol checks run by Verify.
+type Check struct {
+	// Name describes what was checked.
+	Name string
+
+	// Failure describes why the check failed, or is empty if it passed.
+	Failure string
+
+	// Duration is how long the check took.
+	Duration time.Duration
+}
+
+// Passed reports whether the check passed.
+func (c Check) Passed() bool {
+	return c.Failure == ""
+}
+
+// knownDirectives are the directives which Webex Assistant understands.
+var knownDirectives = map[string]bool{}
+
+func init() {
+	for _, d := range []wxas.DirectiveName{
+		wxas.DirectiveNameReply, wxas.DirectiveNameLongReply, wxas.DirectiveNameSpeak,
+		wxas.DirectiveNameListen, wxas.DirectiveNameSleep, wxas.DirectiveNameUIHint,
+		wxas.DirectiveNameASRHint, wxas.DirectiveNameDisplay, wxas.DirectiveNameDisplayWebView,
+		wxas.DirectiveNameClearWebView, wxas.DirectiveNameAssistantEvent,
+	} {
+		knownDirectives[d.String()] = true
+	}
+}
+
+// Verify checks that the skill the client sends to follows the Webex Assistant protocol:
+//
+//   - the signed health check is answered with the decrypted challenge;
+//   - health checks and turns with an invalid signature are rejected with 401;
+//   - turns with tampered ciphertext are rejected with a 4xx status;
+//   - turns, including skill_intro, are answered with JSON which echoes the challenge and has
+//     valid directives, within maxLatency.
+//
+// Each check is run, in order, even if an earlier one fails.  If maxLatency is zero,
+// DefaultMaxLatency is used.
+func Verify(ctx context.Context, c *Client, maxLatency time.Duration) []Check {
+	if maxLatency == 0 {
+		maxLatency = DefaultMaxLatency
+	}
+	v := &verifier{c: c, maxLatency: maxLatency}
+	v.run(ctx, "health check responds with the challenge", v.healthCheck)
+	v.run(ctx, "health check rejects an invalid signature with 401", v.healthCheckBadSignature)
+	v.run(ctx, "turn responds with JSON which echoes the challenge", v.turn)
+	v.run(ctx, "turn has valid directives", v.turnDirectives)
+	v.run(ctx, "skill_intro turn is answered with a reply", v.intro)
+	v.run(ctx, "turn rejects an invalid signature with 401", v.turnBadSignature)
+	v.run(ctx, "turn rejects tampered ciphertext", v.turnTampered)
+	v.run(ctx, fmt.Sprintf("turns respond within %s", maxLatency), v.latency)
+	return v.checks
+}
+
+type verifier struct {
+	c          *Client
+	maxLatency time.Duration
+	checks     []Check
+
+	// results of the turns, which later checks use
+	turnResult  *Result
+	introResult *Result
+	slowest     time.Duration
+}
+
+func (v *verifier) run(ctx context.Context, name string, check func(context.Context) error) {
+	start := time.Now()
+	err := check(ctx)
+	c := Check{Name: name, Duration: time.Since(start)}
+	if err != nil {
+		c.Failure = err.Error()
+	}
+	v.checks = append(v.checks, c)
+}
+
+// send sends the message, recording the latency, and checks the response is JSON which
+// echoes the challenge.
+func (v *verifier) send(ctx context.Context, msg wxas.WebexAssistantMessage) (*Result, error) {
+	start := time.Now()
+	r, err := v.c.Send(ctx, msg)
+	if latency := time.Since(start); latency > v.slowest {
+		v.slowest = latency
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d, want 200: %s", r.StatusCode, bodyText(r.Body))
+	}
+	if r.Response.Challenge != r.Challenge {
+		return r, fmt.Errorf("challenge %q, want %q", r.Response.Challenge, r.Challenge)
+	}
+	return r, nil
+}
+
+func (v *verifier) healthCheck(ctx context.Context) error {
+	challenge, err := randomString(32)
+	if err != nil {
+		return err
+	}
+	token, err := wxas.EncryptMessage(v.c.PublicKey, challenge)
+	if err != nil {
+		return err
+	}
+	resp, body, err := v.healthRequest(ctx, token, wxas.SignMessage(v.c.Secret, token))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d, want 200: %s", resp.StatusCode, bodyText(body))
+	}
+	if err := checkJSON(resp.Header); err != nil {
+		return err
+	}
+	var health wxas.WebexAssistantHealthResponse
+	if err := json.Unmarshal(body, &health); err != nil {
+		return fmt.Errorf("invalid response: %s", err)
+	}
+	if health.Challenge != challenge {
+		return fmt.Errorf("challenge %q, want %q", health.Challenge, challenge)
+	}
+	if health.Status == "" {
+		return fmt.Errorf("no status in the response")
+	}
+	return nil
+}
+
+func (v *verifier) healthCheckBadSignature(ctx context.Context) error {
+	token, err := wxas.EncryptMessage(v.c.PublicKey, "challenge")
+	if err != nil {
+		return err
+	}
+	resp, body, err := v.healthRequest(ctx, token, wxas.SignMessage("not the secret", token))
+	if err != nil {
+		return err
+	}
+	return expectStatus(resp, body, http.StatusUnauthorized)
+}
+
+func (v *verifier) healthRequest(ctx context.Context, token, signature string) (*http.Response, []byte, error) {
+	u, err := url.Parse(v.c.URL)
+	if err != nil {
+		return nil, nil, err
+	}
+	q := u.Query()
+	q.Set("signature", signature)
+	q.Set("challenge", token)
+	u.RawQuery = q.Encode()
+	return v.c.do(ctx, http.MethodGet, u.String(), nil)
+}
+
+func (v *verifier) turn(ctx context.Context) error {
+	r, err := v.send(ctx, wxas.WebexAssistantMessage{
+		Text:   "hello",
+		Params: wxas.Params{Locale: "en_US", Language: "en", Timestamp: time.Now().Unix()},
+	})
+	v.turnResult = r
+	if err != nil {
+		return err
+	}
+	return checkJSON(r.Header)
+}
+
+func (v *verifier) turnDirectives(ctx context.Context) error {
+	if v.turnResult == nil {
+		return fmt.Errorf("no response to check")
+	}
+	return checkDirectives(v.turnResult.Response)
+}
+
+func (v *verifier) intro(ctx context.Context) error {
+	r, err := v.send(ctx, wxas.WebexAssistantMessage{
+		Params: wxas.Params{TargetDialogueState: "skill_intro", Locale: "en_US", Language: "en", Timestamp: time.Now().Unix()},
+	})
+	if err != nil {
+		return err
+	}
+	if err := checkDirectives(r.Response); err != nil {
+		return err
+	}
+	if !r.Has(wxas.DirectiveNameReply) && !r.Has(wxas.DirectiveNameSpeak) {
+		return fmt.Errorf("no reply or speak directive")
+	}
+	return nil
+}
+
+func (v *verifier) turnBadSignature(ctx context.Context) error {
+	token, err := v.token()
+	if err != nil {
+		return err
+	}
+	resp, body, err := v.post(ctx, token, wxas.SignMessage("not the secret", token))
+	if err != nil {
+		return err
+	}
+	return expectStatus(resp, body, http.StatusUnauthorized)
+}
+
+func (v *verifier) turnTampered(ctx context.Context) error {
+	token, err := v.token()
+	if err != nil {
+		return err
+	}
+	// flip a bit in the middle of the fernet token and sign it, so only decryption can fail
+	key, fernetToken, _ := strings.Cut(token, ".")
+	b, err := base64.StdEncoding.DecodeString(fernetToken)
+	if err != nil || len(b) == 0 {
+		return fmt.Errorf("unable to tamper with token")
+	}
+	b[len(b)/2] ^= 0x01
+	tampered := key + "." + base64.StdEncoding.EncodeToString(b)
+	resp, body, err := v.post(ctx, tampered, wxas.SignMessage(v.c.Secret, tampered))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 400 || resp.StatusCode >= 500 {
+		return fmt.Errorf("status %d, want 400: %s", resp.StatusCode, bodyText(body))
+	}
+	return nil
+}
+
+func (v *verifier) latency(ctx context.Context) error {
+	if v.slowest > v.maxLatency {
+		return fmt.Errorf("slowest turn took %s", v.slowest.Round(time.Millisecond))
+	}
+	return nil
+}
+
+func (v *verifier) token() (string, error) {
+	challenge, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	js, err := json.Marshal(wxas.WebexAssistantMessage{Text: "hello", Challenge: challenge})
+	if err != nil {
+		return "", err
+	}
+	return wxas.EncryptMessage(v.c.PublicKey, string(js))
+}
+
+func (v *verifier) post(ctx context.Context, token, signature string) (*http.Response, []byte, error) {
+	body, err := json.Marshal(map[string]string{"signature": signature, "message": token})
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.c.do(ctx, http.MethodPost, v.c.URL, body)
+}
+
+func expectStatus(resp *http.Response, body []byte, status int) error {
+	if resp.StatusCode != status {
+		return fmt.Errorf("status %d, want %d: %s", resp.StatusCode, status, bodyText(body))
+	}
+	return nil
+}
+
+func checkJSON(header http.Header) error {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return fmt.Errorf("content type %q, want application/json", header.Get("Content-Type"))
+	}
+	return nil
+}
+
+// checkDirectives checks the directives have known names and types, have the payload they
+// need, and end with listen or sleep.
+func checkDirectives(resp wxas.WebexAssistantResponse) error {
+	if len(resp.Directives) == 0 {
+		return fmt.Errorf("no directives")
+	}
+	var problems []string
+	for i, d := range resp.Directives {
+		name := d.Name.String()
+		if !knownDirectives[name] {
+			problems = append(problems, fmt.Sprintf("directive %d: unknown name %q", i+1, name))
+			continue
+		}
+		if t := d.Type.String(); t != wxas.DirectiveTypeView.String() && t != wxas.DirectiveTypeAction.String() {
+			problems = append(problems, fmt.Sprintf("directive %d (%s): type %q, want view or action", i+1, name, t))
+		}
+		switch d.Name {
+		case wxas.DirectiveNameReply, wxas.DirectiveNameLongReply, wxas.DirectiveNameSpeak, wxas.DirectiveNameUIHint:
+			if len(DirectiveTexts(d)) == 0 {
+				problems = append(problems, fmt.Sprintf("directive %d (%s): no text", i+1, name))
+			}
+		case wxas.DirectiveNameDisplayWebView:
+			if d.Payload.URL == nil || *d.Payload.URL == "" {
+				problems = append(problems, fmt.Sprintf("directive %d (%s): no url", i+1, name))
+			}
+		}
+	}
+	last := resp.Directives[len(resp.Directives)-1].Name
+	if last != wxas.DirectiveNameListen && last != wxas.DirectiveNameSleep {
+		problems = append(problems, fmt.Sprintf("last directive is %q, want listen or sleep", last))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package skilltest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	wxas "github.com/darrenparkinson/wxa-skills-go"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler wxas.HandlerFunc
+		latency time.Duration
+		failed  map[string]string
+	}{
+		{
+			name:    "valid skill",
+			handler: testHandler,
+		},
+		{
+			name: "challenge isn't echoed",
+			handler: func(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+				resp, err := testHandler(ctx, msg)
+				resp.Challenge = "wrong"
+				return resp, err
+			},
+			failed: map[string]string{
+				"turn responds with JSON which echoes the challenge": `challenge "wrong", want`,
+				"skill_intro turn is answered with a reply":          `challenge "wrong", want`,
+			},
+		},
+		{
+			name: "reply without text",
+			handler: func(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+				resp, err := testHandler(ctx, msg)
+				resp.Directives = append(resp.Directives, wxas.WebexAssistantDirective{Name: wxas.DirectiveNameReply, Type: wxas.DirectiveTypeView})
+				return resp, err
+			},
+			failed: map[string]string{
+				"turn has valid directives":                 "directive 4 (reply): no text",
+				"skill_intro turn is answered with a reply": "directive 4 (reply): no text",
+			},
+		},
+		{
+			name: "slow skill",
+			handler: func(ctx context.Context, msg wxas.WebexAssistantMessage) (wxas.WebexAssistantResponse, error) {
+				time.Sleep(20 * time.Millisecond)
+				return testHandler(ctx, msg)
+			},
+			latency: 10 * time.Millisecond,
+			failed: map[string]string{
+				"turns respond within 10ms": "slowest turn took",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(t, tt.handler)
+			checks := Verify(context.Background(), h.Client, tt.latency)
+			if len(checks) != 8 {
+				t.Fatalf("%d checks, want 8", len(checks))
+			}
+			for _, c := range checks {
+				want, fail := tt.failed[c.Name]
+				switch {
+				case fail && c.Passed():
+					t.Errorf("%s: passed, want failure containing %q", c.Name, want)
+				case fail && !strings.Contains(c.Failure, want):
+					t.Errorf("%s: failure %q, want failure containing %q", c.Name, c.Failure, want)
+				case !fail && !c.Passed():
+					t.Errorf("%s: %s", c.Name, c.Failure)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2021 Darren Parkinson
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupStdout(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := context.Background()
+	shutdown, err := Setup(ctx, Config{ServiceName: "test-skill", Exporter: ExporterStdout, Writer: &buf})
+	if err != nil {
+		t.Fatalf("Setup: %s", err)
+	}
+	_, span := otel.Tracer("tracing_test").Start(ctx, "test-span")
+	span.End()
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %s", err)
+	}
+	out := buf.String()
+	for _, want := range []string{`"Name": "test-span"`, `"Value": "test-skill"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("exported span doesn't contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		exporter string
+		wantErr  bool
+	}{
+		{exporter: ""},
+		{exporter: ExporterNone},
+		{exporter: ExporterOTLP},
+		{exporter: "zipkin", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.exporter, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), Config{ServiceName: "test-skill", Exporter: tt.exporter, Endpoint: "localhost:4318"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup(%q) error = %v, want error %t", tt.exporter, err, tt.wantErr)
+			}
+			if err == nil {
+				// nothing was traced, so shutting down doesn't contact the collector
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown: %s", err)
+				}
+			}
+		})
+	}
+}
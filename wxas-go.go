@@ -121,9 +121,8 @@ type WebexAssistantMessage struct {
 }
 
 // Frame contains information that needs to be preserved during multiple continuous interactions with the skill.
-type Frame struct {
-	//TODO: Find details about the Frame item
-}
+// The skill returns the frame in its response and receives it again in the next message of the conversation.
+type Frame map[string]interface{}
 
 // History contains the history of the conversation in a multi-turn interaction.
 type History struct {
@@ -156,6 +155,7 @@ type Context struct {
 // WebexAssistantResponse is what we can send back to webex assistant.
 type WebexAssistantResponse struct {
 	Directives []WebexAssistantDirective `json:"directives"`
+	Frame      Frame                     `json:"frame,omitempty"`
 	Challenge  string                    `json:"challenge"`
 }
 